@@ -2,27 +2,34 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"ragbot/internal/tansultant"
 	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"ragbot/internal/access"
 	"ragbot/internal/ai"
 	"ragbot/internal/bot"
 	"ragbot/internal/config"
 	"ragbot/internal/db"
 	"ragbot/internal/education"
 	"ragbot/internal/embedding"
+	"ragbot/internal/followup"
 	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/messenger"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
+	"ragbot/internal/vk"
 )
 
 func main() {
 	defer util.Recover("main")
 	cfg := config.LoadConfig()
 	config.LoadSettings()
+	loadMessageCatalog(cfg)
 
 	database, err := db.Connect(cfg.DatabaseURL)
 	if err != nil {
@@ -30,25 +37,74 @@ func main() {
 	}
 	defer database.Close()
 	repo := repository.New(database)
+	bootstrapAdmins(cfg, repo)
 
 	aiClient := ai.NewAIClient()
+	transcriber := ai.NewTranscriber()
 	tansClient := tansultant.NewClient()
+	tansClient.OnStale = bot.AlertTansultantStale
+	tansClient.StartRefresh(context.Background())
+	handler.UseScheduleSource(tansClient)
 
-	go handler.StartHTTP(repo, aiClient)
+	engine := messenger.NewEngine(repo, aiClient, tansClient, bot.SendToAdminsWith)
+	if vkBot := vk.New(engine); vkBot != nil {
+		handler.Mount(vk.CallbackPath, vkBot)
+	}
+
+	go handler.StartHTTP(repo, aiClient, engine.RequestCallback, engine.HandleWeb)
 
-	go bot.StartUserBot(repo, aiClient, tansClient, cfg.UserTelegramToken)
+	go bot.StartUserBot(repo, aiClient, transcriber, tansClient, engine, cfg.UserTelegramToken)
 
-	startEducationSourcesHandlers(cfg, repo)
+	startEducationSourcesHandlers(cfg, repo, aiClient, tansClient)
 
 	embedding.StartWorker(repo, aiClient)
+	followup.StartWorker(repo, aiClient, bot.SendFollowUp)
 
 	select {}
 }
 
-func startEducationSourcesHandlers(cfg *config.AppConfig, repo *repository.Repository) {
+func loadMessageCatalog(cfg *config.AppConfig) {
+	problems, err := i18n.Load(cfg.MessagesDir, cfg.DefaultLocale)
+	if err != nil {
+		log.Fatalf("Message catalog error: %v", err)
+	}
+	for _, p := range problems {
+		log.Printf("Message catalog: %s", p)
+	}
+}
+
+// bootstrapAdmins creates owner accounts from ADMIN_CHAT_IDS and the web login
+// ADMIN_USERNAME/ADMIN_PASSWORD on the first start. Later admins are managed in the admin bot.
+func bootstrapAdmins(cfg *config.AppConfig, repo *repository.Repository) {
+	var admins []repository.Admin
+	for _, id := range cfg.AdminChatIDs {
+		admins = append(admins, repository.Admin{Role: access.RoleOwner, ChatID: sql.NullInt64{Int64: id, Valid: true}})
+	}
+	if cfg.AdminPassword != "" {
+		hash, err := access.HashPassword(cfg.AdminPassword)
+		if err != nil {
+			log.Fatalf("Admin password error: %v", err)
+		}
+		// Веб-логин принадлежит первому владельцу, чтобы бот и веб были одной учётной записью
+		if len(admins) == 0 {
+			admins = append(admins, repository.Admin{Role: access.RoleOwner})
+		}
+		admins[0].Login = sql.NullString{String: cfg.AdminUsername, Valid: true}
+		admins[0].PasswordHash = hash
+	}
+	created, err := repo.BootstrapAdmins(context.Background(), admins)
+	if err != nil {
+		log.Fatalf("Admin accounts error: %v", err)
+	}
+	if created {
+		log.Printf("Created %d owner accounts", len(admins))
+	}
+}
+
+func startEducationSourcesHandlers(cfg *config.AppConfig, repo *repository.Repository, aiClient *ai.AIClient, tansClient *tansultant.Client) {
 	ctx := context.Background()
 	sources := []education.Source{
-		&education.AdminSource{Token: cfg.AdminTelegramToken, AllowedIDs: cfg.AdminChatIDs},
+		&education.AdminSource{Token: cfg.AdminTelegramToken, AIClient: aiClient},
 	}
 	if cfg.EducationFilePath != "" {
 		sources = append(sources, &education.FileSource{Path: cfg.EducationFilePath, Interval: time.Hour})
@@ -56,6 +112,9 @@ func startEducationSourcesHandlers(cfg *config.AppConfig, repo *repository.Repos
 	if cfg.YandexYMLURL != "" {
 		sources = append(sources, &education.YandexYMLSource{URL: cfg.YandexYMLURL, Interval: time.Hour})
 	}
+	if tansClient.Configured() {
+		sources = append(sources, &education.TansultantSource{Client: tansClient, Interval: time.Hour})
+	}
 	if cfg.UseExternalSource {
 		sources = append(sources, &education.ExternalDBSource{})
 	}
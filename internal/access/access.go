@@ -0,0 +1,116 @@
+// Package access defines the roles of admin accounts and what each role may do.
+package access
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Роли администраторов, каждая следующая включает права предыдущих
+const (
+	RoleViewer  = "viewer"
+	RoleEditor  = "editor"
+	RoleManager = "manager"
+	RoleOwner   = "owner"
+)
+
+// Roles lists the roles from the least to the most privileged.
+var Roles = []string{RoleViewer, RoleEditor, RoleManager, RoleOwner}
+
+// Permission is an action an admin may be allowed to perform.
+type Permission int
+
+const (
+	// ViewKnowledge allows reading the knowledge base, its history and statistics
+	ViewKnowledge Permission = iota
+	// EditKnowledge allows adding, changing, importing and restoring chunks
+	EditKnowledge
+	// ViewTranscripts allows reading user conversations
+	ViewTranscripts
+	// Broadcast allows sending messages to bot users
+	Broadcast
+	// ManageSettings allows managing admin accounts
+	ManageSettings
+)
+
+// minRole is the least privileged role that has the permission.
+var minRole = map[Permission]string{
+	ViewKnowledge:   RoleViewer,
+	EditKnowledge:   RoleEditor,
+	ViewTranscripts: RoleManager,
+	Broadcast:       RoleManager,
+	ManageSettings:  RoleOwner,
+}
+
+func rank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidRole reports whether role is one of Roles.
+func ValidRole(role string) bool {
+	return rank(role) >= 0
+}
+
+// Allowed reports whether the role has the permission.
+func Allowed(role string, p Permission) bool {
+	min, ok := minRole[p]
+	return ok && rank(role) >= rank(min)
+}
+
+// RequiredRole returns the least privileged role that has the permission.
+func RequiredRole(p Permission) string {
+	return minRole[p]
+}
+
+// Пароли веб-интерфейса хранятся как pbkdf2-sha256$<итерации>$<соль>$<ключ>
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 100000
+	saltSize       = 16
+	keySize        = 32
+)
+
+// HashPassword returns the password hash to store in the admin account.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, hashIterations, keySize)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether the password matches the stored hash.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err1 := enc.DecodeString(parts[2])
+	want, err2 := enc.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
@@ -0,0 +1,42 @@
+package access
+
+import "testing"
+
+func TestAllowed(t *testing.T) {
+	cases := []struct {
+		role string
+		p    Permission
+		want bool
+	}{
+		{RoleViewer, ViewKnowledge, true},
+		{RoleViewer, EditKnowledge, false},
+		{RoleEditor, EditKnowledge, true},
+		{RoleEditor, ViewTranscripts, false},
+		{RoleManager, Broadcast, true},
+		{RoleManager, ManageSettings, false},
+		{RoleOwner, ManageSettings, true},
+		{"", ViewKnowledge, false},
+		{"admin", ViewKnowledge, false},
+	}
+	for _, c := range cases {
+		if got := Allowed(c.role, c.p); got != c.want {
+			t.Errorf("Allowed(%q, %d) = %v, want %v", c.role, c.p, got, c.want)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("пароль")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !CheckPassword(hash, "пароль") {
+		t.Error("the password must match its hash")
+	}
+	if CheckPassword(hash, "secret") || CheckPassword("secret", "secret") || CheckPassword("", "") {
+		t.Error("a wrong password or hash must not match")
+	}
+	if other, _ := HashPassword("пароль"); other == hash {
+		t.Error("hashes of the same password must use different salts")
+	}
+}
@@ -1,6 +1,8 @@
 package ai
 
 import (
+	"context"
+
 	"ragbot/internal/config"
 )
 
@@ -9,6 +11,11 @@ type ModelStrategy interface {
 	GenerateResponse(prompt string) (string, error)
 }
 
+// StreamingStrategy is implemented by models that return the answer in parts.
+type StreamingStrategy interface {
+	GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error)
+}
+
 type AIClient struct {
 	strategy ModelStrategy
 }
@@ -27,3 +34,22 @@ func (a *AIClient) GenerateEmbedding(text string) ([]float32, error) {
 func (a *AIClient) GenerateResponse(prompt string) (string, error) {
 	return a.strategy.GenerateResponse(prompt)
 }
+
+// GenerateResponseStream passes parts of the answer to onDelta as they are generated
+// and returns the whole answer. Models without streaming return it in one part.
+// Generation stops with ctx.Err() once ctx is done.
+func (a *AIClient) GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	if s, ok := a.strategy.(StreamingStrategy); ok {
+		return s.GenerateResponseStream(ctx, prompt, onDelta)
+	}
+	text, err := a.strategy.GenerateResponse(prompt)
+	if err != nil {
+		return "", err
+	}
+	// Ответ без потока нельзя прервать, но ушедшему клиенту он уже не нужен
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	onDelta(text)
+	return text, nil
+}
@@ -2,7 +2,11 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
+
 	go_openai "github.com/sashabaranov/go-openai"
 )
 
@@ -27,16 +31,53 @@ func (g *GPTStrategy) GenerateEmbedding(text string) ([]float32, error) {
 	return resp.Data[0].Embedding, nil
 }
 
-func (g *GPTStrategy) GenerateResponse(prompt string) (string, error) {
-	chatReq := go_openai.ChatCompletionRequest{
+func chatRequest(prompt string) go_openai.ChatCompletionRequest {
+	return go_openai.ChatCompletionRequest{
 		Model:       go_openai.GPT4oMini, //go_openai.GPT3Dot5Turbo,
 		Messages:    []go_openai.ChatCompletionMessage{{Role: "system", Content: prompt}},
 		MaxTokens:   512,
 		Temperature: 0.2,
 	}
-	resp, err := g.client.CreateChatCompletion(context.Background(), chatReq)
+}
+
+func (g *GPTStrategy) GenerateResponse(prompt string) (string, error) {
+	resp, err := g.client.CreateChatCompletion(context.Background(), chatRequest(prompt))
 	if err != nil {
 		return "", fmt.Errorf("OpenAI chat error: %v", err)
 	}
 	return resp.Choices[0].Message.Content, nil
 }
+
+func (g *GPTStrategy) GenerateResponseStream(ctx context.Context, prompt string, onDelta func(string)) (string, error) {
+	req := chatRequest(prompt)
+	req.Stream = true
+	stream, err := g.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("OpenAI chat stream error: %v", err)
+	}
+	defer stream.Close()
+
+	var sb strings.Builder
+	for {
+		// Отмена ctx закрывает соединение, но Recv может вернуть уже полученную часть
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return sb.String(), nil
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return "", ctxErr
+			}
+			return "", fmt.Errorf("OpenAI chat stream error: %v", err)
+		}
+		if len(resp.Choices) == 0 || resp.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := resp.Choices[0].Delta.Content
+		sb.WriteString(delta)
+		onDelta(delta)
+	}
+}
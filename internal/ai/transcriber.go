@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"ragbot/internal/config"
+)
+
+// ErrEmptyTranscript is returned when speech recognition produced no text.
+var ErrEmptyTranscript = errors.New("empty transcript")
+
+// TranscriptionStrategy converts recorded speech into text.
+type TranscriptionStrategy interface {
+	Transcribe(filename string, audio io.Reader) (string, error)
+}
+
+// Transcriber turns voice messages into text using the configured strategy.
+type Transcriber struct {
+	strategy TranscriptionStrategy
+}
+
+// NewTranscriber returns a transcriber for the configured provider
+// or nil when speech recognition is disabled.
+func NewTranscriber() *Transcriber {
+	switch config.Config.TranscriptionProvider {
+	case config.TranscriptionOpenAI:
+		return NewTranscriberWithStrategy(NewWhisperAPIStrategy(config.Config.OpenAIAPIKey, config.Config.TranscriptionLanguage))
+	case config.TranscriptionLocal:
+		return NewTranscriberWithStrategy(NewLocalWhisperStrategy(config.Config.WhisperServerURL, config.Config.TranscriptionLanguage))
+	}
+	return nil
+}
+
+// NewTranscriberWithStrategy wraps an arbitrary strategy, e.g. a fake one in tests.
+func NewTranscriberWithStrategy(s TranscriptionStrategy) *Transcriber {
+	return &Transcriber{strategy: s}
+}
+
+func (t *Transcriber) Transcribe(filename string, audio io.Reader) (string, error) {
+	text, err := t.strategy.Transcribe(filename, audio)
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrEmptyTranscript
+	}
+	return text, nil
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeTranscription struct {
+	text string
+	err  error
+}
+
+func (f fakeTranscription) Transcribe(string, io.Reader) (string, error) {
+	return f.text, f.err
+}
+
+func TestTranscriberTrimsText(t *testing.T) {
+	tr := NewTranscriberWithStrategy(fakeTranscription{text: "  сколько стоит абонемент?\n"})
+	text, err := tr.Transcribe("voice.ogg", strings.NewReader("audio"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "сколько стоит абонемент?" {
+		t.Fatalf("unexpected transcript: %q", text)
+	}
+}
+
+func TestTranscriberEmptyTranscript(t *testing.T) {
+	tr := NewTranscriberWithStrategy(fakeTranscription{text: " "})
+	if _, err := tr.Transcribe("voice.ogg", strings.NewReader("audio")); !errors.Is(err, ErrEmptyTranscript) {
+		t.Fatalf("expected ErrEmptyTranscript, got %v", err)
+	}
+}
+
+func TestLocalWhisperStrategy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("file not sent: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		if header.Filename != "voice.ogg" || string(data) != "audio" {
+			t.Errorf("unexpected file %s: %q", header.Filename, data)
+		}
+		if lang := r.FormValue("language"); lang != "ru" {
+			t.Errorf("unexpected language: %q", lang)
+		}
+		w.Write([]byte(`{"text":"где вы находитесь"}`))
+	}))
+	defer srv.Close()
+
+	s := NewLocalWhisperStrategy(srv.URL, "ru")
+	text, err := s.Transcribe("voice.ogg", strings.NewReader("audio"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "где вы находитесь" {
+		t.Fatalf("unexpected transcript: %q", text)
+	}
+}
+
+func TestLocalWhisperStrategyError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := NewLocalWhisperStrategy(srv.URL, "")
+	if _, err := s.Transcribe("voice.ogg", strings.NewReader("audio")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	go_openai "github.com/sashabaranov/go-openai"
+)
+
+// WhisperAPIStrategy распознаёт речь через OpenAI Whisper API
+type WhisperAPIStrategy struct {
+	client   *go_openai.Client
+	language string
+}
+
+// NewWhisperAPIStrategy создаёт WhisperAPIStrategy с заданным API-ключом
+func NewWhisperAPIStrategy(apiKey, language string) *WhisperAPIStrategy {
+	return &WhisperAPIStrategy{client: go_openai.NewClient(apiKey), language: language}
+}
+
+func (w *WhisperAPIStrategy) Transcribe(filename string, audio io.Reader) (string, error) {
+	resp, err := w.client.CreateTranscription(context.Background(), go_openai.AudioRequest{
+		Model:    go_openai.Whisper1,
+		FilePath: filename,
+		Reader:   audio,
+		Language: w.language,
+		Format:   go_openai.AudioResponseFormatJSON,
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI transcription error: %v", err)
+	}
+	return resp.Text, nil
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// LocalWhisperStrategy отправляет аудио на локальный whisper-сервер
+// (whisper.cpp server или совместимый), который отвечает JSON вида {"text": "..."}.
+type LocalWhisperStrategy struct {
+	HTTPClient *http.Client
+	url        string
+	language   string
+}
+
+func NewLocalWhisperStrategy(url, language string) *LocalWhisperStrategy {
+	return &LocalWhisperStrategy{
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		url:        url,
+		language:   language,
+	}
+}
+
+func (l *LocalWhisperStrategy) Transcribe(filename string, audio io.Reader) (string, error) {
+	if l.url == "" {
+		return "", fmt.Errorf("whisper server url not set")
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return "", err
+	}
+	form.WriteField("response_format", "json")
+	if l.language != "" {
+		form.WriteField("language", l.language)
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, l.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whisper server request error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("whisper server unexpected status: %s", resp.Status)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("whisper server response parse error: %v", err)
+	}
+	return result.Text, nil
+}
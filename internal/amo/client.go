@@ -115,6 +115,39 @@ func SendLeadToAMO(repo *repository.Repository, info *conversation.ChatInfo, lin
 
 // SendLeadToAMO создает лид в amoCRM используя API v4
 func (c *AmoClient) SendLeadToAMO(repo *repository.Repository, info *conversation.ChatInfo, link string) error {
+	return c.sendLead(repo, info, link, nil)
+}
+
+// Booking описывает запись на пробное занятие, прикрепляемую к лиду
+type Booking struct {
+	ID     string
+	Branch string
+	Lesson string
+	Time   time.Time
+	// LeadName называет лид беседы без заголовка
+	LeadName string
+}
+
+func (b Booking) String() string {
+	text := fmt.Sprintf("%s, %s, студия «%s»", b.Lesson, b.Time.Format("02.01.2006 15:04"), b.Branch)
+	if b.ID != "" {
+		text += ", запись #" + b.ID
+	}
+	return text
+}
+
+// SendBookingToAMO creates a lead for a trial lesson booking.
+func SendBookingToAMO(repo *repository.Repository, info *conversation.ChatInfo, link string, booking Booking) error {
+	return defaultClient.SendBookingToAMO(repo, info, link, booking)
+}
+
+// SendBookingToAMO создает лид с записью на пробное занятие: добавляет теги
+// AMO_BOOKING_TAGS, филиал записи и описание записи в поле AMO_BOOKING_FIELD_ID
+func (c *AmoClient) SendBookingToAMO(repo *repository.Repository, info *conversation.ChatInfo, link string, booking Booking) error {
+	return c.sendLead(repo, info, link, &booking)
+}
+
+func (c *AmoClient) sendLead(repo *repository.Repository, info *conversation.ChatInfo, link string, booking *Booking) error {
 	if config.Config.AmoDomain == "" || config.Config.AmoAccessToken == "" {
 		log.Println("AMO integration not configured")
 		return nil
@@ -129,7 +162,7 @@ func (c *AmoClient) SendLeadToAMO(repo *repository.Repository, info *conversatio
 	lowerSummary := strings.ToLower(info.Summary.String)
 	for branch, _ := range amoConfig.branchFieldValuesMap {
 		lowerBranch := strings.ToLower(branch)
-		if strings.Contains(lowerSummary, lowerBranch) {
+		if strings.Contains(lowerSummary, lowerBranch) || (booking != nil && strings.EqualFold(booking.Branch, branch)) {
 			branches = append(branches, branch)
 		}
 	}
@@ -137,6 +170,14 @@ func (c *AmoClient) SendLeadToAMO(repo *repository.Repository, info *conversatio
 	// Generate dynamic tags based on conversation content
 	dynamicTags := c.generateDynamicTags(info)
 
+	leadName := info.Title.String
+	if booking != nil {
+		dynamicTags = append(dynamicTags, amoConfig.bookingTags...)
+		if leadName == "" {
+			leadName = booking.LeadName
+		}
+	}
+
 	var cont *savedContact
 	var err error
 	if info.AmoContactID.Valid {
@@ -151,7 +192,17 @@ func (c *AmoClient) SendLeadToAMO(repo *repository.Repository, info *conversatio
 	}
 
 	// Create lead
-	_, err = c.createLead(ctx, cont, info.Title.String, info.Summary.String, info.Interest.String, link, branches, dynamicTags)
+	l := buildLead(leadName, cont, info.Summary.String, info.Interest.String, link, branches, dynamicTags)
+	if booking != nil && amoConfig.bookingFieldId != 0 {
+		l.CustomFieldsValues = append(l.CustomFieldsValues, cf{
+			FieldId: amoConfig.bookingFieldId,
+			Values:  []value{{Value: booking.String()}},
+		})
+	}
+	if amoConfig.utmFieldsEnabled {
+		l.CustomFieldsValues = append(l.CustomFieldsValues, utmFields(info)...)
+	}
+	_, err = c.createLead(ctx, l)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to create a lead: %s", err)
 		return errors.New(errMsg)
@@ -186,11 +237,10 @@ func (c *AmoClient) createContact(ctx context.Context, name, phone string) (*sav
 	return &contactResp.Embedded.Contacts[0], nil
 }
 
-func (c *AmoClient) createLead(ctx context.Context, cont *savedContact, leadName, summary, interest, link string, branches []string, dynamicTags []string) (*http.Response, error) {
-	lead := buildLead(leadName, cont, summary, interest, link, branches, dynamicTags)
+func (c *AmoClient) createLead(ctx context.Context, l *lead) (*http.Response, error) {
 	url := fmt.Sprintf(leadsComplexEndpoint, config.Config.AmoDomain)
 
-	return c.makeJSONRequest(ctx, url, []any{lead})
+	return c.makeJSONRequest(ctx, url, []any{l})
 }
 
 func (c *AmoClient) makeJSONRequest(ctx context.Context, url string, payload any) (*http.Response, error) {
@@ -260,6 +310,28 @@ func (c *AmoClient) generateDynamicTags(info *conversation.ChatInfo) []string {
 	return tags
 }
 
+// utmFields fills the standard amoCRM lead tracking fields with the UTM tags
+// of the landing visit the conversation started from.
+func utmFields(info *conversation.ChatInfo) []cf {
+	tags := []struct {
+		code  string
+		value sql.NullString
+	}{
+		{"UTM_SOURCE", info.UtmSource},
+		{"UTM_MEDIUM", info.UtmMedium},
+		{"UTM_CAMPAIGN", info.UtmCampaign},
+		{"UTM_CONTENT", info.UtmContent},
+		{"UTM_TERM", info.UtmTerm},
+	}
+	fields := make([]cf, 0, len(tags))
+	for _, t := range tags {
+		if t.value.Valid && t.value.String != "" {
+			fields = append(fields, cf{FieldCode: t.code, Values: []value{{Value: t.value.String}}})
+		}
+	}
+	return fields
+}
+
 func buildContact(name, phone string) *contact {
 	return &contact{
 		Name: name,
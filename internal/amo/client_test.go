@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"ragbot/internal/config"
 	"ragbot/internal/conversation"
@@ -190,3 +191,86 @@ func TestSendLeadWithTags(t *testing.T) {
 		t.Fatalf("request body should contain interest tag: %s", string(body))
 	}
 }
+
+func TestSendBookingAttachesBooking(t *testing.T) {
+	repo := newTestRepo(t)
+	client := &AmoClient{HTTPClient: &fakeHTTPClient{}}
+	config.Config = &config.AppConfig{AmoDomain: "example.com", AmoAccessToken: "token"}
+	t.Setenv("AMO_BOOKING_FIELD_ID", "555")
+
+	info := conversation.ChatInfo{
+		ChatID:       4,
+		Name:         sql.NullString{String: "Анна", Valid: true},
+		Phone:        sql.NullString{String: "+79990000000", Valid: true},
+		AmoContactID: sql.NullInt64{Int64: 321, Valid: true},
+	}
+	booking := Booking{
+		ID:     "b-42",
+		Branch: "Центр",
+		Lesson: "Хип-хоп",
+		Time:   time.Date(2026, 10, 19, 18, 0, 0, 0, time.Local),
+		// Название лида задаёт бот из каталога сообщений
+		LeadName: "Пробное занятие: Хип-хоп",
+	}
+	fhc := client.HTTPClient.(*fakeHTTPClient)
+	if err := client.SendBookingToAMO(repo, &info, "link", booking); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fhc.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(fhc.requests))
+	}
+	body, err := io.ReadAll(fhc.requests[0].Body)
+	if err != nil {
+		t.Fatalf("failed to read request body: %v", err)
+	}
+	for _, want := range []string{
+		`"name":"Пробное занятие: Хип-хоп"`,
+		`"Пробное занятие"`,
+		`"field_id":555`,
+		`"value":"Хип-хоп, 19.10.2026 18:00, студия «Центр», запись #b-42"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("request body should contain %s: %s", want, body)
+		}
+	}
+}
+
+func TestSendLeadWithUTM(t *testing.T) {
+	repo := newTestRepo(t)
+	client := &AmoClient{HTTPClient: &fakeHTTPClient{}}
+	config.Config = &config.AppConfig{AmoDomain: "example.com", AmoAccessToken: "token"}
+
+	info := conversation.ChatInfo{
+		ChatID:       5,
+		Name:         sql.NullString{String: "Анна", Valid: true},
+		Phone:        sql.NullString{String: "+79990000000", Valid: true},
+		AmoContactID: sql.NullInt64{Int64: 321, Valid: true},
+		UtmSource:    sql.NullString{String: "vk", Valid: true},
+		UtmCampaign:  sql.NullString{String: "autumn", Valid: true},
+		UtmTerm:      sql.NullString{Valid: true},
+	}
+	fhc := client.HTTPClient.(*fakeHTTPClient)
+	if err := client.SendLeadToAMO(repo, &info, "link"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fhc.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(fhc.requests))
+	}
+	body, err := io.ReadAll(fhc.requests[0].Body)
+	if err != nil {
+		t.Fatalf("failed to read request body: %v", err)
+	}
+	for _, want := range []string{
+		`{"field_code":"UTM_SOURCE","values":[{"value":"vk"}]}`,
+		`{"field_code":"UTM_CAMPAIGN","values":[{"value":"autumn"}]}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("request body should contain %s: %s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"UTM_MEDIUM", "UTM_TERM"} {
+		if strings.Contains(string(body), unwanted) {
+			t.Errorf("request body should not contain %s: %s", unwanted, body)
+		}
+	}
+}
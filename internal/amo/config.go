@@ -14,6 +14,9 @@ type ac struct {
 	tags                 []string
 	dynamicTagsEnabled   bool
 	keywordTagsMap       map[string][]string
+	bookingFieldId       int
+	bookingTags          []string
+	utmFieldsEnabled     bool
 }
 
 var amoConfig *ac
@@ -31,6 +34,9 @@ func loadConfig() {
 		tags:                 util.GetEnvStringSlice("AMO_LEAD_TAGS", []string{}),
 		dynamicTagsEnabled:   util.GetEnvBool("AMO_DYNAMIC_TAGS_ENABLED", true),
 		keywordTagsMap:       loadKeywordTagsMap(),
+		bookingFieldId:       util.GetEnvInt("AMO_BOOKING_FIELD_ID", 0),
+		bookingTags:          util.GetEnvStringSlice("AMO_BOOKING_TAGS", []string{"Пробное занятие"}),
+		utmFieldsEnabled:     util.GetEnvBool("AMO_UTM_FIELDS_ENABLED", true),
 	}
 }
 
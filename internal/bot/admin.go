@@ -6,71 +6,90 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/access"
+	"ragbot/internal/ai"
 	"ragbot/internal/config"
+	"ragbot/internal/i18n"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
 )
 
 const source = "admin"
 
-var adminChats []int64
 var adminBot *tgbotapi.BotAPI
+var adminAIClient *ai.AIClient
 
 // StartAdminBot launches Telegram bot for knowledge base administration.
-func StartAdminBot(repo *repository.Repository, token string, allowedIDs []int64) {
+// The bot answers the chats of the admin accounts.
+func StartAdminBot(repo *repository.Repository, ac *ai.AIClient, token string) {
 	defer util.Recover("StartAdminBot")
 
+	if err := reloadAdmins(repo); err != nil {
+		log.Printf("Load admins error: %v", err)
+	}
+	adminAIClient = ac
 	adminBot = connect(token)
 	log.Println("Admin bot connected to Telegram API")
 
 	registerAdminCommands()
-	handleAdminUpdates(repo, allowedIDs)
+	handleAdminUpdates(repo)
 }
 
-func handleAdminUpdates(repo *repository.Repository, allowedIDs []int64) {
-	adminChats = allowedIDs
-	allowed := make(map[int64]bool)
-	for _, id := range allowedIDs {
-		allowed[id] = true
-	}
-
+func handleAdminUpdates(repo *repository.Repository) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := adminBot.GetUpdatesChan(u)
 
 	log.Println("Admin bot started")
 	for update := range updates {
+		if update.CallbackQuery != nil {
+			handleAdminCallback(repo, update.CallbackQuery)
+			continue
+		}
 		if update.Message == nil {
 			continue
 		}
-		handleAdminMessage(repo, update, allowed)
+		handleAdminMessage(repo, update)
 	}
 }
 
-func handleAdminMessage(repo *repository.Repository, update tgbotapi.Update, allowed map[int64]bool) bool {
+func handleAdminMessage(repo *repository.Repository, update tgbotapi.Update) bool {
 	chatID := update.Message.Chat.ID
 
-	if !allowed[chatID] {
+	if _, ok := adminRole(chatID); !ok {
 		return true
 	}
 
 	if handleAdminCommand(repo, update, chatID) {
 		return true
 	}
+	if update.Message.Document != nil {
+		if requirePermission(chatID, access.EditKnowledge) {
+			handleImportDocument(repo, chatID, update.Message.Document)
+		}
+		return true
+	}
 
 	text := strings.TrimSpace(update.Message.Text)
+	if text != "" && (handleFixInput(repo, chatID, text) || handleListInput(repo, chatID, text) || handleBroadcastInput(repo, chatID, text)) {
+		return true
+	}
+	if !requirePermission(chatID, access.EditKnowledge) {
+		return true
+	}
 	content := strings.Trim(text, " ")
-	id, err := repo.AddChunk(context.Background(), content, source)
+	id, err := repo.AddChunk(adminContext(chatID), content, source)
 	if err != nil {
-		replyToAdmin(chatID, fmt.Sprintf(msgAdminAddError, content))
+		replyToAdmin(chatID, adminText(msgAdminAddError, i18n.Vars{"Content": content}))
 		return true
 	}
 	if id != 0 {
-		SendToAllAdmins(fmt.Sprintf(msgAdminAdded, id, content))
+		SendToAllAdmins(adminText(msgAdminAdded, i18n.Vars{"ID": id, "Content": content}))
 	} else {
-		replyToAdmin(chatID, msgAdminExists)
+		replyToAdmin(chatID, adminText(msgAdminExists, i18n.Vars{"Content": content}))
 	}
 	return false
 }
@@ -79,62 +98,87 @@ func handleAdminCommand(repo *repository.Repository, update tgbotapi.Update, cha
 	if update.Message.IsCommand() {
 		cmd := update.Message.Command()
 		args := update.Message.CommandArguments()
+		if p, ok := commandPermissions[cmd]; ok && !requirePermission(chatID, p) {
+			return true
+		}
 
 		switch cmd {
 		case "start", "myid":
-			replyToAdmin(chatID, fmt.Sprintf(msgAdminMyIDFormat, chatID))
+			replyToAdmin(chatID, adminText(msgAdminMyIDFormat, i18n.Vars{"ChatID": chatID}))
 			return true
 		case "help":
-			replyToAdmin(chatID, msgAdminHelp)
+			replyToAdmin(chatID, adminText(msgAdminHelp))
 			return true
 		case "delete":
 			idStr := strings.Fields(args)
 			if len(idStr) == 0 {
-				replyToAdmin(chatID, msgAdminInvalidID)
+				replyToAdmin(chatID, adminText(msgAdminInvalidID))
 				return true
 			}
 			id, err := strconv.Atoi(idStr[0])
 			if err != nil {
-				replyToAdmin(chatID, msgAdminInvalidID)
+				replyToAdmin(chatID, adminText(msgAdminInvalidID))
 				return true
 			}
-			content, err := repo.DeleteChunk(context.Background(), id)
+			content, err := repo.DeleteChunk(adminContext(chatID), id)
 			if err != nil {
-				replyToAdmin(chatID, fmt.Sprintf(msgAdminDeleteError, id))
+				replyToAdmin(chatID, adminText(msgAdminDeleteError, i18n.Vars{"ID": id}))
 				return true
 			}
-			replyToAdmin(chatID, fmt.Sprintf(msgAdminDeletedFormat, id, content))
+			replyToAdmin(chatID, adminText(msgAdminDeletedFormat, i18n.Vars{"ID": id, "Content": content}))
 			return true
 		case "update":
 			parts := strings.SplitN(args, " ", 2)
 			if len(parts) < 2 {
-				replyToAdmin(chatID, msgAdminUpdateUsage)
+				replyToAdmin(chatID, adminText(msgAdminUpdateUsage))
 				return true
 			}
 			id, err := strconv.Atoi(parts[0])
 			if err != nil {
-				replyToAdmin(chatID, msgAdminInvalidID)
+				replyToAdmin(chatID, adminText(msgAdminInvalidID))
 				return true
 			}
 			content := parts[1]
-			if err := repo.UpdateChunk(context.Background(), id, content); err != nil {
-				replyToAdmin(chatID, fmt.Sprintf(msgAdminUpdateError, id, content))
+			if err := repo.UpdateChunk(adminContext(chatID), id, content); err != nil {
+				replyToAdmin(chatID, adminText(msgAdminUpdateError, i18n.Vars{"ID": id, "Content": content}))
 				return true
 			}
-			replyToAdmin(chatID, fmt.Sprintf(msgAdminUpdatedFormat, id, content))
+			replyToAdmin(chatID, adminText(msgAdminUpdatedFormat, i18n.Vars{"ID": id, "Content": content}))
 			return true
 		case "list":
-			chunks, err := repo.ListChunksWithoutExtID(context.Background())
-			if err != nil {
-				replyToAdmin(chatID, fmt.Sprintf("Ошибка получения списка: %v", err))
-				return true
-			}
-			var sb strings.Builder
-			for _, c := range chunks {
-				escapedText := tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, c.Content)
-				sb.WriteString(fmt.Sprintf("*%d* %s\n\n", c.ID, escapedText))
+			handleListCommand(repo, chatID, args)
+			return true
+		case "fix":
+			handleFixCommand(repo, chatID, args)
+			return true
+		case "broadcast":
+			handleBroadcastCommand(repo, chatID, args)
+			return true
+		case "search":
+			handleSearchCommand(repo, chatID, args)
+			return true
+		case "ask":
+			handleAskCommand(repo, chatID, args)
+			return true
+		case "export":
+			handleExportCommand(repo, chatID, args)
+			return true
+		case "history":
+			handleHistoryCommand(repo, chatID, args)
+			return true
+		case "admins":
+			handleAdminsCommand(chatID)
+			return true
+		case "admin":
+			handleAdminAccountCommand(repo, chatID, update.Message.MessageID, args)
+			return true
+		case "password":
+			handlePasswordCommand(repo, chatID, update.Message.MessageID, args)
+			return true
+		case "cancel":
+			if !cancelBroadcastDraft(chatID) && !cancelListEdit(chatID) && !cancelImport(chatID) {
+				cancelFix(chatID)
 			}
-			replyToAdminMarkdownV2(chatID, sb.String())
 			return true
 		case "stats":
 			url := fmt.Sprintf("%s/stats", config.Config.BaseURL)
@@ -149,15 +193,53 @@ func handleAdminCommand(repo *repository.Repository, update tgbotapi.Update, cha
 	return false
 }
 
+func handleAdminCallback(repo *repository.Repository, cq *tgbotapi.CallbackQuery) {
+	defer util.Recover("handleAdminCallback")
+
+	if cq.Message == nil {
+		return
+	}
+	if _, ok := adminRole(cq.Message.Chat.ID); !ok {
+		return
+	}
+	if _, err := adminBot.Request(tgbotapi.NewCallback(cq.ID, "")); err != nil {
+		log.Printf("Callback answer error: %v", err)
+	}
+	if !requirePermission(cq.Message.Chat.ID, callbackPermission(cq.Data)) {
+		return
+	}
+	switch {
+	case strings.HasPrefix(cq.Data, actionFixPrefix):
+		handleFixCallback(repo, cq.Message.Chat.ID, cq.Message.MessageID, cq.Data)
+	case strings.HasPrefix(cq.Data, actionBroadcastPrefix):
+		handleBroadcastCallback(repo, cq.Message.Chat.ID, cq.Message.MessageID, cq.Data)
+	case strings.HasPrefix(cq.Data, actionListPrefix):
+		handleListCallback(repo, cq.Message.Chat.ID, cq.Message.MessageID, cq.Data)
+	case strings.HasPrefix(cq.Data, actionImportPrefix):
+		handleImportCallback(repo, cq.Message.Chat.ID, cq.Message.MessageID, cq.Data)
+	case strings.HasPrefix(cq.Data, actionRevisionPrefix):
+		handleRevisionCallback(repo, cq.Message.Chat.ID, cq.Data)
+	}
+}
+
 func registerAdminCommands() {
 	commands := []tgbotapi.BotCommand{
-		{Command: "start", Description: "Получить ваш chat ID"},
-		{Command: "help", Description: "Показать справку по командам"},
-		{Command: "update", Description: "Обновить фрагмент: /update <id> <текст>"},
-		{Command: "delete", Description: "Удалить фрагмент: /delete <id>"},
-		{Command: "list", Description: "Показать все фрагменты"},
-		{Command: "stats", Description: "Открыть статистику"},
-		{Command: "chats", Description: "Открыть список чатов"},
+		{Command: "start", Description: adminText(msgAdminCommandStart)},
+		{Command: "help", Description: adminText(msgAdminCommandHelp)},
+		{Command: "update", Description: adminText(msgAdminCommandUpdate)},
+		{Command: "delete", Description: adminText(msgAdminCommandDelete)},
+		{Command: "list", Description: adminText(msgAdminCommandList)},
+		{Command: "stats", Description: adminText(msgAdminCommandStats)},
+		{Command: "chats", Description: adminText(msgAdminCommandChats)},
+		{Command: "fix", Description: adminText(msgAdminCommandFix)},
+		{Command: "broadcast", Description: adminText(msgAdminCommandCast)},
+		{Command: "search", Description: adminText(msgAdminCommandSearch)},
+		{Command: "ask", Description: adminText(msgAdminCommandAsk)},
+		{Command: "export", Description: adminText(msgAdminCommandExport)},
+		{Command: "history", Description: adminText(msgAdminCommandHist)},
+		{Command: "password", Description: adminText(msgAdminCommandPass)},
+		{Command: "admins", Description: adminText(msgAdminCommandAdmins)},
+		{Command: "admin", Description: adminText(msgAdminCommandAdmin)},
 	}
 
 	_, err := adminBot.Request(tgbotapi.NewSetMyCommands(commands...))
@@ -166,23 +248,63 @@ func registerAdminCommands() {
 	}
 }
 
+// adminChatsWith returns the admin chats whose role has the permission.
+func adminChatsWith(p access.Permission) []int64 {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	var chats []int64
+	for _, chatID := range adminChats {
+		if access.Allowed(adminRoles[chatID], p) {
+			chats = append(chats, chatID)
+		}
+	}
+	return chats
+}
+
+// SendToAllAdmins sends a message without user data to every admin chat.
 func SendToAllAdmins(message string) {
-	for _, adminChatID := range adminChats {
+	SendToAdminsWith(access.ViewKnowledge, message)
+}
+
+// SendToAdminsWith sends a message to the admin chats whose role has the permission,
+// e.g. access.ViewTranscripts for messages with user contacts or conversations.
+func SendToAdminsWith(p access.Permission, message string) {
+	for _, adminChatID := range adminChatsWith(p) {
 		replyToAdmin(adminChatID, message)
 	}
 }
 
-func replyToAdmin(chatID int64, message string) {
-	msg := tgbotapi.NewMessage(chatID, message)
-	_, err := adminBot.Send(msg)
-	if err != nil {
-		log.Printf("Error sending message: %s", err.Error())
+// AlertTansultantStale notifies admins that Tansultant data is outdated.
+func AlertTansultantStale(name string, age time.Duration, err error) {
+	SendToAllAdmins(adminText(msgAdminTansStale, i18n.Vars{"Name": name, "Age": age.Round(time.Minute), "Error": err}))
+}
+
+// sendFileToAdminsWith uploads a file to the admin chats whose role has the permission.
+func sendFileToAdminsWith(p access.Permission, file tgbotapi.FileBytes, asPhoto bool, caption string) {
+	for _, adminChatID := range adminChatsWith(p) {
+		var msg tgbotapi.Chattable
+		if asPhoto {
+			photo := tgbotapi.NewPhoto(adminChatID, file)
+			photo.Caption = caption
+			msg = photo
+		} else {
+			doc := tgbotapi.NewDocument(adminChatID, file)
+			doc.Caption = caption
+			msg = doc
+		}
+		if _, err := adminBot.Send(msg); err != nil {
+			log.Printf("Error sending file: %s", err.Error())
+		}
 	}
 }
 
-func replyToAdminMarkdownV2(chatID int64, message string) {
+// adminContext records the chunk changes made by the admin under their chat ID.
+func adminContext(chatID int64) context.Context {
+	return repository.WithAuthor(context.Background(), strconv.FormatInt(chatID, 10))
+}
+
+func replyToAdmin(chatID int64, message string) {
 	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
 	_, err := adminBot.Send(msg)
 	if err != nil {
 		log.Printf("Error sending message: %s", err.Error())
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/access"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// Учётные записи администраторов хранятся в таблице admins. Роли чатов
+// кэшируются и перечитываются после каждого изменения через /admin.
+
+// adminAccounts holds all admin accounts, adminRoles their roles by chat ID
+// and adminChats the chats to notify. reloadAdmins replaces them at runtime,
+// so they are read only under stateMu.
+var (
+	adminAccounts []repository.Admin
+	adminRoles    = make(map[int64]string)
+	adminChats    []int64
+)
+
+// commandPermissions lists the permissions of admin commands. Commands
+// missing here are available to every admin.
+var commandPermissions = map[string]access.Permission{
+	"list":      access.ViewKnowledge,
+	"search":    access.ViewKnowledge,
+	"ask":       access.ViewKnowledge,
+	"export":    access.ViewKnowledge,
+	"history":   access.ViewKnowledge,
+	"stats":     access.ViewKnowledge,
+	"update":    access.EditKnowledge,
+	"delete":    access.EditKnowledge,
+	"fix":       access.EditKnowledge,
+	"chats":     access.ViewTranscripts,
+	"broadcast": access.Broadcast,
+	"admins":    access.ManageSettings,
+	"admin":     access.ManageSettings,
+}
+
+// callbackPermission returns the permission needed for an admin bot button.
+func callbackPermission(data string) access.Permission {
+	switch {
+	case strings.HasPrefix(data, actionListPage):
+		return access.ViewKnowledge
+	case strings.HasPrefix(data, actionBroadcastPrefix):
+		return access.Broadcast
+	default:
+		// Правка фрагментов из /fix и /list, импорт и восстановление версий
+		return access.EditKnowledge
+	}
+}
+
+// reloadAdmins reads the admin accounts and the chats to notify.
+func reloadAdmins(repo *repository.Repository) error {
+	admins, err := repo.ListAdmins(context.Background())
+	if err != nil {
+		return err
+	}
+	roles := make(map[int64]string)
+	var chats []int64
+	for _, a := range admins {
+		if a.ChatID.Valid {
+			roles[a.ChatID.Int64] = a.Role
+			chats = append(chats, a.ChatID.Int64)
+		}
+	}
+	stateMu.Lock()
+	adminAccounts, adminRoles, adminChats = admins, roles, chats
+	stateMu.Unlock()
+	return nil
+}
+
+// adminRole returns the role of the chat and whether the chat is an admin.
+func adminRole(chatID int64) (string, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	role, ok := adminRoles[chatID]
+	return role, ok
+}
+
+// requirePermission reports whether the admin may do the action and tells them otherwise.
+func requirePermission(chatID int64, p access.Permission) bool {
+	role, _ := adminRole(chatID)
+	if access.Allowed(role, p) {
+		return true
+	}
+	replyToAdmin(chatID, adminText(msgAdminDenied, i18n.Vars{"Role": role, "Required": access.RequiredRole(p)}))
+	return false
+}
+
+// parseAdminRef reads an account reference: a chat ID or a web login.
+func parseAdminRef(s string) repository.AdminRef {
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return repository.AdminRef{ChatID: id}
+	}
+	return repository.AdminRef{Login: s}
+}
+
+// isLastOwner reports whether the account is the only owner left.
+func isLastOwner(ref repository.AdminRef) bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	owners, target := 0, false
+	for _, a := range adminAccounts {
+		if a.Role != access.RoleOwner {
+			continue
+		}
+		owners++
+		if (ref.Login != "" && a.Login.String == ref.Login) || (ref.Login == "" && a.ChatID.Valid && a.ChatID.Int64 == ref.ChatID) {
+			target = true
+		}
+	}
+	return target && owners == 1
+}
+
+// handleAdminsCommand lists the admin accounts.
+func handleAdminsCommand(chatID int64) {
+	stateMu.Lock()
+	admins := adminAccounts
+	stateMu.Unlock()
+
+	var sb strings.Builder
+	for _, a := range admins {
+		sb.WriteString(adminText(msgAdminAccountsItem, i18n.Vars{
+			"ID":          a.ID,
+			"Role":        a.Role,
+			"ChatID":      a.ChatID.Int64,
+			"Login":       a.Login.String,
+			"HasPassword": a.PasswordHash != "",
+		}))
+	}
+	replyToAdmin(chatID, adminText(msgAdminAccounts, i18n.Vars{"Items": sb.String()}))
+}
+
+// handleAdminAccountCommand changes admin accounts:
+// /admin set <chat_id|login> <role>, /admin link <chat_id> <login>,
+// /admin password <chat_id|login> <password>, /admin remove <chat_id|login>.
+func handleAdminAccountCommand(repo *repository.Repository, chatID int64, messageID int, args string) {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		replyToAdmin(chatID, adminText(msgAdminAccountUsage, i18n.Vars{"Roles": strings.Join(access.Roles, ", ")}))
+		return
+	}
+	ctx := context.Background()
+	ref := parseAdminRef(fields[1])
+	vars := i18n.Vars{"Account": fields[1]}
+	var reply string
+	var err error
+	switch {
+	case fields[0] == "set" && len(fields) == 3:
+		role := strings.ToLower(fields[2])
+		if !access.ValidRole(role) {
+			replyToAdmin(chatID, adminText(msgAdminAccountUsage, i18n.Vars{"Roles": strings.Join(access.Roles, ", ")}))
+			return
+		}
+		if role != access.RoleOwner && isLastOwner(ref) {
+			replyToAdmin(chatID, adminText(msgAdminLastOwner))
+			return
+		}
+		var created bool
+		created, err = repo.SetAdminRole(ctx, ref, role)
+		vars["Role"], vars["Created"] = role, created
+		reply = adminText(msgAdminAccountRole, vars)
+	case fields[0] == "link" && len(fields) == 3 && ref.Login == "":
+		err = repo.LinkAdminLogin(ctx, ref.ChatID, fields[2])
+		vars["Login"] = fields[2]
+		reply = adminText(msgAdminAccountLinked, vars)
+	case fields[0] == "password" && len(fields) == 3:
+		// Сообщение с паролем не должно оставаться в переписке
+		deleteAdminMessage(chatID, messageID)
+		var hash string
+		if hash, err = access.HashPassword(fields[2]); err == nil {
+			err = repo.SetAdminPassword(ctx, ref, hash)
+		}
+		reply = adminText(msgAdminAccountPass, vars)
+	case fields[0] == "remove" && len(fields) == 2:
+		if isLastOwner(ref) {
+			replyToAdmin(chatID, adminText(msgAdminLastOwner))
+			return
+		}
+		err = repo.DeleteAdmin(ctx, ref)
+		reply = adminText(msgAdminAccountDel, vars)
+	default:
+		replyToAdmin(chatID, adminText(msgAdminAccountUsage, i18n.Vars{"Roles": strings.Join(access.Roles, ", ")}))
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		replyToAdmin(chatID, adminText(msgAdminAccountNone, vars))
+		return
+	}
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminAccountError, i18n.Vars{"Error": err}))
+		return
+	}
+	if err := reloadAdmins(repo); err != nil {
+		log.Printf("Reload admins error: %v", err)
+	}
+	replyToAdmin(chatID, reply)
+}
+
+// handlePasswordCommand sets the web password of the admin's own account: /password <password>.
+func handlePasswordCommand(repo *repository.Repository, chatID int64, messageID int, args string) {
+	password := strings.TrimSpace(args)
+	if password == "" {
+		replyToAdmin(chatID, adminText(msgAdminPasswordUsage))
+		return
+	}
+	deleteAdminMessage(chatID, messageID)
+
+	ctx := context.Background()
+	account, err := repo.GetAdmin(ctx, repository.AdminRef{ChatID: chatID})
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminAccountError, i18n.Vars{"Error": err}))
+		return
+	}
+	if !account.Login.Valid {
+		replyToAdmin(chatID, adminText(msgAdminPasswordLogin))
+		return
+	}
+	hash, err := access.HashPassword(password)
+	if err == nil {
+		err = repo.SetAdminPassword(ctx, repository.AdminRef{ChatID: chatID}, hash)
+	}
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminAccountError, i18n.Vars{"Error": err}))
+		return
+	}
+	if err := reloadAdmins(repo); err != nil {
+		log.Printf("Reload admins error: %v", err)
+	}
+	replyToAdmin(chatID, adminText(msgAdminPasswordSet, i18n.Vars{"Login": account.Login.String}))
+}
+
+func deleteAdminMessage(chatID int64, messageID int) {
+	if _, err := adminBot.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+		log.Printf("Error deleting message: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"testing"
+
+	"ragbot/internal/access"
+)
+
+func TestParseAdminRef(t *testing.T) {
+	if ref := parseAdminRef("123456789"); ref.ChatID != 123456789 || ref.Login != "" {
+		t.Errorf("numeric reference must be a chat ID, got %+v", ref)
+	}
+	if ref := parseAdminRef("anna"); ref.Login != "anna" || ref.ChatID != 0 {
+		t.Errorf("other references must be a login, got %+v", ref)
+	}
+}
+
+func TestCallbackPermission(t *testing.T) {
+	cases := map[string]access.Permission{
+		actionListPage + "2":        access.ViewKnowledge,
+		actionListDelete + "5_0":    access.EditKnowledge,
+		actionImportConfirm + "1":   access.EditKnowledge,
+		actionRevisionRestore + "7": access.EditKnowledge,
+		actionBroadcastSend + "3":   access.Broadcast,
+	}
+	for data, want := range cases {
+		if got := callbackPermission(data); got != want {
+			t.Errorf("callbackPermission(%q) = %d, want %d", data, got, want)
+		}
+	}
+}
+
+func TestAdminChatsWith(t *testing.T) {
+	stateMu.Lock()
+	adminRoles = map[int64]string{1: access.RoleViewer, 2: access.RoleManager, 3: access.RoleOwner}
+	adminChats = []int64{1, 2, 3}
+	stateMu.Unlock()
+	defer func() {
+		stateMu.Lock()
+		adminRoles, adminChats = make(map[int64]string), nil
+		stateMu.Unlock()
+	}()
+
+	if got := adminChatsWith(access.ViewKnowledge); len(got) != 3 {
+		t.Errorf("every admin must get knowledge notifications, got %v", got)
+	}
+	if got := adminChatsWith(access.ViewTranscripts); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("only managers and owners must get user data, got %v", got)
+	}
+}
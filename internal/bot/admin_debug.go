@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// /search и /ask показывают администратору то же, что видит модель:
+// найденные фрагменты с расстояниями и промпт ответа.
+
+const (
+	maxSearchLimit = 10
+	// messageLimit is the maximum length of a Telegram message
+	messageLimit = 4096
+)
+
+// handleSearchCommand replies with the chunks closest to the query: /search [k] <query>.
+func handleSearchCommand(repo *repository.Repository, chatID int64, args string) {
+	limit, query := handler.FragmentsLimit, strings.TrimSpace(args)
+	if fields := strings.Fields(query); len(fields) > 1 {
+		if k, err := strconv.Atoi(fields[0]); err == nil && k > 0 {
+			limit = min(k, maxSearchLimit)
+			query = strings.TrimSpace(strings.TrimPrefix(query, fields[0]))
+		}
+	}
+	if query == "" {
+		replyToAdmin(chatID, adminText(msgAdminSearchUsage, i18n.Vars{"Limit": handler.FragmentsLimit}))
+		return
+	}
+
+	vec, err := adminAIClient.GenerateEmbedding(query)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminSearchError, i18n.Vars{"Error": err}))
+		return
+	}
+	matches, err := repo.SearchChunkMatches(context.Background(), vec, limit)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminSearchError, i18n.Vars{"Error": err}))
+		return
+	}
+	if len(matches) == 0 {
+		replyToAdmin(chatID, adminText(msgAdminSearchEmpty, i18n.Vars{"Query": query}))
+		return
+	}
+	replyToAdmin(chatID, truncateText(adminText(msgAdminSearchTitle, i18n.Vars{"Query": query})+matchesText(matches), messageLimit-1))
+}
+
+// handleAskCommand answers the question without chat history and shows
+// the chunks and the prompt of the answer.
+func handleAskCommand(repo *repository.Repository, chatID int64, args string) {
+	question := strings.TrimSpace(args)
+	if question == "" {
+		replyToAdmin(chatID, adminText(msgAdminAskUsage))
+		return
+	}
+	answer, err := handler.ProcessQuestionWithSources(repo, adminAIClient, 0, i18n.DefaultLocale(), question)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminAskError, i18n.Vars{"Error": err}))
+		return
+	}
+	replyToAdmin(chatID, adminText(msgAdminAskResult, i18n.Vars{
+		"Question": question,
+		"Answer":   truncateText(answer.Text, fixAnswerLimit),
+		"Sources":  matchesText(answer.Chunks),
+	}))
+
+	prompt := adminText(msgAdminAskPrompt, i18n.Vars{"Prompt": answer.Prompt})
+	if utf8.RuneCountInString(prompt) < messageLimit {
+		replyToAdmin(chatID, prompt)
+		return
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "prompt.txt", Bytes: []byte(answer.Prompt)})
+	doc.Caption = adminText(msgAdminAskFile)
+	if _, err := adminBot.Send(doc); err != nil {
+		log.Printf("Error sending prompt: %v", err)
+	}
+}
+
+func matchesText(matches []repository.ChunkMatch) string {
+	var sb strings.Builder
+	for _, m := range matches {
+		sb.WriteString(adminText(msgAdminSearchItem, i18n.Vars{
+			"ID":       m.ID,
+			"Source":   m.Source,
+			"Distance": m.Distance,
+			"Content":  truncateText(m.Content, fixContentLimit),
+		}))
+	}
+	return sb.String()
+}
@@ -0,0 +1,335 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/access"
+	"ragbot/internal/config"
+	"ragbot/internal/embedding"
+	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// Разбор неудачного ответа: администратор видит вопрос, ответ и найденные
+// фрагменты, исправляет фрагмент или добавляет новый и перезапускает вопрос.
+const (
+	// FIX_<historyID> открывает разбор, FIX_E_<historyID>_<chunkID> — правку фрагмента,
+	// FIX_A_, FIX_R_ и FIX_C_ с <historyID> — добавление, перезапуск и завершение.
+	actionFixPrefix = "FIX_"
+	actionFixEdit   = "FIX_E_"
+	actionFixAdd    = "FIX_A_"
+	actionFixRerun  = "FIX_R_"
+	actionFixClose  = "FIX_C_"
+
+	fixAnswerLimit  = 1500
+	fixContentLimit = 300
+)
+
+// Разбор ждёт от администратора текст исправленного или нового фрагмента.
+const (
+	fixStageEdit = iota + 1
+	fixStageAdd
+)
+
+// fixSession is an admin's correction waiting for a chunk text.
+type fixSession struct {
+	HistoryID int64
+	Stage     int
+	ChunkID   int
+}
+
+var fixSessions = make(map[int64]*fixSession)
+
+// offerAnswerFix notifies admins about a downvoted answer with a button to review it.
+func offerAnswerFix(historyID int64) {
+	if adminBot == nil {
+		return
+	}
+	review, err := repo.GetAnswerReview(context.Background(), historyID)
+	if err != nil {
+		log.Printf("Failed loading answer #%d: %v", historyID, err)
+		return
+	}
+	text := adminText(msgAdminFixOffer, i18n.Vars{
+		"ID":       historyID,
+		"Question": review.Question.String,
+		"Answer":   truncateText(review.Answer, fixAnswerLimit),
+	})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminFixOfferBtn), fmt.Sprintf("%s%d", actionFixPrefix, historyID)),
+	))
+	// Вопрос и ответ — часть переписки пользователя
+	for _, adminChatID := range adminChatsWith(access.ViewTranscripts) {
+		msg := tgbotapi.NewMessage(adminChatID, text)
+		msg.ReplyMarkup = keyboard
+		if _, err := adminBot.Send(msg); err != nil {
+			log.Printf("Error sending message: %s", err.Error())
+		}
+	}
+}
+
+// handleFixCommand opens the review of an answer by its history ID: /fix <id>.
+func handleFixCommand(repo *repository.Repository, chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		replyToAdmin(chatID, adminText(msgAdminFixUsage))
+		return
+	}
+	historyID, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "#"), 10, 64)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminFixUsage))
+		return
+	}
+	showAnswerReview(repo, chatID, historyID)
+}
+
+func showAnswerReview(repo *repository.Repository, chatID int64, historyID int64) {
+	review, err := repo.GetAnswerReview(context.Background(), historyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		replyToAdmin(chatID, adminText(msgAdminFixNotFound, i18n.Vars{"ID": historyID}))
+		return
+	}
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminFixError, i18n.Vars{"Error": err}))
+		return
+	}
+
+	var sources strings.Builder
+	var chunkIDs []int
+	if len(review.Sources) == 0 {
+		sources.WriteString(adminText(msgAdminFixNoSources))
+	} else {
+		sources.WriteString(adminText(msgAdminFixSources))
+	}
+	for _, s := range review.Sources {
+		if !s.Content.Valid {
+			sources.WriteString(adminText(msgAdminFixDeleted, i18n.Vars{"ID": s.ChunkID}))
+			continue
+		}
+		chunkIDs = append(chunkIDs, s.ChunkID)
+		sources.WriteString(adminText(msgAdminFixSource, i18n.Vars{
+			"ID":       s.ChunkID,
+			"Distance": s.Distance.Float64,
+			"Content":  truncateText(s.Content.String, fixContentLimit),
+		}))
+	}
+
+	text := adminText(msgAdminFixCard, i18n.Vars{
+		"ID":       historyID,
+		"Rating":   review.Rating,
+		"Question": review.Question.String,
+		"Answer":   truncateText(review.Answer, fixAnswerLimit),
+		"Comment":  review.Comment.String,
+		"Sources":  sources.String(),
+		"Link":     fmt.Sprintf(chatUrlFormat, config.Config.BaseURL, review.ChatUUID) + fmt.Sprintf("#m%d", historyID),
+	})
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = fixKeyboard(historyID, chunkIDs)
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
+
+func fixKeyboard(historyID int64, chunkIDs []int) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, id := range chunkIDs {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			adminText(msgAdminFixEditButton, i18n.Vars{"ID": id}),
+			fmt.Sprintf("%s%d_%d", actionFixEdit, historyID, id),
+		))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminFixAddButton), fmt.Sprintf("%s%d", actionFixAdd, historyID)),
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminFixRerunBtn), fmt.Sprintf("%s%d", actionFixRerun, historyID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminFixCloseBtn), fmt.Sprintf("%s%d", actionFixClose, historyID)),
+		),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleFixCallback processes FIX_* buttons of the admin bot.
+func handleFixCallback(repo *repository.Repository, chatID int64, messageID int, data string) {
+	switch {
+	case strings.HasPrefix(data, actionFixEdit):
+		idPart, chunkPart, _ := strings.Cut(strings.TrimPrefix(data, actionFixEdit), "_")
+		historyID, err1 := strconv.ParseInt(idPart, 10, 64)
+		chunkID, err2 := strconv.Atoi(chunkPart)
+		if err1 != nil || err2 != nil {
+			log.Printf("Invalid fix callback: %s", data)
+			return
+		}
+		chunk, err := repo.GetChunk(context.Background(), chunkID)
+		if err != nil {
+			replyToAdmin(chatID, adminText(msgAdminFixError, i18n.Vars{"Error": err}))
+			return
+		}
+		stateMu.Lock()
+		fixSessions[chatID] = &fixSession{HistoryID: historyID, Stage: fixStageEdit, ChunkID: chunkID}
+		stateMu.Unlock()
+		replyToAdmin(chatID, adminText(msgAdminFixEditPrompt, i18n.Vars{"ID": chunkID, "Content": chunk.Content}))
+	case strings.HasPrefix(data, actionFixAdd):
+		historyID, ok := parseFixID(data, actionFixAdd)
+		if !ok {
+			return
+		}
+		stateMu.Lock()
+		fixSessions[chatID] = &fixSession{HistoryID: historyID, Stage: fixStageAdd}
+		stateMu.Unlock()
+		replyToAdmin(chatID, adminText(msgAdminFixAddPrompt))
+	case strings.HasPrefix(data, actionFixRerun):
+		if historyID, ok := parseFixID(data, actionFixRerun); ok {
+			rerunAnswer(repo, chatID, historyID)
+		}
+	case strings.HasPrefix(data, actionFixClose):
+		historyID, ok := parseFixID(data, actionFixClose)
+		if !ok {
+			return
+		}
+		stateMu.Lock()
+		delete(fixSessions, chatID)
+		stateMu.Unlock()
+		if _, err := adminBot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})); err != nil {
+			log.Printf("Error removing fix buttons: %v", err)
+		}
+		replyToAdmin(chatID, adminText(msgAdminFixClosed, i18n.Vars{"ID": historyID}))
+	default:
+		if historyID, ok := parseFixID(data, actionFixPrefix); ok {
+			showAnswerReview(repo, chatID, historyID)
+		}
+	}
+}
+
+func parseFixID(data, prefix string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(data, prefix), 10, 64)
+	if err != nil {
+		log.Printf("Invalid fix callback: %s", data)
+		return 0, false
+	}
+	return id, true
+}
+
+// handleFixInput saves the text sent by an admin during a correction.
+// It reports whether the message was consumed by the correction workflow.
+func handleFixInput(repo *repository.Repository, chatID int64, text string) bool {
+	stateMu.Lock()
+	session, ok := fixSessions[chatID]
+	delete(fixSessions, chatID)
+	stateMu.Unlock()
+	if !ok {
+		return false
+	}
+	ctx := adminContext(chatID)
+	chunkID := session.ChunkID
+	switch session.Stage {
+	case fixStageEdit:
+		if err := repo.UpdateChunk(ctx, chunkID, text); err != nil {
+			replyToAdmin(chatID, adminText(msgAdminUpdateError, i18n.Vars{"ID": chunkID, "Content": text}))
+			return true
+		}
+	case fixStageAdd:
+		id, err := repo.AddChunk(ctx, text, source)
+		if err != nil {
+			replyToAdmin(chatID, adminText(msgAdminAddError, i18n.Vars{"Content": text}))
+			return true
+		}
+		if id == 0 {
+			replyToAdmin(chatID, adminText(msgAdminExists, i18n.Vars{"Content": text}))
+			return true
+		}
+		chunkID = id
+		// Новый фрагмент не меняет старые, поэтому ответы из кэша по тем же фрагментам сбрасываются явно
+		if err := repo.InvalidateCachedAnswersForHistory(ctx, session.HistoryID); err != nil {
+			log.Printf("Failed invalidating answer cache for #%d: %v", session.HistoryID, err)
+		}
+	default:
+		return false
+	}
+
+	// Фрагмент векторизуется сразу, чтобы перезапуск вопроса учитывал исправление
+	var reply string
+	if err := embedding.EmbedChunk(ctx, repo, adminAIClient, chunkID, text); err != nil {
+		log.Printf("Failed embedding chunk #%d: %v", chunkID, err)
+		reply = adminText(msgAdminFixEmbedError, i18n.Vars{"ID": chunkID, "Error": err})
+	} else {
+		reply = adminText(msgAdminFixSaved, i18n.Vars{"ID": chunkID, "Content": text})
+	}
+	msg := tgbotapi.NewMessage(chatID, reply)
+	msg.ReplyMarkup = fixKeyboard(session.HistoryID, []int{chunkID})
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+	return true
+}
+
+// cancelFix drops a pending correction of the admin.
+func cancelFix(chatID int64) {
+	stateMu.Lock()
+	delete(fixSessions, chatID)
+	stateMu.Unlock()
+	replyToAdmin(chatID, adminText(msgAdminFixCanceled))
+}
+
+// rerunAnswer asks the original question again against the current knowledge base.
+// The chat history is not used, so the answer depends only on the fragments.
+func rerunAnswer(repo *repository.Repository, chatID int64, historyID int64) {
+	review, err := repo.GetAnswerReview(context.Background(), historyID)
+	if err != nil || !review.Question.Valid {
+		replyToAdmin(chatID, adminText(msgAdminFixNotFound, i18n.Vars{"ID": historyID}))
+		return
+	}
+	adminBot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+	answer, err := handler.ProcessQuestionWithSources(repo, adminAIClient, 0, i18n.DefaultLocale(), review.Question.String)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminFixError, i18n.Vars{"Error": err}))
+		return
+	}
+
+	var sources strings.Builder
+	sources.WriteString(adminText(msgAdminFixSources))
+	chunkIDs := make([]int, 0, len(answer.Chunks))
+	for _, c := range answer.Chunks {
+		chunkIDs = append(chunkIDs, c.ID)
+		sources.WriteString(adminText(msgAdminFixSource, i18n.Vars{
+			"ID":       c.ID,
+			"Distance": c.Distance,
+			"Content":  truncateText(c.Content, fixContentLimit),
+		}))
+	}
+	msg := tgbotapi.NewMessage(chatID, adminText(msgAdminFixRerun, i18n.Vars{
+		"Question": review.Question.String,
+		"Answer":   truncateText(answer.Text, fixAnswerLimit),
+		"Sources":  sources.String(),
+	}))
+	msg.ReplyMarkup = fixKeyboard(historyID, chunkIDs)
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
+
+// truncateText shortens text to limit runes so that messages fit Telegram limits.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// История фрагментов: /history показывает последние изменения базы знаний,
+// /history <id> — изменения одного фрагмента с кнопками восстановления версии.
+const (
+	// REV_R_<revisionID> возвращает фрагмент к версии этого изменения
+	actionRevisionPrefix  = "REV_"
+	actionRevisionRestore = "REV_R_"
+
+	historyLimit = 10
+)
+
+// handleHistoryCommand replies with the latest changes of a chunk or of the whole knowledge base.
+func handleHistoryCommand(repo *repository.Repository, chatID int64, args string) {
+	var revs []repository.ChunkRevision
+	var err error
+	title := adminText(msgAdminHistoryRecent)
+	if fields := strings.Fields(args); len(fields) > 0 {
+		id, convErr := strconv.Atoi(fields[0])
+		if convErr != nil {
+			replyToAdmin(chatID, adminText(msgAdminHistoryUsage))
+			return
+		}
+		title = adminText(msgAdminHistoryTitle, i18n.Vars{"ID": id})
+		revs, err = repo.ListChunkRevisions(context.Background(), id, historyLimit)
+	} else {
+		revs, err = repo.ListRecentRevisions(context.Background(), historyLimit)
+	}
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminHistoryError, i18n.Vars{"Error": err}))
+		return
+	}
+	if len(revs) == 0 {
+		replyToAdmin(chatID, adminText(msgAdminHistoryEmpty))
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, rev := range revs {
+		sb.WriteString(adminText(msgAdminHistoryItem, i18n.Vars{
+			"Revision": rev.ID,
+			"ChunkID":  rev.ChunkID,
+			"Action":   rev.Action,
+			"Author":   rev.Author,
+			"Time":     rev.CreatedAt.Format("02.01.2006 15:04"),
+			"Content":  truncateText(rev.Content(), fixContentLimit),
+		}))
+		if rev.Content() != "" && rev.ExtID == "" {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				adminText(msgAdminHistoryBtn, i18n.Vars{"Revision": rev.ID, "ChunkID": rev.ChunkID}),
+				fmt.Sprintf("%s%d", actionRevisionRestore, rev.ID),
+			)))
+		}
+	}
+	msg := tgbotapi.NewMessage(chatID, truncateText(title+sb.String(), messageLimit-1))
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
+
+// handleRevisionCallback restores the chunk version chosen in the history.
+func handleRevisionCallback(repo *repository.Repository, chatID int64, data string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(data, actionRevisionRestore), 10, 64)
+	if err != nil || !strings.HasPrefix(data, actionRevisionRestore) {
+		log.Printf("Invalid revision callback: %s", data)
+		return
+	}
+	rev, err := repo.RestoreChunkRevision(adminContext(chatID), id)
+	if errors.Is(err, repository.ErrSyncedChunk) {
+		replyToAdmin(chatID, adminText(msgAdminRestoreSynced, i18n.Vars{"ChunkID": rev.ChunkID, "Source": rev.Source}))
+		return
+	}
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminRestoreError, i18n.Vars{"Revision": id, "Error": err}))
+		return
+	}
+	SendToAllAdmins(adminText(msgAdminRestored, i18n.Vars{"ChunkID": rev.ChunkID, "Revision": id, "Content": rev.Content()}))
+}
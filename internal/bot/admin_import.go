@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
+	"ragbot/internal/knowledge"
+	"ragbot/internal/repository"
+)
+
+// Импорт: документ .txt/.md/.csv/.jsonl -> предпросмотр -> подтверждение.
+// /export выгружает базу знаний в тех же форматах, так что выгрузку можно
+// поправить и загрузить обратно.
+const (
+	// IMP_Y_<draftID> и IMP_N_<draftID> подтверждают и отменяют импорт
+	actionImportPrefix  = "IMP_"
+	actionImportConfirm = "IMP_Y_"
+	actionImportCancel  = "IMP_N_"
+
+	maxImportSize = 5 << 20
+)
+
+// importDraft is an import plan an admin has not confirmed yet. The ID ties
+// the plan to the buttons of its preview.
+type importDraft struct {
+	ID   int
+	Plan knowledge.ImportPlan
+}
+
+// importDrafts holds the pending import of each admin, lastImportID numbers the drafts.
+var (
+	importDrafts = make(map[int64]importDraft)
+	lastImportID int
+)
+
+// handleImportDocument parses a document sent to the admin bot and shows what it would change.
+func handleImportDocument(repo *repository.Repository, chatID int64, doc *tgbotapi.Document) {
+	format, ok := knowledge.FormatFromName(doc.FileName)
+	if !ok {
+		replyToAdmin(chatID, adminText(msgAdminImportFormat, i18n.Vars{"Formats": strings.Join(knowledge.Formats, ", ")}))
+		return
+	}
+	if doc.FileSize > maxImportSize {
+		replyToAdmin(chatID, adminText(msgAdminImportLarge, i18n.Vars{"Limit": maxImportSize >> 20}))
+		return
+	}
+	body, err := openBotFile(adminBot, doc.FileID)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminImportError, i18n.Vars{"Error": err}))
+		return
+	}
+	defer body.Close()
+	records, err := knowledge.ParseRecords(format, io.LimitReader(body, maxImportSize))
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminImportError, i18n.Vars{"Error": err}))
+		return
+	}
+	existing, err := repo.ListAllChunks(context.Background(), "")
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminImportError, i18n.Vars{"Error": err}))
+		return
+	}
+
+	plan := knowledge.PlanImport(records, existing)
+	vars := i18n.Vars{"File": doc.FileName, "New": len(plan.New), "Updated": len(plan.Updated), "Duplicates": plan.Duplicates}
+	if len(plan.New) == 0 && len(plan.Updated) == 0 {
+		replyToAdmin(chatID, adminText(msgAdminImportNothing, vars))
+		return
+	}
+	stateMu.Lock()
+	lastImportID++
+	id := lastImportID
+	importDrafts[chatID] = importDraft{ID: id, Plan: plan}
+	stateMu.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, adminText(msgAdminImportPreview, vars))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminImportYesBtn), fmt.Sprintf("%s%d", actionImportConfirm, id)),
+		tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminImportNoBtn), fmt.Sprintf("%s%d", actionImportCancel, id)),
+	))
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
+
+// handleImportCallback applies or drops the pending import of the admin.
+// Buttons of an older preview do not touch the pending import.
+func handleImportCallback(repo *repository.Repository, chatID int64, messageID int, data string) {
+	confirm := strings.HasPrefix(data, actionImportConfirm)
+	idPart := strings.TrimPrefix(strings.TrimPrefix(data, actionImportConfirm), actionImportCancel)
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		log.Printf("Invalid import callback: %s", data)
+		return
+	}
+
+	stateMu.Lock()
+	draft, ok := importDrafts[chatID]
+	ok = ok && draft.ID == id
+	if ok {
+		delete(importDrafts, chatID)
+	}
+	stateMu.Unlock()
+
+	// Кнопки убираются, чтобы импорт нельзя было подтвердить дважды
+	if _, err := adminBot.Request(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})); err != nil {
+		log.Printf("Error removing import buttons: %v", err)
+	}
+	if !ok {
+		replyToAdmin(chatID, adminText(msgAdminImportExpired))
+		return
+	}
+	if !confirm {
+		replyToAdmin(chatID, adminText(msgAdminImportCancel))
+		return
+	}
+
+	plan := draft.Plan
+	ctx := adminContext(chatID)
+	added, updated, duplicates, failed := 0, 0, plan.Duplicates, 0
+	for _, rec := range plan.New {
+		src := rec.Source
+		if src == "" {
+			src = source
+		}
+		id, err := repo.AddChunk(ctx, rec.Content, src)
+		switch {
+		case err != nil:
+			log.Printf("Import: add chunk error: %v", err)
+			failed++
+		case id == 0:
+			duplicates++
+		default:
+			added++
+		}
+	}
+	for _, rec := range plan.Updated {
+		if err := repo.UpdateChunk(ctx, rec.ID, rec.Content); err != nil {
+			log.Printf("Import: update chunk %d error: %v", rec.ID, err)
+			failed++
+			continue
+		}
+		updated++
+	}
+	replyToAdmin(chatID, adminText(msgAdminImportDone, i18n.Vars{
+		"New": added, "Updated": updated, "Duplicates": duplicates, "Failed": failed,
+	}))
+}
+
+// cancelImport drops a pending import of the admin and reports whether there was one.
+func cancelImport(chatID int64) bool {
+	stateMu.Lock()
+	_, ok := importDrafts[chatID]
+	delete(importDrafts, chatID)
+	stateMu.Unlock()
+	if ok {
+		replyToAdmin(chatID, adminText(msgAdminImportCancel))
+	}
+	return ok
+}
+
+// handleExportCommand sends the knowledge base as a file: /export [format] [source=<source>].
+func handleExportCommand(repo *repository.Repository, chatID int64, args string) {
+	format, src := knowledge.FormatCSV, ""
+	for _, field := range strings.Fields(args) {
+		if value, ok := strings.CutPrefix(field, "source="); ok && value != "" {
+			src = value
+			continue
+		}
+		f, ok := knowledge.ExportFormatFromName("." + field)
+		if !ok {
+			replyToAdmin(chatID, adminText(msgAdminExportUsage, i18n.Vars{"Formats": strings.Join(knowledge.ExportFormats, "|")}))
+			return
+		}
+		format = f
+	}
+
+	items, err := repo.ListAllChunks(context.Background(), src)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminExportError, i18n.Vars{"Error": err}))
+		return
+	}
+	if len(items) == 0 {
+		replyToAdmin(chatID, adminText(msgAdminExportEmpty))
+		return
+	}
+	records := make([]knowledge.Record, 0, len(items))
+	for _, c := range items {
+		records = append(records, knowledge.Record{ID: c.ID, Source: c.Source, Content: c.Content})
+	}
+	var buf bytes.Buffer
+	if err := knowledge.WriteRecords(format, &buf, records); err != nil {
+		replyToAdmin(chatID, adminText(msgAdminExportError, i18n.Vars{"Error": err}))
+		return
+	}
+
+	name := "knowledge"
+	if src != "" {
+		name += "-" + strings.NewReplacer("/", "_", "\\", "_").Replace(src)
+	}
+	name += "-" + time.Now().Format("2006-01-02") + "." + format
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: buf.Bytes()})
+	doc.Caption = adminText(msgAdminExportCaption, i18n.Vars{"Count": len(records)})
+	if _, err := adminBot.Send(doc); err != nil {
+		log.Printf("Error sending export: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// Список фрагментов базы знаний по страницам. Фильтр запоминается для чата
+// администратора, поэтому кнопки содержат только страницу или ID фрагмента.
+const (
+	// LIST_P_<page> открывает страницу, LIST_E_<chunkID> — правку фрагмента,
+	// LIST_D_<chunkID>_<page> удаляет фрагмент и обновляет страницу.
+	actionListPrefix = "LIST_"
+	actionListPage   = "LIST_P_"
+	actionListEdit   = "LIST_E_"
+	actionListDelete = "LIST_D_"
+
+	listPageSize = 10
+)
+
+var (
+	listFilters = make(map[int64]repository.ChunkFilter)
+	// listEdits holds the chunk an admin is editing from the list
+	listEdits = make(map[int64]int)
+)
+
+// parseChunkFilter reads /list arguments: source=<source>, status=ready|pending
+// and the rest as a text to search for.
+func parseChunkFilter(args string) (repository.ChunkFilter, error) {
+	var f repository.ChunkFilter
+	var text []string
+	for _, field := range strings.Fields(args) {
+		key, value, ok := strings.Cut(field, "=")
+		switch {
+		case ok && strings.EqualFold(key, "source"):
+			if value == "" {
+				return f, fmt.Errorf("empty source")
+			}
+			f.Source = value
+		case ok && strings.EqualFold(key, "status"):
+			switch value = strings.ToLower(value); value {
+			case repository.ChunkStatusReady, repository.ChunkStatusPending:
+				f.Status = value
+			default:
+				return f, fmt.Errorf("invalid status %q", value)
+			}
+		default:
+			text = append(text, field)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, nil
+}
+
+func describeChunkFilter(f repository.ChunkFilter) string {
+	var parts []string
+	if f.Source != "" {
+		parts = append(parts, "source="+f.Source)
+	}
+	if f.Status != "" {
+		parts = append(parts, "status="+f.Status)
+	}
+	if f.Text != "" {
+		parts = append(parts, "«"+f.Text+"»")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// handleListCommand shows the first page of chunks matching the filter.
+func handleListCommand(repo *repository.Repository, chatID int64, args string) {
+	f, err := parseChunkFilter(args)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminListUsage))
+		return
+	}
+	stateMu.Lock()
+	listFilters[chatID] = f
+	stateMu.Unlock()
+
+	text, keyboard, err := chunkListPage(repo, f, 0)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminListError, i18n.Vars{"Error": err}))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
+
+// chunkListPage renders the page of chunks with edit, delete and navigation buttons.
+func chunkListPage(repo *repository.Repository, f repository.ChunkFilter, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	items, total, err := repo.ListChunks(context.Background(), f, page*listPageSize, listPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	pages := (total + listPageSize - 1) / listPageSize
+	if len(items) == 0 && page > 0 && pages > 0 {
+		// Страница исчезла после удаления, показываем последнюю
+		return chunkListPage(repo, f, pages-1)
+	}
+	if len(items) == 0 {
+		return adminText(msgAdminListEmpty), nil, nil
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, c := range items {
+		sb.WriteString(adminText(msgAdminListItem, i18n.Vars{
+			"ID":        c.ID,
+			"Source":    c.Source,
+			"Processed": c.Processed,
+			"Content":   truncateText(c.Content, fixContentLimit),
+		}))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminListEditBtn, i18n.Vars{"ID": c.ID}), fmt.Sprintf("%s%d", actionListEdit, c.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminListDeleteBtn, i18n.Vars{"ID": c.ID}), fmt.Sprintf("%s%d_%d", actionListDelete, c.ID, page)),
+		))
+	}
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminListPrevBtn), fmt.Sprintf("%s%d", actionListPage, page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+			adminText(msgAdminListPageBtn, i18n.Vars{"Page": page + 1, "Pages": pages}),
+			fmt.Sprintf("%s%d", actionListPage, page),
+		))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminListNextBtn), fmt.Sprintf("%s%d", actionListPage, page+1)))
+		}
+		rows = append(rows, nav)
+	}
+	text := adminText(msgAdminListPage, i18n.Vars{
+		"From":   page*listPageSize + 1,
+		"To":     page*listPageSize + len(items),
+		"Total":  total,
+		"Filter": describeChunkFilter(f),
+		"Items":  sb.String(),
+	})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return text, &keyboard, nil
+}
+
+// showChunkListPage replaces the list message with another page.
+func showChunkListPage(repo *repository.Repository, chatID int64, messageID int, page int) {
+	stateMu.Lock()
+	f := listFilters[chatID]
+	stateMu.Unlock()
+	text, keyboard, err := chunkListPage(repo, f, page)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminListError, i18n.Vars{"Error": err}))
+		return
+	}
+	var edit tgbotapi.EditMessageTextConfig
+	if keyboard != nil {
+		edit = tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, *keyboard)
+	} else {
+		edit = tgbotapi.NewEditMessageText(chatID, messageID, text)
+	}
+	if _, err := adminBot.Request(edit); err != nil {
+		log.Printf("Error editing chunk list: %v", err)
+	}
+}
+
+// handleListCallback processes LIST_* buttons of the admin bot.
+func handleListCallback(repo *repository.Repository, chatID int64, messageID int, data string) {
+	switch {
+	case strings.HasPrefix(data, actionListPage):
+		page, err := strconv.Atoi(strings.TrimPrefix(data, actionListPage))
+		if err != nil || page < 0 {
+			log.Printf("Invalid list callback: %s", data)
+			return
+		}
+		showChunkListPage(repo, chatID, messageID, page)
+	case strings.HasPrefix(data, actionListEdit):
+		id, err := strconv.Atoi(strings.TrimPrefix(data, actionListEdit))
+		if err != nil {
+			log.Printf("Invalid list callback: %s", data)
+			return
+		}
+		chunk, err := repo.GetChunk(context.Background(), id)
+		if err != nil {
+			replyToAdmin(chatID, adminText(msgAdminListError, i18n.Vars{"Error": err}))
+			return
+		}
+		stateMu.Lock()
+		listEdits[chatID] = id
+		stateMu.Unlock()
+		replyToAdmin(chatID, adminText(msgAdminFixEditPrompt, i18n.Vars{"ID": id, "Content": chunk.Content}))
+	case strings.HasPrefix(data, actionListDelete):
+		idPart, pagePart, _ := strings.Cut(strings.TrimPrefix(data, actionListDelete), "_")
+		id, err1 := strconv.Atoi(idPart)
+		page, err2 := strconv.Atoi(pagePart)
+		if err1 != nil || err2 != nil {
+			log.Printf("Invalid list callback: %s", data)
+			return
+		}
+		content, err := repo.DeleteChunk(adminContext(chatID), id)
+		if err != nil {
+			replyToAdmin(chatID, adminText(msgAdminDeleteError, i18n.Vars{"ID": id}))
+			return
+		}
+		replyToAdmin(chatID, adminText(msgAdminDeletedFormat, i18n.Vars{"ID": id, "Content": content}))
+		showChunkListPage(repo, chatID, messageID, page)
+	}
+}
+
+// handleListInput saves the new text of a chunk edited from the list.
+// It reports whether the message was consumed as such a text.
+func handleListInput(repo *repository.Repository, chatID int64, text string) bool {
+	stateMu.Lock()
+	id, ok := listEdits[chatID]
+	delete(listEdits, chatID)
+	stateMu.Unlock()
+	if !ok {
+		return false
+	}
+	if err := repo.UpdateChunk(adminContext(chatID), id, text); err != nil {
+		replyToAdmin(chatID, adminText(msgAdminUpdateError, i18n.Vars{"ID": id, "Content": text}))
+		return true
+	}
+	replyToAdmin(chatID, adminText(msgAdminUpdatedFormat, i18n.Vars{"ID": id, "Content": text}))
+	return true
+}
+
+// cancelListEdit drops a pending chunk edit of the admin and reports whether there was one.
+func cancelListEdit(chatID int64) bool {
+	stateMu.Lock()
+	_, ok := listEdits[chatID]
+	delete(listEdits, chatID)
+	stateMu.Unlock()
+	if ok {
+		replyToAdmin(chatID, adminText(msgAdminListCanceled))
+	}
+	return ok
+}
@@ -0,0 +1,23 @@
+package bot
+
+import "testing"
+
+func TestParseChunkFilter(t *testing.T) {
+	f, err := parseChunkFilter("source=file status=Pending абонемент на месяц")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Source != "file" || f.Status != "pending" || f.Text != "абонемент на месяц" {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+
+	if f, err = parseChunkFilter(""); err != nil || f.Source != "" || f.Status != "" || f.Text != "" {
+		t.Fatalf("empty arguments should list everything, got %+v, %v", f, err)
+	}
+
+	for _, args := range []string{"status=done", "source="} {
+		if _, err := parseChunkFilter(args); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/access"
+	"ragbot/internal/amo"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+	"ragbot/internal/tansultant"
+)
+
+// Запись на пробное занятие: филиал → занятие → имя и телефон → подтверждение.
+const (
+	bookingStageName = iota + 1
+	bookingStagePhone
+	bookingStageConfirm
+)
+
+const (
+	// BOOK_B_<branchID> показывает занятия филиала, BOOK_L_<n> выбирает
+	// n-е из показанных занятий: ID занятия не помещается в 64 байта данных кнопки.
+	actionBookPrefix       = "BOOK_"
+	actionBookBranchPrefix = "BOOK_B_"
+	actionBookLessonPrefix = "BOOK_L_"
+	actionBookConfirm      = "BOOK_YES"
+	actionBookEdit         = "BOOK_EDIT"
+	actionBookCancel       = "BOOK_CANCEL"
+
+	bookingDays       = 7
+	bookingMaxLessons = 10
+)
+
+type bookingState struct {
+	Stage  int
+	Branch tansultant.Branch
+	Lesson tansultant.Lesson
+}
+
+var bookingSteps = make(map[int64]*bookingState)
+
+// bookingOffer is the list of lessons last shown to the chat.
+type bookingOffer struct {
+	BranchID  int
+	LessonIDs []string
+}
+
+var bookingOffers = make(map[int64]bookingOffer)
+
+func bookingFor(chatID int64) (*bookingState, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, ok := bookingSteps[chatID]
+	return st, ok
+}
+
+// startBooking shows the list of branches to book a trial class in.
+func startBooking(chatID int64) {
+	if tansClient == nil || !tansClient.BookingConfigured() || !tansClient.ScheduleConfigured() {
+		replyToUser(chatID, localize(chatID, msgBookingUnavailable))
+		engine.OfferCallback(chat(chatID))
+		return
+	}
+	branches, err := tansClient.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		log.Printf("Failed retrieving branches for booking: %v", err)
+		replyToUser(chatID, localize(chatID, msgBookingUnavailable))
+		engine.OfferCallback(chat(chatID))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, localize(chatID, msgBookingChooseBranch))
+	msg.ReplyMarkup = bookingBranchesKeyboard(branches)
+	userBot.Send(msg)
+}
+
+// handleBookingCallback processes BOOK_* callbacks.
+func handleBookingCallback(chatID int64, messageID int, data string) {
+	switch {
+	case data == actionBookConfirm:
+		deleteMessage(chatID, messageID)
+		finalizeBooking(chatID)
+	case data == actionBookEdit:
+		deleteMessage(chatID, messageID)
+		stateMu.Lock()
+		if st, ok := bookingSteps[chatID]; ok {
+			st.Stage = bookingStageName
+		}
+		stateMu.Unlock()
+		replyToUser(chatID, localize(chatID, msgAskName))
+	case data == actionBookCancel:
+		deleteMessage(chatID, messageID)
+		stateMu.Lock()
+		delete(bookingSteps, chatID)
+		stateMu.Unlock()
+		replyToUser(chatID, localize(chatID, msgBookingCanceled))
+	case strings.HasPrefix(data, actionBookBranchPrefix):
+		branchID, err := strconv.Atoi(strings.TrimPrefix(data, actionBookBranchPrefix))
+		if err != nil {
+			log.Printf("Invalid booking callback: %s", data)
+			return
+		}
+		showBookingLessons(chatID, messageID, branchID, msgBookingChooseLesson)
+	case strings.HasPrefix(data, actionBookLessonPrefix):
+		n, err := strconv.Atoi(strings.TrimPrefix(data, actionBookLessonPrefix))
+		if err != nil {
+			log.Printf("Invalid booking callback: %s", data)
+			return
+		}
+		stateMu.Lock()
+		offer, ok := bookingOffers[chatID]
+		stateMu.Unlock()
+		if !ok || n < 0 || n >= len(offer.LessonIDs) {
+			// Список занятий устарел, например после перезапуска бота
+			deleteMessage(chatID, messageID)
+			startBooking(chatID)
+			return
+		}
+		selectBookingLesson(chatID, messageID, offer.BranchID, offer.LessonIDs[n])
+	default:
+		log.Printf("Unknown CallbackQuery data: %s", data)
+	}
+}
+
+// bookableLessons returns upcoming lessons of the branch open for booking.
+func bookableLessons(ctx context.Context, branchID int) ([]tansultant.Lesson, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	lessons, err := tansClient.Schedule(ctx, branchID, from, from.AddDate(0, 0, bookingDays-1))
+	if err != nil {
+		return nil, err
+	}
+	var result []tansultant.Lesson
+	for _, l := range lessons {
+		if l.Canceled() || !l.StartsAt.After(now) {
+			continue
+		}
+		result = append(result, l)
+	}
+	return result, nil
+}
+
+func findBranch(ctx context.Context, branchID int) (tansultant.Branch, bool) {
+	branches, err := tansClient.Branches(ctx)
+	if err != nil {
+		log.Printf("Failed retrieving branches: %v", err)
+		return tansultant.Branch{}, false
+	}
+	for _, b := range branches {
+		if b.ID == branchID {
+			return b, true
+		}
+	}
+	return tansultant.Branch{}, false
+}
+
+func showBookingLessons(chatID int64, messageID int, branchID int, titleKey string) {
+	ctx := context.Background()
+	branch, ok := findBranch(ctx, branchID)
+	if !ok {
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	lessons, err := bookableLessons(ctx, branchID)
+	if err != nil {
+		log.Printf("Failed retrieving lessons for booking: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	if len(lessons) == 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, localize(chatID, msgBookingNoLessons, i18n.Vars{"Title": branch.Title}))
+		userBot.Send(edit)
+		return
+	}
+	if len(lessons) > bookingMaxLessons {
+		lessons = lessons[:bookingMaxLessons]
+	}
+	offer := bookingOffer{BranchID: branchID}
+	for _, l := range lessons {
+		offer.LessonIDs = append(offer.LessonIDs, l.ID)
+	}
+	stateMu.Lock()
+	bookingOffers[chatID] = offer
+	stateMu.Unlock()
+	text := localize(chatID, titleKey, i18n.Vars{"Title": branch.Title})
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, bookingLessonsKeyboard(chatID, lessons))
+	if _, err := userBot.Send(edit); err != nil {
+		log.Printf("Error editing booking message: %v", err)
+	}
+}
+
+func selectBookingLesson(chatID int64, messageID int, branchID int, lessonID string) {
+	ctx := context.Background()
+	branch, ok := findBranch(ctx, branchID)
+	if !ok {
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	lessons, err := bookableLessons(ctx, branchID)
+	if err != nil {
+		log.Printf("Failed retrieving lessons for booking: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	var lesson tansultant.Lesson
+	found := false
+	for _, l := range lessons {
+		if l.ID == lessonID {
+			lesson, found = l, true
+			break
+		}
+	}
+	if !found {
+		showBookingLessons(chatID, messageID, branchID, msgBookingLessonGone)
+		return
+	}
+	stateMu.Lock()
+	delete(bookingOffers, chatID)
+	stateMu.Unlock()
+	deleteMessage(chatID, messageID)
+	conversation.AppendHistory(repo, chatID, "user", fmt.Sprintf(historyBookingFormat, lessonSummary(branch, lesson)))
+
+	st := &bookingState{Stage: bookingStageName, Branch: branch, Lesson: lesson}
+	info, err := conversation.GetChatInfoByChatID(repo, chatID)
+	if err == nil && info.Name.String != "" && info.Phone.String != "" {
+		st.Stage = bookingStageConfirm
+	}
+	stateMu.Lock()
+	bookingSteps[chatID] = st
+	stateMu.Unlock()
+
+	if st.Stage == bookingStageConfirm {
+		userBot.Send(bookingConfirmMessage(chatID, st, info.Name.String, info.Phone.String))
+		return
+	}
+	replyToUser(chatID, localize(chatID, msgAskName))
+}
+
+// handleBookingInput collects name and phone while a booking is in progress.
+// It reports whether the message was consumed by the booking flow.
+func handleBookingInput(chatID int64, userText string) bool {
+	st, ok := bookingFor(chatID)
+	if !ok {
+		return false
+	}
+	switch st.Stage {
+	case bookingStageName:
+		conversation.UpdateName(repo, chatID, userText)
+		stateMu.Lock()
+		st.Stage = bookingStagePhone
+		stateMu.Unlock()
+		replyToUser(chatID, localize(chatID, msgAskPhone))
+		return true
+	case bookingStagePhone:
+		conversation.UpdatePhone(repo, chatID, userText)
+		stateMu.Lock()
+		st.Stage = bookingStageConfirm
+		stateMu.Unlock()
+		info, err := conversation.GetChatInfoByChatID(repo, chatID)
+		if err != nil {
+			log.Printf("Error loading chat info for booking: %v", err)
+			replyToUser(chatID, localize(chatID, msgUserError))
+			return true
+		}
+		userBot.Send(bookingConfirmMessage(chatID, st, info.Name.String, info.Phone.String))
+		return true
+	}
+	return false
+}
+
+func finalizeBooking(chatID int64) {
+	stateMu.Lock()
+	st, ok := bookingSteps[chatID]
+	delete(bookingSteps, chatID)
+	stateMu.Unlock()
+	if !ok {
+		return
+	}
+	info, err := conversation.GetChatInfoByChatID(repo, chatID)
+	if err != nil {
+		log.Printf("Error loading chat info for booking: %v", err)
+		replyToUser(chatID, localize(chatID, msgUserError))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	booking, bookErr := tansClient.Book(ctx, tansultant.BookingRequest{
+		BranchID: st.Branch.ID,
+		LessonID: st.Lesson.ID,
+		Name:     info.Name.String,
+		Phone:    info.Phone.String,
+		Comment:  adminText(msgAdminBookingNote),
+	})
+
+	link := fmt.Sprintf(chatUrlFormat, config.Config.BaseURL, info.ID)
+	amoBooking := amo.Booking{
+		ID:       booking.ID,
+		Branch:   st.Branch.Title,
+		Lesson:   st.Lesson.Name,
+		Time:     st.Lesson.StartsAt.Time,
+		LeadName: adminText(msgAdminBookingLead, i18n.Vars{"Lesson": st.Lesson.Name}),
+	}
+	vars := i18n.Vars{"Name": info.Name.String, "Phone": info.Phone.String, "Booking": amoBooking.String(), "Link": link}
+	if bookErr != nil {
+		log.Printf("Tansultant booking error: %v", bookErr)
+		vars["Error"] = bookErr
+		SendToAdminsWith(access.ViewTranscripts, adminText(msgAdminBookingError, vars))
+		replyToUser(chatID, localize(chatID, msgBookingFailed))
+	} else {
+		conversation.AppendHistory(repo, chatID, "user", repository.HistoryBookingCreated)
+		SendToAdminsWith(access.ViewTranscripts, adminText(msgAdminBooking, vars))
+		locale := localeFor(chatID)
+		replyToUser(chatID, localize(chatID, msgBookingDone, i18n.Vars{
+			"Lesson":  st.Lesson.Name,
+			"Weekday": i18n.Weekday(locale, st.Lesson.StartsAt.Time),
+			"Date":    st.Lesson.StartsAt.Format("02.01"),
+			"Start":   st.Lesson.StartsAt.Format("15:04"),
+			"Branch":  st.Branch.Title,
+			"Address": st.Branch.Address,
+		}))
+	}
+
+	// Лид создаётся и при ошибке записи, чтобы менеджер записал клиента вручную
+	if err := amo.SendBookingToAMO(repo, &info, link, amoBooking); err != nil {
+		log.Printf("Error sending booking to AMO: %v", err)
+		SendToAllAdmins(adminText(msgAdminLeadError, i18n.Vars{"Error": err}))
+	}
+}
+
+func lessonSummary(b tansultant.Branch, l tansultant.Lesson) string {
+	return fmt.Sprintf("%s, %s, %s", l.Name, l.StartsAt.Format("02.01 15:04"), b.Title)
+}
+
+func bookingBranchesKeyboard(branches []tansultant.Branch) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, b := range branches {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(b.Title, fmt.Sprintf("%s%d", actionBookBranchPrefix, b.ID)),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func bookingLessonsKeyboard(chatID int64, lessons []tansultant.Lesson) tgbotapi.InlineKeyboardMarkup {
+	locale := localeFor(chatID)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, l := range lessons {
+		label := localize(chatID, msgBookingLessonButton, i18n.Vars{
+			"Weekday": i18n.Weekday(locale, l.StartsAt.Time),
+			"Date":    l.StartsAt.Format("02.01"),
+			"Start":   l.StartsAt.Format("15:04"),
+			"Name":    l.Name,
+		})
+		data := fmt.Sprintf("%s%d", actionBookLessonPrefix, i)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, data)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func bookingConfirmMessage(chatID int64, st *bookingState, name, phone string) tgbotapi.MessageConfig {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgBookingConfirmButton), actionBookConfirm),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgBookingEditButton), actionBookEdit),
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgBookingCancelButton), actionBookCancel),
+		),
+	)
+	text := localize(chatID, msgBookingConfirm, i18n.Vars{
+		"Lesson":  st.Lesson.Name,
+		"Weekday": i18n.Weekday(localeFor(chatID), st.Lesson.StartsAt.Time),
+		"Date":    st.Lesson.StartsAt.Format("02.01"),
+		"Start":   st.Lesson.StartsAt.Format("15:04"),
+		"Branch":  st.Branch.Title,
+		"Name":    name,
+		"Phone":   phone,
+	})
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	return msg
+}
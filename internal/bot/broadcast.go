@@ -0,0 +1,369 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/config"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+	"ragbot/internal/util"
+)
+
+// Рассылка: /broadcast с условиями -> текст -> предпросмотр -> подтверждение.
+// Получатели фиксируются в broadcast_deliveries при запуске, отправка идёт
+// с ограничением скорости, прогресс обновляется в сообщении администратора.
+const (
+	actionBroadcastSend   = "BC_SEND_"
+	actionBroadcastCancel = "BC_CANCEL_"
+	actionBroadcastStop   = "BC_STOP_"
+	actionBroadcastPrefix = "BC_"
+
+	broadcastBatch            = 100
+	broadcastProgressInterval = 5 * time.Second
+	broadcastSendAttempts     = 3
+)
+
+// broadcastDrafts хранит условия рассылки, для которой администратор ещё не прислал текст.
+var broadcastDrafts = make(map[int64]repository.BroadcastSegment)
+
+var (
+	broadcastTickOnce sync.Once
+	broadcastTick     <-chan time.Time
+)
+
+// parseBroadcastSegment разбирает условия /broadcast: lead, interest=<слово>,
+// since=<ГГГГ-ММ-ДД> или since=<N>d.
+func parseBroadcastSegment(args string, now time.Time) (repository.BroadcastSegment, error) {
+	var s repository.BroadcastSegment
+	for _, field := range strings.Fields(args) {
+		key, value, _ := strings.Cut(field, "=")
+		switch strings.ToLower(key) {
+		case "lead":
+			s.HasLead = true
+		case "interest":
+			if value == "" {
+				return s, fmt.Errorf("empty interest")
+			}
+			s.Interest = value
+		case "since":
+			if days, ok := strings.CutSuffix(value, "d"); ok {
+				n, err := strconv.Atoi(days)
+				if err != nil || n <= 0 {
+					return s, fmt.Errorf("invalid since %q", value)
+				}
+				s.ActiveSince = now.AddDate(0, 0, -n)
+				continue
+			}
+			t, err := time.ParseInLocation("2006-01-02", value, now.Location())
+			if err != nil {
+				return s, fmt.Errorf("invalid since %q", value)
+			}
+			s.ActiveSince = t
+		default:
+			return s, fmt.Errorf("unknown condition %q", field)
+		}
+	}
+	return s, nil
+}
+
+func describeSegment(s repository.BroadcastSegment) string {
+	since := ""
+	if !s.ActiveSince.IsZero() {
+		since = s.ActiveSince.Format("02.01.2006")
+	}
+	return adminText(msgAdminCastSegment, i18n.Vars{
+		"All":      !s.HasLead && s.Interest == "" && since == "",
+		"Lead":     s.HasLead,
+		"Interest": s.Interest,
+		"Since":    since,
+	})
+}
+
+// handleBroadcastCommand starts a broadcast draft: /broadcast [conditions].
+func handleBroadcastCommand(repo *repository.Repository, chatID int64, args string) {
+	segment, err := parseBroadcastSegment(args, time.Now())
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminCastUsage))
+		return
+	}
+	count, err := repo.CountBroadcastRecipients(context.Background(), segment)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+		return
+	}
+	vars := i18n.Vars{"Count": count, "Segment": describeSegment(segment)}
+	if count == 0 {
+		replyToAdmin(chatID, adminText(msgAdminCastEmpty, vars))
+		return
+	}
+	stateMu.Lock()
+	broadcastDrafts[chatID] = segment
+	stateMu.Unlock()
+	replyToAdmin(chatID, adminText(msgAdminCastAskText, vars))
+}
+
+// handleBroadcastInput saves the broadcast text and shows the preview.
+// It reports whether the message was consumed as a broadcast text.
+func handleBroadcastInput(repo *repository.Repository, chatID int64, text string) bool {
+	stateMu.Lock()
+	segment, ok := broadcastDrafts[chatID]
+	delete(broadcastDrafts, chatID)
+	stateMu.Unlock()
+	if !ok {
+		return false
+	}
+	ctx := context.Background()
+	count, err := repo.CountBroadcastRecipients(ctx, segment)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+		return true
+	}
+	id, err := repo.CreateBroadcast(ctx, text, segment, chatID)
+	if err != nil {
+		replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+		return true
+	}
+
+	replyToAdmin(chatID, text+i18n.T(i18n.DefaultLocale(), msgBroadcastFooter))
+	msg := tgbotapi.NewMessage(chatID, adminText(msgAdminCastPreview, i18n.Vars{"Count": count, "Segment": describeSegment(segment)}))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminCastSendBtn), fmt.Sprintf("%s%d", actionBroadcastSend, id)),
+		tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminCastCancelBtn), fmt.Sprintf("%s%d", actionBroadcastCancel, id)),
+	))
+	if _, err := adminBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+	return true
+}
+
+// cancelBroadcastDraft drops a broadcast waiting for its text.
+// It reports whether there was such a draft.
+func cancelBroadcastDraft(chatID int64) bool {
+	stateMu.Lock()
+	_, ok := broadcastDrafts[chatID]
+	delete(broadcastDrafts, chatID)
+	stateMu.Unlock()
+	if ok {
+		replyToAdmin(chatID, adminText(msgAdminCastCanceled))
+	}
+	return ok
+}
+
+// handleBroadcastCallback processes BC_* buttons of the admin bot.
+func handleBroadcastCallback(repo *repository.Repository, chatID int64, messageID int, data string) {
+	var prefix string
+	for _, p := range []string{actionBroadcastSend, actionBroadcastCancel, actionBroadcastStop} {
+		if strings.HasPrefix(data, p) {
+			prefix = p
+		}
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(data, prefix))
+	if prefix == "" || err != nil {
+		log.Printf("Invalid broadcast callback: %s", data)
+		return
+	}
+	ctx := context.Background()
+	removeButtons := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+
+	switch prefix {
+	case actionBroadcastSend:
+		started, err := repo.StartBroadcast(ctx, id)
+		if err != nil {
+			replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+			return
+		}
+		if !started {
+			return
+		}
+		adminBot.Request(removeButtons)
+		go runBroadcast(repo, id, chatID, 0)
+	case actionBroadcastCancel:
+		if err := repo.FinishBroadcast(ctx, id, repository.BroadcastCanceled); err != nil {
+			replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+			return
+		}
+		adminBot.Request(removeButtons)
+		replyToAdmin(chatID, adminText(msgAdminCastCanceled))
+	case actionBroadcastStop:
+		// Отправка остановится перед следующим получателем
+		if err := repo.FinishBroadcast(ctx, id, repository.BroadcastCanceled); err != nil {
+			replyToAdmin(chatID, adminText(msgAdminCastError, i18n.Vars{"Error": err}))
+		}
+	}
+}
+
+// resumeBroadcasts continues broadcasts interrupted by a restart.
+func resumeBroadcasts(repo *repository.Repository) {
+	defer util.Recover("resumeBroadcasts")
+
+	ids, err := repo.ListRunningBroadcasts(context.Background())
+	if err != nil {
+		log.Printf("Failed loading running broadcasts: %v", err)
+		return
+	}
+	for _, id := range ids {
+		b, err := repo.GetBroadcast(context.Background(), id)
+		if err != nil {
+			log.Printf("Failed loading broadcast #%d: %v", id, err)
+			continue
+		}
+		log.Printf("Resuming broadcast #%d", id)
+		runBroadcast(repo, id, b.CreatedBy, 0)
+	}
+}
+
+// runBroadcast sends the broadcast to pending recipients until all are done
+// or the broadcast is stopped. Progress is shown in the admin chat.
+func runBroadcast(repo *repository.Repository, id int, adminChatID int64, progressMessageID int) {
+	defer util.Recover("runBroadcast")
+
+	ctx := context.Background()
+	lastProgress := time.Time{}
+	for {
+		b, err := repo.GetBroadcast(ctx, id)
+		if err != nil {
+			log.Printf("Failed loading broadcast #%d: %v", id, err)
+			return
+		}
+		if b.Status != repository.BroadcastRunning {
+			showBroadcastProgress(adminChatID, &progressMessageID, b, msgAdminCastStopped, false)
+			return
+		}
+		if time.Since(lastProgress) >= broadcastProgressInterval {
+			showBroadcastProgress(adminChatID, &progressMessageID, b, msgAdminCastProgress, true)
+			lastProgress = time.Now()
+		}
+
+		recipients, err := repo.PendingBroadcastRecipients(ctx, id, broadcastBatch)
+		if err != nil {
+			log.Printf("Failed loading broadcast #%d recipients: %v", id, err)
+			return
+		}
+		if len(recipients) == 0 {
+			if err := repo.FinishBroadcast(ctx, id, repository.BroadcastDone); err != nil {
+				log.Printf("Failed finishing broadcast #%d: %v", id, err)
+			}
+			if b, err = repo.GetBroadcast(ctx, id); err == nil {
+				showBroadcastProgress(adminChatID, &progressMessageID, b, msgAdminCastDone, false)
+			}
+			return
+		}
+		for i, chatID := range recipients {
+			// Статус проверяется на каждой десятой отправке, чтобы кнопка «Остановить» срабатывала быстро
+			if i > 0 && i%10 == 0 {
+				if current, err := repo.GetBroadcast(ctx, id); err == nil && current.Status != repository.BroadcastRunning {
+					break
+				}
+			}
+			status, errText := deliverBroadcast(chatID, b.Text)
+			if err := repo.SaveBroadcastDelivery(ctx, id, chatID, status, errText); err != nil {
+				log.Printf("Failed saving broadcast #%d delivery to %d: %v", id, chatID, err)
+				return
+			}
+		}
+	}
+}
+
+// deliverBroadcast sends the text to one chat respecting BROADCAST_RATE
+// and returns the delivery status with the error text.
+func deliverBroadcast(chatID int64, text string) (string, string) {
+	broadcastTickOnce.Do(func() {
+		rate := config.Config.BroadcastRate
+		if rate <= 0 {
+			rate = 25
+		}
+		broadcastTick = time.Tick(time.Second / time.Duration(rate))
+	})
+
+	msg := tgbotapi.NewMessage(chatID, text+localize(chatID, msgBroadcastFooter))
+	var err error
+	for attempt := 0; attempt < broadcastSendAttempts; attempt++ {
+		<-broadcastTick
+		if _, err = userBot.Send(msg); err == nil {
+			return repository.DeliverySent, ""
+		}
+		var tgErr *tgbotapi.Error
+		if !errors.As(err, &tgErr) {
+			break
+		}
+		if tgErr.Code == http.StatusForbidden {
+			return repository.DeliveryBlocked, tgErr.Message
+		}
+		if tgErr.Code != http.StatusTooManyRequests {
+			break
+		}
+		time.Sleep(time.Duration(tgErr.RetryAfter) * time.Second)
+	}
+	return repository.DeliveryFailed, err.Error()
+}
+
+// showBroadcastProgress sends or edits the progress message; the stop button
+// is shown while the broadcast is running.
+func showBroadcastProgress(chatID int64, messageID *int, b repository.Broadcast, key string, running bool) {
+	if adminBot == nil {
+		return
+	}
+	text := adminText(key, i18n.Vars{"ID": b.ID, "Sent": b.Sent, "Total": b.Total, "Failed": b.Failed, "Blocked": b.Blocked})
+	markup := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if running {
+		markup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(adminText(msgAdminCastStopBtn), fmt.Sprintf("%s%d", actionBroadcastStop, b.ID)),
+		))
+	}
+	if *messageID == 0 {
+		msg := tgbotapi.NewMessage(chatID, text)
+		if running {
+			msg.ReplyMarkup = markup
+		}
+		sent, err := adminBot.Send(msg)
+		if err != nil {
+			log.Printf("Error sending message: %s", err.Error())
+			return
+		}
+		*messageID = sent.MessageID
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, *messageID, text, markup)
+	if _, err := adminBot.Send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		log.Printf("Error editing broadcast progress: %v", err)
+	}
+}
+
+// SendFollowUp sends a re-engagement follow-up to a user. A chat that blocked
+// the bot is excluded from further broadcasts and follow-ups.
+func SendFollowUp(chatID int64, text string) error {
+	if userBot == nil {
+		return errors.New("user bot is not connected")
+	}
+	_, err := userBot.Send(tgbotapi.NewMessage(chatID, text+localize(chatID, msgBroadcastFooter)))
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && tgErr.Code == http.StatusForbidden {
+		if err := repo.MarkChatBlocked(context.Background(), chatID); err != nil {
+			log.Printf("Failed marking chat %d blocked: %v", chatID, err)
+		}
+	}
+	return err
+}
+
+// setSubscribed handles /unsubscribe and /subscribe of a user.
+func setSubscribed(chatID int64, subscribed bool) {
+	if err := repo.SetUnsubscribed(context.Background(), chatID, !subscribed); err != nil {
+		log.Printf("Failed updating subscription of %d: %v", chatID, err)
+		replyToUser(chatID, localize(chatID, msgUserError))
+		return
+	}
+	if subscribed {
+		replyToUser(chatID, localize(chatID, msgSubscribed))
+	} else {
+		replyToUser(chatID, localize(chatID, msgUnsubscribed))
+	}
+}
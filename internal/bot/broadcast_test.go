@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBroadcastSegment(t *testing.T) {
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	s, err := parseBroadcastSegment("lead interest=танго since=30d", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !s.HasLead || s.Interest != "танго" || !s.ActiveSince.Equal(now.AddDate(0, 0, -30)) {
+		t.Fatalf("unexpected segment %+v", s)
+	}
+
+	s, err = parseBroadcastSegment("since=2025-01-15", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC); !s.ActiveSince.Equal(want) || s.HasLead {
+		t.Fatalf("unexpected segment %+v", s)
+	}
+
+	if s, err = parseBroadcastSegment("", now); err != nil || s.HasLead || s.Interest != "" || !s.ActiveSince.IsZero() {
+		t.Fatalf("empty conditions should select everyone, got %+v, %v", s, err)
+	}
+
+	for _, args := range []string{"vip", "since=yesterday", "since=0d", "interest="} {
+		if _, err := parseBroadcastSegment(args, now); err == nil {
+			t.Errorf("expected error for %q", args)
+		}
+	}
+}
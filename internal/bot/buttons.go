@@ -2,41 +2,20 @@ package bot
 
 import (
 	"fmt"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
 	"ragbot/internal/tansultant"
 )
 
-func callMeBackButton(chatID int64) tgbotapi.MessageConfig {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(msgCallManagerButton, actionCallManager),
-		),
-	)
-	msg := tgbotapi.NewMessage(chatID, msgCallManagerPrompt)
-	msg.ReplyMarkup = keyboard
-	return msg
-}
-
-func confirmContactButton(chatID int64, name, phone string) tgbotapi.MessageConfig {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(msgConfirmYes, actionConfirmYes),
-			tgbotapi.NewInlineKeyboardButtonData(msgConfirmNo, actionConfirmNo),
-		),
-	)
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(msgConfirmContactFormat, name, phone))
-	msg.ReplyMarkup = keyboard
-	return msg
-}
-
 func statsButton(chatID int64, url string) tgbotapi.MessageConfig {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL(msgStatsButton, url),
+			tgbotapi.NewInlineKeyboardButtonURL(adminText(msgStatsButton), url),
 		),
 	)
-	msg := tgbotapi.NewMessage(chatID, msgStatsPrompt)
+	msg := tgbotapi.NewMessage(chatID, adminText(msgStatsPrompt))
 	msg.ReplyMarkup = keyboard
 	return msg
 }
@@ -44,10 +23,10 @@ func statsButton(chatID int64, url string) tgbotapi.MessageConfig {
 func chatsButton(chatID int64, url string) tgbotapi.MessageConfig {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL(msgChatsButton, url),
+			tgbotapi.NewInlineKeyboardButtonURL(adminText(msgChatsButton), url),
 		),
 	)
-	msg := tgbotapi.NewMessage(chatID, msgChatsPrompt)
+	msg := tgbotapi.NewMessage(chatID, adminText(msgChatsPrompt))
 	msg.ReplyMarkup = keyboard
 	return msg
 }
@@ -59,54 +38,60 @@ func channelButton(chatID int64, channel string) tgbotapi.MessageConfig {
 			tgbotapi.NewInlineKeyboardButtonURL("@"+channel, url),
 		),
 	)
-	msg := tgbotapi.NewMessage(chatID, msgChannelPrompt)
+	msg := tgbotapi.NewMessage(chatID, localize(chatID, msgChannelPrompt))
 	msg.ReplyMarkup = keyboard
 	return msg
 }
 
 func scheduleButtons(chatID int64, branches []tansultant.Branch) tgbotapi.MessageConfig {
-	var rows [][]tgbotapi.InlineKeyboardButton
-	for _, b := range branches {
-		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL(fmt.Sprintf(msgScheduleLinkFormat, b.Title), b.ScheduleLink),
-		))
-	}
-	msg := tgbotapi.NewMessage(chatID, msgScheduleTitle)
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	msg := tgbotapi.NewMessage(chatID, localize(chatID, msgScheduleTitle))
+	msg.ReplyMarkup = scheduleBranchesKeyboard(chatID, branches)
 	return msg
 }
 
-func priceButtons(chatID int64, prices []tansultant.Price) (tgbotapi.MessageConfig, map[string]string) {
+// scheduleBranchesKeyboard opens the timetable of a branch in the chat
+// or, when the schedule API is not configured, its public schedule page.
+func scheduleBranchesKeyboard(chatID int64, branches []tansultant.Branch) tgbotapi.InlineKeyboardMarkup {
+	today := time.Now().Format(time.DateOnly)
 	var rows [][]tgbotapi.InlineKeyboardButton
-	m := make(map[string]string)
-	for i, p := range prices {
-		key := fmt.Sprintf("PRICE_%d", i)
-		properties := ""
-		if p.Hours != "" {
-			properties += fmt.Sprintf(msgPassHoursFormat, p.Hours)
-		}
-		if p.GuestVisits != "" {
-			properties += fmt.Sprintf(msgGuestVisitsFormat, p.GuestVisits)
-		}
-		if p.FreezeAllowed != "" {
-			properties += msgPassFreezeAllowed
-		}
-		if p.Lifetime != "" {
-			properties += fmt.Sprintf(msgPassLifetimeFormat, p.Lifetime)
-		}
-		properties = tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, properties)
-		if p.Price != "" {
-			properties += fmt.Sprintf(msgPriceFormat, p.Price)
+	for _, b := range branches {
+		label := localize(chatID, msgScheduleLinkFormat, i18n.Vars{"Title": b.Title})
+		if tansClient.ScheduleConfigured() {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s%d_%s", actionSchedulePrefix, b.ID, today)),
+			))
+		} else if b.ScheduleLink != "" {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL(label, b.ScheduleLink),
+			))
 		}
-		name := tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, p.Name)
-		description := tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, p.Description)
-		m[key] = fmt.Sprintf(msgPriceDescriptionFormat, name, description, properties)
-		label := fmt.Sprintf(msgPriceButtonFormat, p.Name, p.Price)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// scheduleDayKeyboard navigates between days of the branch timetable.
+func scheduleDayKeyboard(chatID int64, b tansultant.Branch, day time.Time) tgbotapi.InlineKeyboardMarkup {
+	dayData := func(d time.Time) string {
+		return fmt.Sprintf("%s%d_%s", actionSchedulePrefix, b.ID, d.Format(time.DateOnly))
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgSchedulePrev), dayData(day.AddDate(0, 0, -1))),
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgScheduleNext), dayData(day.AddDate(0, 0, 1))),
+		),
+	}
+	if tansClient.BookingConfigured() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgBookingButton), fmt.Sprintf("%s%d", actionBookBranchPrefix, b.ID)),
+		))
+	}
+	if b.ScheduleLink != "" {
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(label, key),
+			tgbotapi.NewInlineKeyboardButtonURL(localize(chatID, msgScheduleFull), b.ScheduleLink),
 		))
 	}
-	msg := tgbotapi.NewMessage(chatID, msgPricesTitle)
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
-	return msg, m
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgScheduleBranches), actionScheduleList),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/conversation"
+	"ragbot/internal/messenger"
+)
+
+const (
+	// FB_UP_<historyID> и FB_DOWN_<historyID> оценивают ответ ассистента.
+	actionFeedbackPrefix = "FB_"
+	actionFeedbackUp     = messenger.ActionFeedbackUp
+	actionFeedbackDown   = messenger.ActionFeedbackDown
+	actionFeedbackDone   = "FB_DONE"
+)
+
+// feedbackPrompt is a question "what was wrong" waiting for a reply.
+type feedbackPrompt struct {
+	HistoryID int64
+	MessageID int
+}
+
+var feedbackPrompts = make(map[int64]feedbackPrompt)
+
+// handleFeedbackCallback stores a rating and replaces the buttons with a thank-you note.
+func handleFeedbackCallback(chatID int64, messageID int, data string) {
+	if data == actionFeedbackDone {
+		return
+	}
+	rating := 1
+	payload := strings.TrimPrefix(data, actionFeedbackUp)
+	if strings.HasPrefix(data, actionFeedbackDown) {
+		rating = -1
+		payload = strings.TrimPrefix(data, actionFeedbackDown)
+	}
+	historyID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Printf("Invalid feedback callback: %s", data)
+		return
+	}
+	if !conversation.SaveFeedback(repo, chatID, historyID, rating) {
+		return
+	}
+
+	thanks := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(localize(chatID, msgFeedbackThanks), actionFeedbackDone),
+		),
+	)
+	if _, err := userBot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, thanks)); err != nil {
+		log.Printf("Error editing feedback buttons: %v", err)
+	}
+	if rating > 0 {
+		return
+	}
+	offerAnswerFix(historyID)
+
+	// Комментарий принимается только ответом на вопрос, чтобы не перепутать его с новым вопросом
+	msg := tgbotapi.NewMessage(chatID, localize(chatID, msgFeedbackAskComment))
+	msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true, Selective: true}
+	sent, err := userBot.Send(msg)
+	if err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+		return
+	}
+	stateMu.Lock()
+	feedbackPrompts[chatID] = feedbackPrompt{HistoryID: historyID, MessageID: sent.MessageID}
+	stateMu.Unlock()
+}
+
+// handleFeedbackComment saves a reply to the "what was wrong" question.
+// It reports whether the message was such a reply.
+func handleFeedbackComment(chatID int64, message *tgbotapi.Message, text string) bool {
+	if message.ReplyToMessage == nil {
+		return false
+	}
+	stateMu.Lock()
+	prompt, ok := feedbackPrompts[chatID]
+	if ok && prompt.MessageID == message.ReplyToMessage.MessageID {
+		delete(feedbackPrompts, chatID)
+	} else {
+		ok = false
+	}
+	stateMu.Unlock()
+	if !ok {
+		return false
+	}
+	conversation.SaveFeedbackComment(repo, chatID, prompt.HistoryID, text)
+	replyToUser(chatID, localize(chatID, msgFeedbackCommented))
+	return true
+}
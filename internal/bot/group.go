@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/util"
+)
+
+// Параметры ссылки ?start= для перехода из группы в личный чат
+const (
+	startPayloadCall = "call"
+	startPayloadBook = "book"
+)
+
+const privateChatURLFormat = "https://t.me/%s?start=%s"
+
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// botUsername returns the user bot name without @ from USER_TELEGRAM_BOT_NAME
+// or from Telegram when it is not configured.
+func botUsername() string {
+	if name := strings.TrimPrefix(config.Config.UserTelegramBotName, "@"); name != "" {
+		return name
+	}
+	if userBot != nil {
+		return userBot.Self.UserName
+	}
+	return ""
+}
+
+// groupRequest returns the text addressed to the bot in a group message.
+// A message is addressed to the bot when it mentions @botName, replies to
+// a bot message or is a command without another bot's name.
+func groupRequest(msg *tgbotapi.Message, botName string, botID int64) (string, bool) {
+	text, entities := msg.Text, msg.Entities
+	if text == "" {
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+	if msg.IsCommand() {
+		_, target, found := strings.Cut(msg.CommandWithAt(), "@")
+		return text, !found || strings.EqualFold(target, botName)
+	}
+
+	// Упоминания берутся из разметки Telegram: смещения в ней считаются
+	// в единицах UTF-16, а @dancebot_helper не упоминает @dancebot
+	units := utf16.Encode([]rune(text))
+	for _, e := range entities {
+		if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(units) {
+			continue
+		}
+		mention := string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+		if (e.Type == "mention" && botName != "" && strings.EqualFold(mention, "@"+botName)) ||
+			(e.Type == "text_mention" && e.User != nil && e.User.ID == botID) {
+			rest := append(utf16.Decode(units[:e.Offset]), utf16.Decode(units[e.Offset+e.Length:])...)
+			return strings.TrimSpace(string(rest)), true
+		}
+	}
+	reply := msg.ReplyToMessage
+	if reply != nil && reply.From != nil && reply.From.ID == botID {
+		return strings.TrimSpace(text), true
+	}
+	return "", false
+}
+
+// privateChatButton sends a prompt with a deep link that opens the private chat
+// with the bot and starts the given flow there.
+func privateChatButton(chatID int64, promptKey, payload string) tgbotapi.MessageConfig {
+	url := fmt.Sprintf(privateChatURLFormat, botUsername(), payload)
+	msg := tgbotapi.NewMessage(chatID, localize(chatID, promptKey))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL(localize(chatID, msgGroupPrivateButton), url),
+	))
+	return msg
+}
+
+// handleGroupMessage answers group and supergroup messages addressed to the bot.
+// The history is kept per participant, contacts are collected only in a private chat.
+func handleGroupMessage(update tgbotapi.Update) {
+	defer util.Recover("handleGroupMessage")
+
+	msg := update.Message
+	chatID := msg.Chat.ID
+	text, ok := groupRequest(msg, botUsername(), userBot.Self.ID)
+	if !ok {
+		return
+	}
+	var userID int64
+	locale := ""
+	if msg.From != nil {
+		userID = msg.From.ID
+		locale = rememberLocale(chatID, msg.From.LanguageCode)
+	}
+	conversation.EnsureSession(repo, chatID, msg.Chat.UserName, locale)
+
+	if msg.IsCommand() {
+		switch msg.Command() {
+		case "call":
+			userBot.Send(privateChatButton(chatID, msgGroupPrivatePrompt, startPayloadCall))
+		case "book":
+			userBot.Send(privateChatButton(chatID, msgGroupBookingPrompt, startPayloadBook))
+		case "start", "unsubscribe", "subscribe":
+		default:
+			handleUserCommand(update, chatID)
+		}
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	if util.ContainsStringFromSlice(strings.ToLower(text), config.Settings.CallManagerTriggerWords) {
+		userBot.Send(privateChatButton(chatID, msgGroupPrivatePrompt, startPayloadCall))
+		return
+	}
+
+	userBot.Request(tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping))
+	result, err := handler.ProcessQuestionForUser(repo, aiClient, chatID, userID, locale, text)
+	if err != nil {
+		SendToAllAdmins(adminText(msgAdminErrorFormat, i18n.Vars{"Error": err}))
+		replyToUser(chatID, localize(chatID, msgUserError))
+		return
+	}
+	conversation.AppendUserHistory(repo, chatID, userID, "user", text)
+	if historyID := conversation.AppendUserHistory(repo, chatID, userID, "assistant", result.Text); historyID != 0 {
+		conversation.SaveAnswerChunks(repo, historyID, result.Chunks)
+	}
+
+	reply := tgbotapi.NewMessage(chatID, result.Text)
+	reply.ReplyToMessageID = msg.MessageID
+	if _, err := userBot.Send(reply); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+	if util.ContainsStringFromSlice(strings.ToLower(result.Text), config.Settings.CallManagerTriggerWordsInAnswer) {
+		userBot.Send(privateChatButton(chatID, msgGroupPrivatePrompt, startPayloadCall))
+	}
+}
+
+// handleStartPayload starts the flow requested by a deep link from a group chat.
+// Any other payload is a landing visit token: the chat is attributed to the visit
+// and the usual greeting follows. It reports whether a flow was started.
+func handleStartPayload(chatID int64, payload string) bool {
+	switch payload {
+	case "":
+		return false
+	case startPayloadCall:
+		engine.OfferCallback(chat(chatID))
+	case startPayloadBook:
+		startBooking(chatID)
+	default:
+		conversation.LinkVisit(repo, chatID, payload)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestGroupRequest(t *testing.T) {
+	const botID = 42
+	command := func(text string, length int) *tgbotapi.Message {
+		return &tgbotapi.Message{Text: text, Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}}
+	}
+	mention := func(text string, offset, length int) *tgbotapi.Message {
+		return &tgbotapi.Message{Text: text, Entities: []tgbotapi.MessageEntity{{Type: "mention", Offset: offset, Length: length}}}
+	}
+	cases := []struct {
+		name string
+		msg  *tgbotapi.Message
+		text string
+		ok   bool
+	}{
+		{"mention", mention("@DanceBot сколько стоит абонемент?", 0, 9), "сколько стоит абонемент?", true},
+		{"mention case", mention("подскажите адрес, @dancebot", 18, 9), "подскажите адрес,", true},
+		{"mention after emoji", mention("👋 @DanceBot привет", 3, 9), "👋  привет", true},
+		{"other bot mention", mention("@DanceBot_helper привет", 0, 16), "", false},
+		{"mention without entity", &tgbotapi.Message{Text: "@DanceBot привет"}, "", false},
+		{"text mention", &tgbotapi.Message{Text: "Бот, привет", Entities: []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 0, Length: 3, User: &tgbotapi.User{ID: botID}}}}, ", привет", true},
+		{"reply to bot", &tgbotapi.Message{Text: "а на выходных?", ReplyToMessage: &tgbotapi.Message{From: &tgbotapi.User{ID: botID}}}, "а на выходных?", true},
+		{"reply to user", &tgbotapi.Message{Text: "согласен", ReplyToMessage: &tgbotapi.Message{From: &tgbotapi.User{ID: 7}}}, "", false},
+		{"plain", &tgbotapi.Message{Text: "всем привет"}, "", false},
+		{"command", command("/prices", 7), "/prices", true},
+		{"own command", command("/prices@DanceBot", 16), "/prices@DanceBot", true},
+		{"other bot command", command("/prices@OtherBot", 16), "/prices@OtherBot", false},
+	}
+	for _, c := range cases {
+		text, ok := groupRequest(c.msg, "DanceBot", botID)
+		if ok != c.ok || (ok && text != c.text) {
+			t.Errorf("%s: got %q, %v; want %q, %v", c.name, text, ok, c.text, c.ok)
+		}
+	}
+}
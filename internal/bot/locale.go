@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"sync"
+
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+)
+
+var (
+	localeMu    sync.RWMutex
+	chatLocales = make(map[int64]string)
+)
+
+// rememberLocale stores the chat locale derived from Telegram language code.
+func rememberLocale(chatID int64, languageCode string) string {
+	locale := i18n.Resolve(languageCode)
+	localeMu.Lock()
+	chatLocales[chatID] = locale
+	localeMu.Unlock()
+	return locale
+}
+
+// localeFor returns the locale of the chat or the default one. Locales
+// missing in memory, e.g. after a restart, are read from the conversation.
+func localeFor(chatID int64) string {
+	localeMu.RLock()
+	locale, ok := chatLocales[chatID]
+	localeMu.RUnlock()
+	if ok {
+		return locale
+	}
+	if repo != nil {
+		locale = conversation.GetLocale(repo, chatID)
+	}
+	if locale == "" {
+		locale = i18n.DefaultLocale()
+	}
+	localeMu.Lock()
+	chatLocales[chatID] = locale
+	localeMu.Unlock()
+	return locale
+}
+
+// localize renders a user-facing message in the chat locale.
+func localize(chatID int64, key string, vars ...i18n.Vars) string {
+	return i18n.T(localeFor(chatID), key, vars...)
+}
+
+// adminText renders an admin-facing message in the default locale.
+func adminText(key string, vars ...i18n.Vars) string {
+	return i18n.T(i18n.DefaultLocale(), key, vars...)
+}
@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
+	"ragbot/internal/tansultant"
+	"ragbot/internal/util"
+)
+
+// branchDistance is a branch with the distance to the user in kilometers.
+// Distance is negative when branch coordinates are unknown.
+type branchDistance struct {
+	Branch   tansultant.Branch
+	Distance float64
+}
+
+// sortBranchesByDistance orders branches from the closest to the farthest.
+// Branches without coordinates keep their order at the end of the list.
+func sortBranchesByDistance(branches []tansultant.Branch, lat, lng float64) []branchDistance {
+	result := make([]branchDistance, 0, len(branches))
+	for _, b := range branches {
+		d := -1.0
+		if b.HasCoordinates() {
+			d = util.DistanceKm(lat, lng, float64(b.Latitude), float64(b.Longitude))
+		}
+		result = append(result, branchDistance{Branch: b, Distance: d})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		di, dj := result[i].Distance, result[j].Distance
+		if di < 0 || dj < 0 {
+			return dj < 0 && di >= 0
+		}
+		return di < dj
+	})
+	return result
+}
+
+// locationRequestKeyboard asks the user to share a location in one tap.
+func locationRequestKeyboard(chatID int64) tgbotapi.ReplyKeyboardMarkup {
+	keyboard := tgbotapi.NewOneTimeReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonLocation(localize(chatID, msgShareLocationButton)),
+		),
+	)
+	return keyboard
+}
+
+// sendNearestBranches answers a shared location with branches sorted by distance.
+func sendNearestBranches(chatID int64, lat, lng float64) {
+	if tansClient == nil {
+		replyToUser(chatID, localize(chatID, msgServiceUnavailable))
+		return
+	}
+	branches, err := tansClient.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		log.Printf("Failed retrieving branches: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	sorted := sortBranchesByDistance(branches, lat, lng)
+	if sorted[0].Distance < 0 {
+		sendAddresses(chatID, false)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(localize(chatID, msgNearestBranchesTitle))
+	ordered := make([]tansultant.Branch, len(sorted))
+	for i, bd := range sorted {
+		ordered[i] = bd.Branch
+		sb.WriteString(localize(chatID, msgNearestBranchItem, i18n.Vars{
+			"Index":       i + 1,
+			"Title":       bd.Branch.Title,
+			"Address":     bd.Branch.Address,
+			"Phone":       bd.Branch.Phone,
+			"Distance":    bd.Distance,
+			"HasDistance": bd.Distance >= 0,
+		}))
+	}
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = scheduleBranchesKeyboard(chatID, ordered)
+	if _, err := userBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/access"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+)
+
+const (
+	mediaPhoto    = "photo"
+	mediaDocument = "document"
+	mediaVideo    = "video"
+	mediaSticker  = "sticker"
+	mediaLocation = "location"
+	mediaOther    = "other"
+)
+
+// mediaMessage describes a non-text message received from a user.
+type mediaMessage struct {
+	Kind     string
+	FileID   string
+	FileName string
+	Emoji    string
+	Location *tgbotapi.Location
+}
+
+// historyPrefix returns the marker stored in history before the message text.
+func (m mediaMessage) historyPrefix() string {
+	switch m.Kind {
+	case mediaPhoto:
+		return historyPhotoPrefix
+	case mediaDocument:
+		return historyDocumentPrefix
+	case mediaVideo:
+		return historyVideoPrefix
+	case mediaSticker:
+		return historyStickerPrefix
+	case mediaLocation:
+		return historyLocationPrefix
+	}
+	return historyOtherPrefix
+}
+
+// describeMedia recognizes supported non-text messages. Voice and audio
+// messages are handled separately by the transcription flow.
+func describeMedia(message *tgbotapi.Message) (mediaMessage, bool) {
+	switch {
+	case len(message.Photo) > 0:
+		// Telegram присылает несколько размеров, последний — самый большой
+		photo := message.Photo[len(message.Photo)-1]
+		return mediaMessage{Kind: mediaPhoto, FileID: photo.FileID, FileName: "photo.jpg"}, true
+	case message.Document != nil:
+		return mediaMessage{Kind: mediaDocument, FileID: message.Document.FileID, FileName: message.Document.FileName}, true
+	case message.Video != nil:
+		return mediaMessage{Kind: mediaVideo, FileID: message.Video.FileID, FileName: message.Video.FileName}, true
+	case message.VideoNote != nil:
+		return mediaMessage{Kind: mediaVideo, FileID: message.VideoNote.FileID, FileName: "video_note.mp4"}, true
+	case message.Animation != nil:
+		return mediaMessage{Kind: mediaVideo, FileID: message.Animation.FileID, FileName: message.Animation.FileName}, true
+	case message.Sticker != nil:
+		return mediaMessage{Kind: mediaSticker, Emoji: message.Sticker.Emoji}, true
+	case message.Venue != nil:
+		return mediaMessage{Kind: mediaLocation, Location: &message.Venue.Location}, true
+	case message.Location != nil:
+		return mediaMessage{Kind: mediaLocation, Location: message.Location}, true
+	case message.Contact != nil, message.Poll != nil, message.Dice != nil, message.Game != nil:
+		return mediaMessage{Kind: mediaOther}, true
+	}
+	return mediaMessage{}, false
+}
+
+// handleMediaMessage reacts to a non-text message. It returns the caption
+// to be answered as a regular question or an empty string when the message
+// has been fully handled here.
+func handleMediaMessage(chatID int64, message *tgbotapi.Message, media mediaMessage) string {
+	prefix := media.historyPrefix()
+	switch media.Kind {
+	case mediaSticker:
+		conversation.AppendHistory(repo, chatID, "user", prefix+fmt.Sprintf(historyStickerFormat, media.Emoji))
+		replyToUser(chatID, localize(chatID, msgStickerReply))
+		return ""
+	case mediaLocation:
+		lat, lng := media.Location.Latitude, media.Location.Longitude
+		conversation.AppendHistory(repo, chatID, "user", prefix+fmt.Sprintf(historyLocationFormat, lat, lng))
+		sendNearestBranches(chatID, lat, lng)
+		return ""
+	}
+
+	forwarded := false
+	if media.FileID != "" && isHandoffActive(chatID) {
+		forwarded = forwardMediaToAdmins(chatID, media, message.Caption)
+	}
+	if message.Caption != "" {
+		return message.Caption
+	}
+
+	conversation.AppendHistory(repo, chatID, "user", prefix+historyMediaNoCaption)
+	if forwarded {
+		replyToUser(chatID, localize(chatID, msgMediaForwarded))
+	} else {
+		replyToUser(chatID, localize(chatID, msgMediaReceived))
+	}
+	return ""
+}
+
+// isHandoffActive reports whether the chat is being handed over to a manager:
+// either the contact flow is in progress or a call has already been requested.
+func isHandoffActive(chatID int64) bool {
+	return engine.InContactFlow(chatID) || conversation.HasCallRequest(repo, chatID)
+}
+
+// forwardMediaToAdmins re-uploads a user's file to admins via the admin bot,
+// because Telegram file IDs are valid only for the bot that received them.
+func forwardMediaToAdmins(chatID int64, media mediaMessage, caption string) bool {
+	info, err := conversation.GetChatInfoByChatID(repo, chatID)
+	if err != nil {
+		log.Printf("Failed forwarding media: %v", err)
+		return false
+	}
+	link := fmt.Sprintf(chatUrlFormat, config.Config.BaseURL, info.ID)
+	author := info.Name.String
+	if author == "" && info.Username.Valid {
+		author = "@" + info.Username.String
+	}
+	adminCaption := adminText(msgAdminMediaFormat, i18n.Vars{"Author": author, "Link": link})
+	if caption != "" {
+		adminCaption += "\n\n" + caption
+	}
+
+	data, err := downloadUserFile(media.FileID)
+	if err != nil {
+		log.Printf("Failed downloading media: %v", err)
+		SendToAdminsWith(access.ViewTranscripts, adminCaption)
+		return true
+	}
+	file := tgbotapi.FileBytes{Name: media.FileName, Bytes: data}
+	if file.Name == "" {
+		file.Name = media.Kind
+	}
+	sendFileToAdminsWith(access.ViewTranscripts, file, media.Kind == mediaPhoto, adminCaption)
+	return true
+}
+
+// downloadUserFile fetches a file received by the user bot.
+func downloadUserFile(fileID string) ([]byte, error) {
+	body, err := openUserFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func openUserFile(fileID string) (io.ReadCloser, error) {
+	return openBotFile(userBot, fileID)
+}
+
+// fileClient downloads files sent to the bots. Updates are handled one by one,
+// so the timeout covers reading the whole body: a stalled download would stop the bot.
+var fileClient = &http.Client{Timeout: time.Minute}
+
+// openBotFile downloads a file sent to the bot.
+func openBotFile(b *tgbotapi.BotAPI, fileID string) (io.ReadCloser, error) {
+	url, err := b.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("get file url: %v", err)
+	}
+	resp, err := fileClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download file: unexpected status: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/tansultant"
+)
+
+func TestDescribeMedia(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message *tgbotapi.Message
+		kind    string
+		fileID  string
+	}{
+		{
+			name: "Largest photo",
+			message: &tgbotapi.Message{Photo: []tgbotapi.PhotoSize{
+				{FileID: "small"}, {FileID: "large"},
+			}},
+			kind:   mediaPhoto,
+			fileID: "large",
+		},
+		{
+			name:    "Document",
+			message: &tgbotapi.Message{Document: &tgbotapi.Document{FileID: "doc", FileName: "price.pdf"}},
+			kind:    mediaDocument,
+			fileID:  "doc",
+		},
+		{
+			name:    "Sticker",
+			message: &tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "st", Emoji: "👍"}},
+			kind:    mediaSticker,
+		},
+		{
+			name:    "Location",
+			message: &tgbotapi.Message{Location: &tgbotapi.Location{Latitude: 55.75, Longitude: 37.61}},
+			kind:    mediaLocation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			media, ok := describeMedia(tc.message)
+			if !ok {
+				t.Fatalf("media not recognized")
+			}
+			if media.Kind != tc.kind || media.FileID != tc.fileID {
+				t.Errorf("Expected %s/%s, got %s/%s", tc.kind, tc.fileID, media.Kind, media.FileID)
+			}
+		})
+	}
+
+	if _, ok := describeMedia(&tgbotapi.Message{Text: "Привет"}); ok {
+		t.Errorf("Text message should not be treated as media")
+	}
+}
+
+func TestSortBranchesByDistance(t *testing.T) {
+	branches := []tansultant.Branch{
+		{ID: 1, Title: "Без координат"},
+		{ID: 2, Title: "Далеко", Latitude: 59.93, Longitude: 30.31},
+		{ID: 3, Title: "Рядом", Latitude: 55.76, Longitude: 37.62},
+		{ID: 4, Title: "Тоже без координат"},
+	}
+
+	sorted := sortBranchesByDistance(branches, 55.75, 37.61)
+	var order []int
+	for _, bd := range sorted {
+		order = append(order, bd.Branch.ID)
+	}
+	if fmt.Sprint(order) != "[3 2 1 4]" {
+		t.Fatalf("Unexpected order %v", order)
+	}
+	if sorted[0].Distance > 2 {
+		t.Errorf("Unexpected distance %.2f km", sorted[0].Distance)
+	}
+	if sorted[2].Distance >= 0 || sorted[3].Distance >= 0 {
+		t.Errorf("Branches without coordinates should have no distance")
+	}
+}
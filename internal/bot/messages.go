@@ -1,77 +1,215 @@
 package bot
 
+// Ключи сообщений каталога internal/i18n/locales. Тексты и их переводы
+// хранятся в каталоге, здесь — только идентификаторы.
 const (
-	msgCallManagerButton    = "Хочу, чтобы мне перезвонили"
-	msgCallManagerPrompt    = "Чтобы продолжить общение с нашим менеджером, нажмите кнопку:"
-	msgConfirmYes           = "Да"
-	msgConfirmNo            = "Нет"
-	msgConfirmContactFormat = "Мы нашли ваши контактные данные: %s, %s. Всё верно?"
-	msgAskName              = "Как к вам можно обращаться?"
-	msgAskPhone             = "Напишите ваш телефон для связи."
-	msgManagerWillCall      = "Наш менеджер свяжется с вами в ближайшее время."
-	msgAdminSummaryFormat   = "%s (%s): %s\n\n%s"
-	msgAdminErrorFormat     = "Возникла ошибка: %s"
-	msgUserError            = "Возникла ошибка. Пожалуйста, попробуйте повторить ваш запрос позднее."
-	msgAdminMyIDFormat      = "Ваш CHAT ID: %d"
-	msgAdminHelp            = "Команды администратора:\n" +
-		"/start или /myid — получить свой chat_id\n" +
-		"/update <id> <текст> — обновить фрагмент по ID\n" +
-		"/delete <id> — удалить фрагмент по ID\n" +
-		"/help — эта справка\n" +
-		"\n" +
-		"Все остальные сообщения будут интерпретированы как фрагменты для записи в базу знаний.\n" +
-		"\n" +
-		"**Как добавлять знания в базу:**\n" +
-		"1. Вносите информацию маленькими фрагментами: небольшими простыми предложениями.\n" +
-		"2. Начало и конец фрагмента должны быть осмысленными, в идеале должны совпадать с началом и концом предложения, а лучше абзаца, чтобы смысл содержался во фрагменте целиком.\n" +
-		"3. Один фрагмент должен нести в себе одну «единицу смысла», одно понятие или описание. Не перегружайте фрагменты разной несвязанной друг с другом информацией.\n" +
-		"4. Фрагменты должны перекрывать друг друга, чтобы ассистент мог собрать разные фрагменты в общую картину.\n" +
-		"\n" +
-		"**Например:**\n" +
-		"абонемент это пропуск, дающий право посещения занятий в выбранных классах\n" +
-		"абонементы бывают разных типов\n" +
-		"абонемент типа Стандарт дает право посещения одного класса\n" +
-		"абонемент типа Вездеход дает право посещения разных классов в одной студии\n" +
-		"абонемент на месяц включает 8 или 12 занятий (в зависимости от типа абонемента)\n" +
-		"при покупке нескольких абонементов действуют скидки\n" +
-		"условия акций и скидок можно уточнить у администратора"
-	msgAdminInvalidID         = "Неверный ID"
-	msgAdminDeleteError       = "Ошибка удаления фрагмента #%d"
-	msgAdminDeletedFormat     = "Удалён фрагмент #%d: %s"
-	msgAdminUpdateUsage       = "Использование: /update <id> <новый текст>"
-	msgAdminUpdateError       = "Ошибка обновления фрагмента #%d: %s"
-	msgAdminUpdatedFormat     = "Обновлён фрагмент #%d: %s"
-	msgAdminAddError          = "Ошибка добавления фрагмента: %s"
-	msgAdminAdded             = "Добавлен фрагмент #%d: %s"
-	msgAdminExists            = "Фрагмент уже существует: %s"
-	msgUnknownCommand         = "Неизвестная команда"
-	msgServiceUnavailable     = "Информация недоступна"
-	msgInfoUnavailable        = "Информация недоступна"
-	msgScheduleTitle          = "Расписание занятий:"
-	msgPricesTitle            = "Цены на обучение:"
-	msgAddressFormat          = "Студия «%s»: %s\n"
-	msgPriceButtonFormat      = "Абонемент %s — %s₽"
-	msgPriceDescriptionFormat = "*Абонемент %s*\n%s\n\n%s"
-	msgPassHoursFormat        = "• Доступно %s занятий\n"
-	msgGuestVisitsFormat      = "• Включает %s гостевых посещений для друзей\n"
-	msgPassFreezeAllowed      = "• Разрешена «заморозка» на 30 дней\n"
-	msgPassLifetimeFormat     = "• Срок действия: %s дн.\n"
-	msgPriceFormat            = "• *Стоимость: %s₽*\n"
-	msgScheduleLinkFormat     = "Расписание студии %s"
-	msgStatsPrompt            = "Чтобы открыть статистику, нажмите кнопку:"
-	msgStatsButton            = "Статистика"
-	msgChatsPrompt            = "Чтобы открыть список чатов, нажмите кнопку:"
-	msgChatsButton            = "Чаты"
-	msgChannelPrompt          = "Чтобы открыть телеграм-канал ШТБП, нажмите кнопку:"
+	msgCommandStart         = "command_start"
+	msgCommandAddress       = "command_address"
+	msgCommandPrices        = "command_prices"
+	msgCommandRasp          = "command_rasp"
+	msgCommandCall          = "command_call"
+	msgCommandChannel       = "command_channel"
+	msgCommandBook          = "command_book"
+	msgCommandUnsubscribe   = "command_unsubscribe"
+	msgStartGreeting        = "start_greeting"
+	msgAskName              = "ask_name"
+	msgAskPhone             = "ask_phone"
+	msgUserError            = "user_error"
+	msgServiceUnavailable   = "service_unavailable"
+	msgInfoUnavailable      = "info_unavailable"
+	msgScheduleTitle        = "schedule_title"
+	msgScheduleLinkFormat   = "schedule_link"
+	msgScheduleDayFormat    = "schedule_day"
+	msgScheduleLesson       = "schedule_lesson"
+	msgScheduleEmpty        = "schedule_empty"
+	msgSchedulePrev         = "schedule_prev"
+	msgScheduleNext         = "schedule_next"
+	msgScheduleFull         = "schedule_full"
+	msgScheduleBranches     = "schedule_branches"
+	msgBookingButton        = "booking_button"
+	msgBookingChooseBranch  = "booking_choose_branch"
+	msgBookingChooseLesson  = "booking_choose_lesson"
+	msgBookingLessonButton  = "booking_lesson_button"
+	msgBookingNoLessons     = "booking_no_lessons"
+	msgBookingLessonGone    = "booking_lesson_gone"
+	msgBookingConfirm       = "booking_confirm"
+	msgBookingConfirmButton = "booking_confirm_button"
+	msgBookingEditButton    = "booking_edit_button"
+	msgBookingCancelButton  = "booking_cancel_button"
+	msgBookingDone          = "booking_done"
+	msgBookingFailed        = "booking_failed"
+	msgBookingCanceled      = "booking_canceled"
+	msgBookingUnavailable   = "booking_unavailable"
+	msgChannelPrompt        = "channel_prompt"
+	msgVoiceUnsupported     = "voice_unsupported"
+	msgVoiceUnrecognized    = "voice_unrecognized"
+	msgVoiceTooLong         = "voice_too_long"
+	msgStickerReply         = "sticker_reply"
+	msgMediaReceived        = "media_received"
+	msgMediaForwarded       = "media_forwarded"
+	msgShareLocationButton  = "share_location_button"
+	msgShareLocationPrompt  = "share_location_prompt"
+	msgNearestBranchesTitle = "nearest_branches_title"
+	msgNearestBranchItem    = "nearest_branch_item"
+	msgFeedbackThanks       = "feedback_thanks"
+	msgFeedbackAskComment   = "feedback_ask_comment"
+	msgFeedbackCommented    = "feedback_comment_thanks"
+	msgBroadcastFooter      = "broadcast_footer"
+	msgUnsubscribed         = "unsubscribed"
+	msgSubscribed           = "subscribed"
+	msgGroupPrivatePrompt   = "group_private_prompt"
+	msgGroupBookingPrompt   = "group_booking_prompt"
+	msgGroupPrivateButton   = "group_private_button"
 )
 
 const (
-	promptUserPrefix        = "Пользователь: "
-	promptAssistantPrefix   = "Помощник: "
-	promptSummarizeGist     = "Суммаризируй диалог пользователя в двух предложениях с упоминанием выбранных пользователем танцевальных направлений (если таковые были), а также выбранного филиала (если он был выбран):\n%s\nРезюме:"
-	promptSummarizeTitle    = "Сократи суть обращения до заголовка из 5-6 слов:\n%s\nСуть:"
-	promptSummarizeInterest = "Если пользователь запрашивал информацию о конкретных танцевальных классах или направлениях, перечисли их. Если таковых нет, верни пустую строку:\n%s\nПользователь интересовался классами:"
-	historyCallRequested    = "** хочет, чтобы ему перезвонили **"
-	historyConfirmYes       = "** подтвердил контактные данные **"
-	historyConfirmNo        = "** опроверг контактные данные **"
+	msgAdminCommandStart  = "admin_command_start"
+	msgAdminCommandHelp   = "admin_command_help"
+	msgAdminCommandUpdate = "admin_command_update"
+	msgAdminCommandDelete = "admin_command_delete"
+	msgAdminCommandList   = "admin_command_list"
+	msgAdminCommandStats  = "admin_command_stats"
+	msgAdminCommandChats  = "admin_command_chats"
+	msgAdminCommandFix    = "admin_command_fix"
+	msgAdminCommandCast   = "admin_command_broadcast"
+	msgAdminCommandSearch = "admin_command_search"
+	msgAdminCommandAsk    = "admin_command_ask"
+	msgAdminCommandExport = "admin_command_export"
+	msgAdminCommandHist   = "admin_command_history"
+	msgAdminCommandPass   = "admin_command_password"
+	msgAdminCommandAdmins = "admin_command_admins"
+	msgAdminCommandAdmin  = "admin_command_admin"
+	msgAdminErrorFormat   = "admin_error"
+	msgAdminLeadError     = "admin_lead_error"
+	msgAdminMyIDFormat    = "admin_my_id"
+	msgAdminHelp          = "admin_help"
+	msgAdminInvalidID     = "admin_invalid_id"
+	msgAdminDeleteError   = "admin_delete_error"
+	msgAdminDeletedFormat = "admin_deleted"
+	msgAdminUpdateUsage   = "admin_update_usage"
+	msgAdminUpdateError   = "admin_update_error"
+	msgAdminUpdatedFormat = "admin_updated"
+	msgAdminAddError      = "admin_add_error"
+	msgAdminAdded         = "admin_added"
+	msgAdminExists        = "admin_exists"
+	msgAdminListError     = "admin_list_error"
+	msgAdminListUsage     = "admin_list_usage"
+	msgAdminListPage      = "admin_list_page"
+	msgAdminListItem      = "admin_list_item"
+	msgAdminListEmpty     = "admin_list_empty"
+	msgAdminListPrevBtn   = "admin_list_prev_button"
+	msgAdminListNextBtn   = "admin_list_next_button"
+	msgAdminListPageBtn   = "admin_list_page_button"
+	msgAdminListEditBtn   = "admin_list_edit_button"
+	msgAdminListDeleteBtn = "admin_list_delete_button"
+	msgAdminListCanceled  = "admin_list_edit_canceled"
+	msgAdminMediaFormat   = "admin_media"
+	msgAdminTansStale     = "admin_tansultant_stale"
+	msgAdminBooking       = "admin_booking"
+	msgAdminBookingError  = "admin_booking_error"
+	msgAdminBookingNote   = "admin_booking_comment"
+	msgAdminBookingLead   = "admin_booking_lead"
+	msgAdminFixUsage      = "admin_fix_usage"
+	msgAdminFixNotFound   = "admin_fix_not_found"
+	msgAdminFixCard       = "admin_fix_card"
+	msgAdminFixSources    = "admin_fix_sources_title"
+	msgAdminFixSource     = "admin_fix_source"
+	msgAdminFixDeleted    = "admin_fix_source_deleted"
+	msgAdminFixNoSources  = "admin_fix_no_sources"
+	msgAdminFixEditButton = "admin_fix_edit_button"
+	msgAdminFixAddButton  = "admin_fix_add_button"
+	msgAdminFixRerunBtn   = "admin_fix_rerun_button"
+	msgAdminFixCloseBtn   = "admin_fix_close_button"
+	msgAdminFixEditPrompt = "admin_fix_edit_prompt"
+	msgAdminFixAddPrompt  = "admin_fix_add_prompt"
+	msgAdminFixSaved      = "admin_fix_saved"
+	msgAdminFixEmbedError = "admin_fix_embed_error"
+	msgAdminFixRerun      = "admin_fix_rerun"
+	msgAdminFixCanceled   = "admin_fix_canceled"
+	msgAdminFixClosed     = "admin_fix_closed"
+	msgAdminFixOffer      = "admin_fix_offer"
+	msgAdminFixOfferBtn   = "admin_fix_offer_button"
+	msgAdminFixError      = "admin_fix_error"
+	msgAdminSearchUsage   = "admin_search_usage"
+	msgAdminSearchTitle   = "admin_search_title"
+	msgAdminSearchItem    = "admin_search_item"
+	msgAdminSearchEmpty   = "admin_search_empty"
+	msgAdminSearchError   = "admin_search_error"
+	msgAdminAskUsage      = "admin_ask_usage"
+	msgAdminAskResult     = "admin_ask_result"
+	msgAdminAskPrompt     = "admin_ask_prompt"
+	msgAdminAskFile       = "admin_ask_prompt_file"
+	msgAdminAskError      = "admin_ask_error"
+	msgAdminExportUsage   = "admin_export_usage"
+	msgAdminExportCaption = "admin_export_caption"
+	msgAdminExportEmpty   = "admin_export_empty"
+	msgAdminExportError   = "admin_export_error"
+	msgAdminImportFormat  = "admin_import_unsupported"
+	msgAdminImportLarge   = "admin_import_too_large"
+	msgAdminImportError   = "admin_import_error"
+	msgAdminImportPreview = "admin_import_preview"
+	msgAdminImportNothing = "admin_import_nothing"
+	msgAdminImportYesBtn  = "admin_import_confirm_button"
+	msgAdminImportNoBtn   = "admin_import_cancel_button"
+	msgAdminImportDone    = "admin_import_done"
+	msgAdminImportCancel  = "admin_import_canceled"
+	msgAdminImportExpired = "admin_import_expired"
+	msgAdminHistoryUsage  = "admin_history_usage"
+	msgAdminHistoryRecent = "admin_history_recent"
+	msgAdminHistoryTitle  = "admin_history_title"
+	msgAdminHistoryItem   = "admin_history_item"
+	msgAdminHistoryEmpty  = "admin_history_empty"
+	msgAdminHistoryError  = "admin_history_error"
+	msgAdminHistoryBtn    = "admin_history_restore_button"
+	msgAdminRestored      = "admin_restored"
+	msgAdminRestoreError  = "admin_restore_error"
+	msgAdminRestoreSynced = "admin_restore_synced"
+	msgAdminDenied        = "admin_access_denied"
+	msgAdminAccounts      = "admin_accounts"
+	msgAdminAccountsItem  = "admin_accounts_item"
+	msgAdminAccountUsage  = "admin_account_usage"
+	msgAdminAccountRole   = "admin_account_role"
+	msgAdminAccountLinked = "admin_account_linked"
+	msgAdminAccountPass   = "admin_account_password"
+	msgAdminAccountDel    = "admin_account_removed"
+	msgAdminAccountNone   = "admin_account_missing"
+	msgAdminAccountError  = "admin_account_error"
+	msgAdminLastOwner     = "admin_account_last_owner"
+	msgAdminPasswordUsage = "admin_password_usage"
+	msgAdminPasswordLogin = "admin_password_no_login"
+	msgAdminPasswordSet   = "admin_password_set"
+	msgAdminCastUsage     = "admin_broadcast_usage"
+	msgAdminCastSegment   = "admin_broadcast_segment"
+	msgAdminCastAskText   = "admin_broadcast_ask_text"
+	msgAdminCastEmpty     = "admin_broadcast_empty"
+	msgAdminCastPreview   = "admin_broadcast_preview"
+	msgAdminCastSendBtn   = "admin_broadcast_send_button"
+	msgAdminCastCancelBtn = "admin_broadcast_cancel_button"
+	msgAdminCastStopBtn   = "admin_broadcast_stop_button"
+	msgAdminCastProgress  = "admin_broadcast_progress"
+	msgAdminCastDone      = "admin_broadcast_done"
+	msgAdminCastStopped   = "admin_broadcast_stopped"
+	msgAdminCastCanceled  = "admin_broadcast_canceled"
+	msgAdminCastError     = "admin_broadcast_error"
+	msgStatsPrompt        = "stats_prompt"
+	msgStatsButton        = "stats_button"
+	msgChatsPrompt        = "chats_prompt"
+	msgChatsButton        = "chats_button"
+)
+
+// Отметки Telegram-бота в истории переписки о голосовых сообщениях, файлах,
+// геолокации и записи. Общие отметки каналов — в repository.
+const (
+	historyVoicePrefix    = "🎤 "
+	historyVoiceFailed    = "** голосовое сообщение не распознано **"
+	historyPhotoPrefix    = "📷 "
+	historyDocumentPrefix = "📎 "
+	historyVideoPrefix    = "🎬 "
+	historyStickerPrefix  = "🙂 "
+	historyLocationPrefix = "📍 "
+	historyOtherPrefix    = "📦 "
+	historyMediaNoCaption = "** файл без подписи **"
+	historyStickerFormat  = "** стикер %s **"
+	historyLocationFormat = "** геолокация %.6f, %.6f **"
+	historyBookingFormat  = "** хочет записаться на пробное: %s **"
 )
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/i18n"
+	"ragbot/internal/tansultant"
+)
+
+func sendSchedule(chatID int64) {
+	if tansClient == nil {
+		replyToUser(chatID, localize(chatID, msgServiceUnavailable))
+		return
+	}
+	branches, err := tansClient.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		log.Printf("Failed retrieving schedule: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	userBot.Send(scheduleButtons(chatID, branches))
+}
+
+// showScheduleBranches returns the timetable message to the list of branches.
+func showScheduleBranches(chatID int64, messageID int) {
+	branches, err := tansClient.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		log.Printf("Failed retrieving schedule: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+		localize(chatID, msgScheduleTitle), scheduleBranchesKeyboard(chatID, branches))
+	if _, err := userBot.Send(edit); err != nil {
+		log.Printf("Error editing schedule message: %v", err)
+	}
+}
+
+// showScheduleDay renders the timetable of a branch for a day into the message.
+// The payload has the form <branchID>_<YYYY-MM-DD>.
+func showScheduleDay(chatID int64, messageID int, payload string) {
+	branchID, day, ok := parseSchedulePayload(payload)
+	if !ok {
+		log.Printf("Invalid schedule callback payload: %s", payload)
+		return
+	}
+	ctx := context.Background()
+	branches, err := tansClient.Branches(ctx)
+	if err != nil {
+		log.Printf("Failed retrieving schedule: %v", err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+	var branch tansultant.Branch
+	found := false
+	for _, b := range branches {
+		if b.ID == branchID {
+			branch, found = b, true
+			break
+		}
+	}
+	if !found {
+		showScheduleBranches(chatID, messageID)
+		return
+	}
+	lessons, err := tansClient.Schedule(ctx, branchID, day, day)
+	if err != nil {
+		log.Printf("Failed retrieving schedule of branch %d: %v", branchID, err)
+		replyToUser(chatID, localize(chatID, msgInfoUnavailable))
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID,
+		scheduleDayText(chatID, branch, day, lessons), scheduleDayKeyboard(chatID, branch, day))
+	if _, err := userBot.Send(edit); err != nil {
+		log.Printf("Error editing schedule message: %v", err)
+	}
+}
+
+func parseSchedulePayload(payload string) (int, time.Time, bool) {
+	idPart, datePart, ok := strings.Cut(payload, "_")
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	day, err := time.ParseInLocation(time.DateOnly, datePart, time.Local)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return id, day, true
+}
+
+func scheduleDayText(chatID int64, b tansultant.Branch, day time.Time, lessons []tansultant.Lesson) string {
+	locale := localeFor(chatID)
+	var sb strings.Builder
+	sb.WriteString(localize(chatID, msgScheduleDayFormat, i18n.Vars{
+		"Title":   b.Title,
+		"Weekday": i18n.Weekday(locale, day),
+		"Date":    day.Format("02.01.2006"),
+	}))
+	if len(lessons) == 0 {
+		sb.WriteString(localize(chatID, msgScheduleEmpty))
+		return sb.String()
+	}
+	for _, l := range lessons {
+		sb.WriteString(localize(chatID, msgScheduleLesson, i18n.Vars{
+			"Start":      l.StartsAt.Format("15:04"),
+			"End":        l.EndsAt.Format("15:04"),
+			"Name":       l.Name,
+			"Instructor": l.Instructor,
+			"Classroom":  l.Classroom,
+			"Canceled":   l.Canceled(),
+		}))
+	}
+	return sb.String()
+}
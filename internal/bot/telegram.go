@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/messenger"
+	"ragbot/internal/repository"
+)
+
+// telegramAdapter delivers messages of the conversation engine to Telegram chats.
+type telegramAdapter struct{}
+
+func (telegramAdapter) Channel() string {
+	return repository.ChannelTelegram
+}
+
+func (telegramAdapter) Send(externalID string, out messenger.Outbound) error {
+	chatID, err := strconv.ParseInt(externalID, 10, 64)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, out.Text)
+	if out.MarkdownV2 != "" {
+		msg.Text = out.MarkdownV2
+		msg.ParseMode = tgbotapi.ModeMarkdownV2
+	}
+	if len(out.Buttons) > 0 {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for _, row := range out.Buttons {
+			var buttons []tgbotapi.InlineKeyboardButton
+			for _, b := range row {
+				if b.URL != "" {
+					buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(b.Text, b.URL))
+				} else {
+					buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(b.Text, b.Action))
+				}
+			}
+			rows = append(rows, buttons)
+		}
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	_, err = userBot.Send(msg)
+	return err
+}
+
+// chat binds the Telegram chat to the conversation engine.
+func chat(chatID int64) *messenger.Conversation {
+	return messenger.NewConversation(telegramAdapter{}, chatID, strconv.FormatInt(chatID, 10), localeFor(chatID))
+}
@@ -1,7 +1,7 @@
 package bot
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"strings"
 	"sync"
@@ -10,47 +10,51 @@ import (
 	ai "ragbot/internal/ai"
 	"ragbot/internal/config"
 	"ragbot/internal/conversation"
-	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/messenger"
 	"ragbot/internal/repository"
 	"ragbot/internal/tansultant"
 	"ragbot/internal/util"
 )
 
-type contactState struct {
-	Stage int
-	Name  string
-}
-
 const (
-	actionCallManager = "CALL_MANAGER"
-	actionConfirmYes  = "CONFIRM_YES"
-	actionConfirmNo   = "CONFIRM_NO"
+	actionCallManager = messenger.ActionCallManager
+	actionConfirmYes  = messenger.ActionConfirmYes
+	actionConfirmNo   = messenger.ActionConfirmNo
+	actionPricePrefix = messenger.ActionPricePrefix
+	// SCHED_<branchID>_<YYYY-MM-DD> открывает расписание филиала на день,
+	// SCHED_LIST возвращает к списку филиалов.
+	actionSchedulePrefix = "SCHED_"
+	actionScheduleList   = "SCHED_LIST"
 )
 
 const chatUrlFormat = "%s/chat/%s"
 
 var (
-	stateMu      sync.Mutex
-	contactSteps = make(map[int64]*contactState)
-	userBot      *tgbotapi.BotAPI
-	repo         *repository.Repository
-	aiClient     *ai.AIClient
-	tansClient   *tansultant.Client
-	priceMu      sync.RWMutex
-	priceMap     = make(map[string]string)
+	stateMu     sync.Mutex
+	userBot     *tgbotapi.BotAPI
+	repo        *repository.Repository
+	aiClient    *ai.AIClient
+	transcriber *ai.Transcriber
+	tansClient  *tansultant.Client
+	engine      *messenger.Engine
 )
 
-// StartUserBot launches Telegram bot for users.
-func StartUserBot(r *repository.Repository, ac *ai.AIClient, tc *tansultant.Client, token string) {
+// StartUserBot launches Telegram bot for users. Conversations are run by
+// the engine, the bot adds Telegram-only features such as the schedule and booking.
+func StartUserBot(r *repository.Repository, ac *ai.AIClient, tr *ai.Transcriber, tc *tansultant.Client, e *messenger.Engine, token string) {
 	defer util.Recover("StartUserBot")
 
+	engine = e
 	aiClient = ac
+	transcriber = tr
 	tansClient = tc
 	repo = r
 	userBot = connect(token)
 	log.Println("User bot connected to Telegram API")
 
 	registerUserCommands()
+	go resumeBroadcasts(repo)
 	handleUserUpdates()
 }
 
@@ -70,223 +74,229 @@ func handleUserUpdates() {
 			continue
 		}
 
+		if isGroupChat(update.Message.Chat) {
+			handleGroupMessage(update)
+			continue
+		}
 		handleUserMessage(update)
 	}
 }
 
 func registerUserCommands() {
 	commands := []tgbotapi.BotCommand{
-		{Command: "start", Description: "Начать общение с ассистентом"},
-		{Command: "address", Description: "Показать адреса студий"},
-		{Command: "prices", Description: "Показать цены на обучение"},
-		{Command: "rasp", Description: "Показать расписание занятий"},
-		{Command: "call", Description: "Заказать обратный звонок от менеджера"},
-		{Command: "channel", Description: "Перейти в телеграм-канал ШТБП"},
+		{Command: "start", Description: msgCommandStart},
+		{Command: "address", Description: msgCommandAddress},
+		{Command: "prices", Description: msgCommandPrices},
+		{Command: "rasp", Description: msgCommandRasp},
+		{Command: "call", Description: msgCommandCall},
+		{Command: "channel", Description: msgCommandChannel},
+		{Command: "book", Description: msgCommandBook},
+		{Command: "unsubscribe", Description: msgCommandUnsubscribe},
 	}
 
-	_, err := userBot.Request(tgbotapi.NewSetMyCommands(commands...))
-	if err != nil {
-		log.Printf("Failed registering commands for user bot: %v", err)
+	// Команды регистрируются для каждого языка каталога, язык по умолчанию — без указания языка
+	for _, locale := range i18n.Default().Locales() {
+		localized := make([]tgbotapi.BotCommand, len(commands))
+		for i, c := range commands {
+			localized[i] = tgbotapi.BotCommand{Command: c.Command, Description: i18n.T(locale, c.Description)}
+		}
+		cfg := tgbotapi.NewSetMyCommands(localized...)
+		if locale != i18n.DefaultLocale() {
+			cfg = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), locale, localized...)
+		}
+		if _, err := userBot.Request(cfg); err != nil {
+			log.Printf("Failed registering commands for user bot (%s): %v", locale, err)
+		}
 	}
 }
 
 func handleUserMessage(update tgbotapi.Update) {
 	chatID := update.Message.Chat.ID
-	username := ""
+	username, locale := "", ""
 	if update.Message.From != nil {
 		username = update.Message.From.UserName
+		locale = rememberLocale(chatID, update.Message.From.LanguageCode)
 	}
-	conversation.EnsureSession(repo, chatID, username)
+	conversation.EnsureSession(repo, chatID, username, locale)
 	userText := update.Message.Text
-	var answer string
-
-	// Обработка команды /start - инициализируем общение как если бы пользователь написал "Привет"
-	if userText == "/start" {
-		userText = "Привет"
-	}
-
-	defer func() {
-		conversation.AppendHistory(repo, chatID, "user", userText)
-		if answer != "" {
-			conversation.AppendHistory(repo, chatID, "assistant", answer)
-		}
-	}()
+	historyPrefix := ""
 
-	if handleUserCommand(update, chatID) {
-		return
+	// Обработка команды /start - инициализируем общение как если бы пользователь написал "Привет".
+	// Параметр ?start= (токен визита или сценарий из группы) разбирается в handleUserCommand
+	if update.Message.IsCommand() && update.Message.Command() == "start" {
+		userText = localize(chatID, msgStartGreeting)
 	}
 
-	stateMu.Lock()
-	st, ok := contactSteps[chatID]
-	stateMu.Unlock()
-	if ok {
-		switch st.Stage {
-		case 1:
-			requestUserName(chatID, userText, st)
-			return
-		case 2:
-			requestUserPhoneNumber(chatID, userText)
-			return
-		case 3:
-			lower := strings.ToLower(userText)
-			if strings.Contains(lower, "да") {
-				conversation.AppendHistory(repo, chatID, "user", historyConfirmYes)
-				finalizeContactRequest(chatID)
+	// Голосовые сообщения распознаём и обрабатываем как обычный текстовый вопрос
+	if fileID, filename, duration, ok := voiceFile(update.Message); ok {
+		transcript, err := transcribeVoice(fileID, filename, duration)
+		if err != nil {
+			conversation.AppendHistory(repo, chatID, "user", historyVoiceFailed)
+			if errors.Is(err, errTranscriptionDisabled) {
+				replyToUser(chatID, localize(chatID, msgVoiceUnsupported))
 				return
 			}
-			if strings.Contains(lower, "нет") {
-				conversation.AppendHistory(repo, chatID, "user", historyConfirmNo)
-				conversation.ClearAmoContactID(repo, chatID)
-				stateMu.Lock()
-				contactSteps[chatID] = &contactState{Stage: 1}
-				stateMu.Unlock()
-				replyToUser(chatID, msgAskName)
+			if errors.Is(err, errVoiceTooLong) {
+				minutes := int(config.Config.VoiceMaxDuration.Minutes())
+				replyToUser(chatID, localize(chatID, msgVoiceTooLong, i18n.Vars{"Minutes": max(minutes, 1)}))
 				return
 			}
+			log.Printf("Voice transcription error: %v", err)
+			replyToUser(chatID, localize(chatID, msgVoiceUnrecognized))
+			return
+		}
+		userText = transcript
+		historyPrefix = historyVoicePrefix
+	}
+
+	// Фото, документы, стикеры, геолокация и прочие нетекстовые сообщения
+	if media, ok := describeMedia(update.Message); ok {
+		userText = handleMediaMessage(chatID, update.Message, media)
+		if userText == "" {
+			return
 		}
+		historyPrefix = media.historyPrefix()
 	}
 
-	lowerRequest := strings.ToLower(userText)
-	if util.ContainsStringFromSlice(lowerRequest, config.Settings.CallManagerTriggerWords) {
-		userBot.Send(callMeBackButton(chatID))
+	// Служебные сообщения без текста (вход в чат и т.п.) не отвечаем
+	if strings.TrimSpace(userText) == "" {
 		return
 	}
 
-	answer, err := handler.ProcessQuestionWithHistory(repo, aiClient, chatID, userText)
-	if err != nil {
-		SendToAllAdmins(fmt.Sprintf(msgAdminErrorFormat, err))
-		answer = msgUserError
-	} else {
-		lowerAnswer := strings.ToLower(answer)
-		if util.ContainsStringFromSlice(lowerAnswer, config.Settings.CallManagerTriggerWordsInAnswer) {
-			userBot.Send(callMeBackButton(chatID))
+	// Ответ на вопрос «что было не так» после 👎 сохраняется как отзыв, а не как вопрос
+	if handleFeedbackComment(chatID, update.Message, userText) {
+		return
+	}
+
+	historySaved := false
+	defer func() {
+		if historySaved {
 			return
 		}
+		conversation.AppendHistory(repo, chatID, "user", historyPrefix+userText)
+	}()
+
+	if handleUserCommand(update, chatID) {
+		return
+	}
+
+	c := chat(chatID)
+	if engine.HandleContactInput(c, userText) {
+		return
 	}
 
-	replyToUser(chatID, answer)
+	if handleBookingInput(chatID, userText) {
+		return
+	}
+
+	// Движок сам сохраняет вопрос и ответ в истории
+	historySaved = true
+	engine.Answer(c, userText, historyPrefix+userText)
 }
 
 func handleUserCommand(update tgbotapi.Update, chatID int64) bool {
 	if update.Message.IsCommand() {
 		switch update.Message.Command() {
+		case "start":
+			return handleStartPayload(chatID, update.Message.CommandArguments())
 		case "address":
-			sendAddresses(chatID)
+			sendAddresses(chatID, isGroupChat(update.Message.Chat))
 			return true
 		case "prices":
-			sendPrices(chatID)
+			engine.ShowPrices(chat(chatID))
 			return true
 		case "rasp":
 			sendSchedule(chatID)
 			return true
 		case "call":
-			userBot.Send(callMeBackButton(chatID))
+			engine.OfferCallback(chat(chatID))
+			return true
+		case "book":
+			startBooking(chatID)
 			return true
 		case "channel":
 			userBot.Send(channelButton(chatID, config.Config.TelegramChannel))
 			return true
+		case "unsubscribe":
+			setSubscribed(chatID, false)
+			return true
+		case "subscribe":
+			setSubscribed(chatID, true)
+			return true
 		default:
 		}
 	}
 	return false
 }
 
-func sendAddresses(chatID int64) {
-	if tansClient == nil {
-		log.Printf("Failed retrieving addresses: Tansultant client is not instantiated")
-		replyToUser(chatID, msgServiceUnavailable)
-		return
-	}
-	branches, err := tansClient.Branches()
-	if err != nil || len(branches) == 0 {
-		log.Printf("Failed retrieving addresses: %s", err.Error())
-		replyToUser(chatID, msgInfoUnavailable)
+// sendAddresses sends the branch addresses. In a private chat it also offers
+// to find the nearest branch: groups cannot show the location request keyboard.
+func sendAddresses(chatID int64, inGroup bool) {
+	text, branches, ok := engine.Addresses(chat(chatID))
+	if !ok {
 		return
 	}
-	var sb strings.Builder
+	withCoordinates := false
 	for _, b := range branches {
-		sb.WriteString(fmt.Sprintf(msgAddressFormat, b.Title, b.Address))
-	}
-	replyToUser(chatID, sb.String())
-}
-
-func sendSchedule(chatID int64) {
-	if tansClient == nil {
-		replyToUser(chatID, msgServiceUnavailable)
-		return
+		withCoordinates = withCoordinates || b.HasCoordinates()
 	}
-	branches, err := tansClient.Branches()
-	if err != nil || len(branches) == 0 {
-		log.Printf("Failed retrieving schedule: %s", err.Error())
-		replyToUser(chatID, msgInfoUnavailable)
-		return
+	msg := tgbotapi.NewMessage(chatID, text)
+	// Поиск ближайшей студии предлагается, только если координаты филиалов известны
+	if withCoordinates && !inGroup {
+		msg.Text += "\n" + localize(chatID, msgShareLocationPrompt)
+		msg.ReplyMarkup = locationRequestKeyboard(chatID)
 	}
-	userBot.Send(scheduleButtons(chatID, branches))
-}
-
-func sendPrices(chatID int64) {
-	if tansClient == nil {
-		replyToUser(chatID, msgServiceUnavailable)
-		return
-	}
-	prices, err := tansClient.Prices()
-	if err != nil || len(prices) == 0 {
-		log.Printf("Failed retrieving prices: %s", err.Error())
-		replyToUser(chatID, msgInfoUnavailable)
-		return
+	if _, err := userBot.Send(msg); err != nil {
+		log.Printf("Error sending message: %s", err.Error())
 	}
-	priceMu.Lock()
-	msg, m := priceButtons(chatID, prices)
-	priceMap = m
-	priceMu.Unlock()
-	userBot.Send(msg)
 }
 
 func handleCallbackQuery(update tgbotapi.Update) {
 	chatID := update.CallbackQuery.Message.Chat.ID
 	messageID := update.CallbackQuery.Message.MessageID
-	username := ""
+	username, locale := "", ""
 	if update.CallbackQuery.From != nil {
 		username = update.CallbackQuery.From.UserName
+		locale = rememberLocale(chatID, update.CallbackQuery.From.LanguageCode)
 	}
-	conversation.EnsureSession(repo, chatID, username)
+	conversation.EnsureSession(repo, chatID, username, locale)
 	data := update.CallbackQuery.Data
 	callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "")
 	if _, err := userBot.Request(callback); err != nil {
 		log.Printf("Callback answer error: %v", err)
 	}
 
+	// Контакты в группе не собираются: пользователь переходит в личный чат
+	if isGroupChat(update.CallbackQuery.Message.Chat) {
+		switch {
+		case data == actionCallManager:
+			userBot.Send(privateChatButton(chatID, msgGroupPrivatePrompt, startPayloadCall))
+			return
+		case strings.HasPrefix(data, actionBookPrefix):
+			userBot.Send(privateChatButton(chatID, msgGroupBookingPrompt, startPayloadBook))
+			return
+		}
+	}
+
 	// Handle actions
 	switch data {
-	case actionCallManager:
-		callManagerAction(chatID)
-		// Удаляем сообщение с кнопкой после нажатия
-		deleteMessage(chatID, messageID)
-	case actionConfirmYes:
-		conversation.AppendHistory(repo, chatID, "user", historyConfirmYes)
-		finalizeContactRequest(chatID)
-		// Удаляем сообщение с кнопкой после нажатия
-		deleteMessage(chatID, messageID)
-	case actionConfirmNo:
-		conversation.AppendHistory(repo, chatID, "user", historyConfirmNo)
-		conversation.ClearAmoContactID(repo, chatID)
-		stateMu.Lock()
-		contactSteps[chatID] = &contactState{Stage: 1}
-		stateMu.Unlock()
-		replyToUser(chatID, msgAskName)
+	case actionCallManager, actionConfirmYes, actionConfirmNo:
+		engine.HandleAction(chat(chatID), data)
 		// Удаляем сообщение с кнопкой после нажатия
 		deleteMessage(chatID, messageID)
+	case actionScheduleList:
+		showScheduleBranches(chatID, messageID)
 	default:
-		if strings.HasPrefix(data, "PRICE_") {
-			priceMu.RLock()
-			desc := priceMap[data]
-			priceMu.RUnlock()
-			if desc != "" {
-				replyToUserMarkdownV2(chatID, desc)
+		if strings.HasPrefix(data, actionFeedbackPrefix) {
+			handleFeedbackCallback(chatID, messageID, data)
+		} else if strings.HasPrefix(data, actionBookPrefix) {
+			handleBookingCallback(chatID, messageID, data)
+		} else if strings.HasPrefix(data, actionSchedulePrefix) {
+			showScheduleDay(chatID, messageID, strings.TrimPrefix(data, actionSchedulePrefix))
+		} else if strings.HasPrefix(data, actionPricePrefix) {
+			if engine.ShowPrice(chat(chatID), strings.TrimPrefix(data, actionPricePrefix)) {
 				// Удаляем сообщение с кнопкой после нажатия
 				deleteMessage(chatID, messageID)
-			} else {
-				log.Printf("Unknown price callback: %s", data)
 			}
 		} else {
 			log.Printf("Unknown CallbackQuery data: %s", data)
@@ -302,15 +312,6 @@ func replyToUser(chatID int64, message string) {
 	}
 }
 
-func replyToUserMarkdownV2(chatID int64, message string) {
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
-	_, err := userBot.Send(msg)
-	if err != nil {
-		log.Printf("Error sending message: %s", err.Error())
-	}
-}
-
 // deleteMessage удаляет сообщение по chatID и messageID
 func deleteMessage(chatID int64, messageID int) {
 	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
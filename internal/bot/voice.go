@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"errors"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"ragbot/internal/config"
+)
+
+var (
+	errTranscriptionDisabled = errors.New("transcription is disabled")
+	errVoiceTooLong          = errors.New("voice message is too long")
+)
+
+// voiceFile returns Telegram file ID, file name and duration of a voice or audio message.
+func voiceFile(message *tgbotapi.Message) (fileID, filename string, duration time.Duration, ok bool) {
+	switch {
+	case message.Voice != nil:
+		return message.Voice.FileID, "voice.ogg", time.Duration(message.Voice.Duration) * time.Second, true
+	case message.Audio != nil:
+		filename = message.Audio.FileName
+		if filename == "" {
+			filename = "audio.mp3"
+		}
+		return message.Audio.FileID, filename, time.Duration(message.Audio.Duration) * time.Second, true
+	}
+	return "", "", 0, false
+}
+
+// transcribeVoice downloads a file from Telegram and converts it into text.
+// Recordings longer than VOICE_MAX_DURATION are not downloaded.
+func transcribeVoice(fileID, filename string, duration time.Duration) (string, error) {
+	if transcriber == nil {
+		return "", errTranscriptionDisabled
+	}
+	if limit := config.Config.VoiceMaxDuration; limit > 0 && duration > limit {
+		return "", errVoiceTooLong
+	}
+	body, err := openUserFile(fileID)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return transcriber.Transcribe(filename, body)
+}
@@ -6,6 +6,7 @@ import (
 	"ragbot/internal/util"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type AppConfig struct {
@@ -25,12 +26,47 @@ type AppConfig struct {
 	AdminUsername       string
 	AdminPassword       string
 	TelegramChannel     string
+
+	TranscriptionProvider string
+	TranscriptionLanguage string
+	WhisperServerURL      string
+	// VoiceMaxDuration limits voice and audio messages sent to transcription, 0 means no limit
+	VoiceMaxDuration time.Duration
+
+	MessagesDir   string
+	DefaultLocale string
+
+	AnswerFeedbackEnabled bool
+
+	AnswerCacheEnabled     bool
+	AnswerCacheMaxDistance float64
+	AnswerCacheTTL         time.Duration
+
+	BroadcastRate int
+
+	// WebAPIKeys maps the allowed website origins to their API keys
+	WebAPIKeys map[string]string
+	// Ограничения веб-API: новые сессии с одного адреса в час, вопросы
+	// одной сессии и одного адреса в минуту. 0 — без ограничения
+	WebAPISessionsPerHour      int
+	WebAPIQuestionsPerMinute   int
+	WebAPIIPQuestionsPerMinute int
+	// TrustedProxies lists addresses or CIDR ranges of proxies whose X-Forwarded-For is used
+	TrustedProxies []string
 }
 
+const (
+	TranscriptionOpenAI = "openai"
+	TranscriptionLocal  = "local"
+)
+
 type AppSettings struct {
 	Preamble                        string
 	CallManagerTriggerWords         []string
 	CallManagerTriggerWordsInAnswer []string
+	ScheduleTriggerWords            []string
+	ScheduleContextDays             int
+	FollowUpTriggerWords            []string
 }
 
 var Config *AppConfig
@@ -83,6 +119,22 @@ func LoadConfig() *AppConfig {
 		useExternal = true
 	}
 
+	transcriptionProvider := os.Getenv("TRANSCRIPTION_PROVIDER")
+	whisperURL := os.Getenv("WHISPER_SERVER_URL")
+	switch transcriptionProvider {
+	case "":
+	case TranscriptionOpenAI:
+		if apiKey == "" {
+			log.Fatalln("OPENAI_API_KEY not set when using OpenAI transcription")
+		}
+	case TranscriptionLocal:
+		if whisperURL == "" {
+			log.Fatalln("WHISPER_SERVER_URL not set when using local transcription")
+		}
+	default:
+		log.Fatalf("Invalid TRANSCRIPTION_PROVIDER value: %s", transcriptionProvider)
+	}
+
 	// Читаем ADMIN_CHAT_IDS как строку "id1,id2,id3"
 	adminIDsEnv := os.Getenv("ADMIN_CHAT_IDS")
 	var adminIDs []int64
@@ -112,7 +164,29 @@ func LoadConfig() *AppConfig {
 		AmoAccessToken:      amoToken,
 		TelegramChannel:     telegramChannel,
 		AdminUsername:       util.GetEnvString("ADMIN_USERNAME", "admin"),
-		AdminPassword:       util.GetEnvString("ADMIN_PASSWORD", "secret"),
+		AdminPassword:       os.Getenv("ADMIN_PASSWORD"),
+
+		TranscriptionProvider: transcriptionProvider,
+		TranscriptionLanguage: util.GetEnvString("TRANSCRIPTION_LANGUAGE", "ru"),
+		WhisperServerURL:      whisperURL,
+		VoiceMaxDuration:      time.Duration(util.GetEnvInt("VOICE_MAX_DURATION", 300)) * time.Second,
+
+		MessagesDir:   os.Getenv("MESSAGES_DIR"),
+		DefaultLocale: util.GetEnvString("DEFAULT_LOCALE", "ru"),
+
+		AnswerFeedbackEnabled: util.GetEnvBool("ANSWER_FEEDBACK_ENABLED", true),
+
+		AnswerCacheEnabled:     util.GetEnvBool("ANSWER_CACHE_ENABLED", true),
+		AnswerCacheMaxDistance: util.GetEnvFloat("ANSWER_CACHE_MAX_DISTANCE", 0.2),
+		AnswerCacheTTL:         time.Duration(util.GetEnvInt("ANSWER_CACHE_TTL", 86400)) * time.Second,
+
+		BroadcastRate: util.GetEnvInt("BROADCAST_RATE", 25),
+
+		WebAPIKeys:                 util.GetEnvStringMap("WEB_API_KEYS", nil),
+		WebAPISessionsPerHour:      util.GetEnvInt("WEB_API_SESSIONS_PER_HOUR", 20),
+		WebAPIQuestionsPerMinute:   util.GetEnvInt("WEB_API_QUESTIONS_PER_MINUTE", 10),
+		WebAPIIPQuestionsPerMinute: util.GetEnvInt("WEB_API_IP_QUESTIONS_PER_MINUTE", 30),
+		TrustedProxies:             util.GetEnvStringSlice("TRUSTED_PROXIES", nil),
 	}
 
 	return Config
@@ -133,10 +207,23 @@ func LoadSettings() *AppSettings {
 		callManagerTriggerWordsInAnswer = "заказать звонок,позвать менеджера,вам перезвонил,оператор"
 	}
 
+	// Расписание запрашивается у всех филиалов, поэтому слова должны указывать
+	// на вопрос о занятиях: «сред» или «сегодня» срабатывали бы на «средний» и на любой вопрос
+	scheduleTriggerWords := util.GetEnvStringSlice("SCHEDULE_TRIGGER_WORDS", strings.Split("расписан,во сколько,когда занят,какие занятия,занятия сегодня,сегодня утром,сегодня вечером,завтра,понедельник,вторник,в среду,по средам,четверг,пятниц,суббот,воскресен", ","))
+
+	// Слова, по которым вопрос считается продолжением разговора и не берётся из кэша ответов
+	followUpTriggerWords := os.Getenv("FOLLOWUP_TRIGGER_WORDS")
+	if followUpTriggerWords == "" {
+		followUpTriggerWords = "а,и,ещё,еще,тогда,это,этот,эта,эти,этого,этом,там,туда,тут,он,она,оно,они,его,её,ее,их,ему,ей,им,такой,такие,also,and,it,that,this,these,those,there,them,they"
+	}
+
 	Settings = &AppSettings{
 		Preamble:                        os.Getenv("PREAMBLE"),
 		CallManagerTriggerWords:         strings.Split(callManagerTriggerWords, ","),
 		CallManagerTriggerWordsInAnswer: strings.Split(callManagerTriggerWordsInAnswer, ","),
+		ScheduleTriggerWords:            scheduleTriggerWords,
+		ScheduleContextDays:             util.GetEnvInt("SCHEDULE_CONTEXT_DAYS", 7),
+		FollowUpTriggerWords:            strings.Split(followUpTriggerWords, ","),
 	}
 
 	return Settings
@@ -23,3 +23,56 @@ func GetHistory(repo *repository.Repository, chatID int64) []HistoryItem {
 	}
 	return items
 }
+
+// GetUserHistory returns the history of one participant of a group chat.
+func GetUserHistory(repo *repository.Repository, chatID, userID int64) []HistoryItem {
+	items, err := repo.GetUserHistory(context.Background(), chatID, userID, 20)
+	if err != nil {
+		log.Printf("get history query error: %v", err)
+		return nil
+	}
+	return items
+}
+
+// AppendUserHistory stores a group chat message of the participant and returns its ID or 0 on error.
+func AppendUserHistory(repo *repository.Repository, chatID, userID int64, role, text string) int64 {
+	id, err := repo.AppendUserHistory(context.Background(), chatID, userID, role, text)
+	if err != nil {
+		log.Printf("append history error: %v", err)
+		return 0
+	}
+	return id
+}
+
+// AppendAnswer stores an assistant answer and returns its history ID or 0 on error.
+func AppendAnswer(repo *repository.Repository, chatID int64, text string) int64 {
+	id, err := repo.AppendHistoryReturningID(context.Background(), chatID, "assistant", text)
+	if err != nil {
+		log.Printf("append history error: %v", err)
+		return 0
+	}
+	return id
+}
+
+// SaveFeedback stores the user's rating of an answer.
+func SaveFeedback(repo *repository.Repository, chatID, historyID int64, rating int) bool {
+	ok, err := repo.SaveFeedback(context.Background(), chatID, historyID, rating)
+	if err != nil {
+		log.Printf("save feedback error: %v", err)
+		return false
+	}
+	return ok
+}
+
+func SaveFeedbackComment(repo *repository.Repository, chatID, historyID int64, comment string) {
+	if err := repo.SaveFeedbackComment(context.Background(), chatID, historyID, comment); err != nil {
+		log.Printf("save feedback comment error: %v", err)
+	}
+}
+
+// SaveAnswerChunks remembers the knowledge fragments an answer was based on.
+func SaveAnswerChunks(repo *repository.Repository, historyID int64, matches []repository.ChunkMatch) {
+	if err := repo.SaveAnswerChunks(context.Background(), historyID, matches); err != nil {
+		log.Printf("save answer chunks error: %v", err)
+	}
+}
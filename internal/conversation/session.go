@@ -3,15 +3,17 @@ package conversation
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 
+	"ragbot/internal/i18n"
 	"ragbot/internal/repository"
 )
 
 type ChatInfo = repository.ChatInfo
 
-func EnsureSession(repo *repository.Repository, chatID int64, username string) (string, error) {
-	uuid, err := repo.EnsureSession(context.Background(), chatID, username)
+func EnsureSession(repo *repository.Repository, chatID int64, username, language string) (string, error) {
+	uuid, err := repo.EnsureSession(context.Background(), chatID, username, language)
 	if err != nil {
 		log.Printf("ensure session error: %v", err)
 		return "", err
@@ -19,6 +21,16 @@ func EnsureSession(repo *repository.Repository, chatID int64, username string) (
 	return uuid, nil
 }
 
+// EnsureConversation returns the chat ID of the conversation with the user of the channel.
+func EnsureConversation(repo *repository.Repository, channel, externalID, username, language string) (int64, error) {
+	chatID, _, err := repo.EnsureConversation(context.Background(), channel, externalID, username, language)
+	if err != nil {
+		log.Printf("ensure conversation error: %v", err)
+		return 0, err
+	}
+	return chatID, nil
+}
+
 func GetChatInfoByChatID(repo *repository.Repository, chatID int64) (ChatInfo, error) {
 	return repo.GetChatInfoByChatID(context.Background(), chatID)
 }
@@ -27,6 +39,21 @@ func GetChatInfoByUUID(repo *repository.Repository, uuid string) (ChatInfo, erro
 	return repo.GetChatInfoByUUID(context.Background(), uuid)
 }
 
+// GetLocale returns the locale of the conversation language, empty if it is unknown.
+func GetLocale(repo *repository.Repository, chatID int64) string {
+	language, err := repo.GetChatLanguage(context.Background(), chatID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("get chat language error: %v", err)
+		}
+		return ""
+	}
+	if language == "" {
+		return ""
+	}
+	return i18n.Resolve(language)
+}
+
 func UpdateSummary(repo *repository.Repository, chatID int64, summary, title, interest string) {
 	if err := repo.UpdateSummary(context.Background(), chatID, summary, title, interest); err != nil {
 		log.Printf("update summary error: %v", err)
@@ -63,3 +90,22 @@ func GetFullHistory(repo *repository.Repository, chatID int64) []HistoryItem {
 	}
 	return items
 }
+
+func HasCallRequest(repo *repository.Repository, chatID int64) bool {
+	exists, err := repo.HasCallRequest(context.Background(), chatID)
+	if err != nil {
+		log.Printf("has call request query error: %v", err)
+		return false
+	}
+	return exists
+}
+
+// LinkVisit attributes the chat to the landing visit with the token from the /start payload.
+func LinkVisit(repo *repository.Repository, chatID int64, token string) bool {
+	ok, err := repo.LinkVisit(context.Background(), token, chatID)
+	if err != nil {
+		log.Printf("link visit error: %v", err)
+		return false
+	}
+	return ok
+}
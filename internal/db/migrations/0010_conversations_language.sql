@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS language TEXT;
+
+-- +goose Down
+ALTER TABLE conversations DROP COLUMN IF EXISTS language;
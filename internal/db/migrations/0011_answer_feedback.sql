@@ -0,0 +1,16 @@
+-- +goose Up
+-- Оценки ответов ассистента: rating = 1 (👍) или -1 (👎)
+CREATE TABLE IF NOT EXISTS answer_feedback (
+    id SERIAL PRIMARY KEY,
+    history_id INTEGER NOT NULL UNIQUE REFERENCES conversation_history(id) ON DELETE CASCADE,
+    chat_id BIGINT NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating IN (-1, 1)),
+    comment TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS answer_feedback_created_at_idx ON answer_feedback(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS answer_feedback;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Фрагменты базы знаний, найденные для ответа ассистента.
+-- chunk_id без внешнего ключа: удалённый фрагмент остаётся в истории ответа.
+CREATE TABLE IF NOT EXISTS answer_chunks (
+    history_id INTEGER NOT NULL REFERENCES conversation_history(id) ON DELETE CASCADE,
+    chunk_id INTEGER NOT NULL,
+    rank INTEGER NOT NULL,
+    distance REAL,
+    PRIMARY KEY (history_id, chunk_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS answer_chunks;
@@ -0,0 +1,64 @@
+-- +goose Up
+-- Кэш ответов на похожие вопросы. Вопрос хранится в нормализованном виде
+-- и как эмбеддинг той же размерности, что и chunks.embedding.
+CREATE TABLE IF NOT EXISTS answer_cache (
+    id SERIAL PRIMARY KEY,
+    question TEXT NOT NULL,
+    embedding VECTOR(1536) NOT NULL,
+    answer TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_hit_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS answer_cache_question_idx ON answer_cache(question);
+
+-- Фрагменты, на которых построен закэшированный ответ
+CREATE TABLE IF NOT EXISTS answer_cache_chunks (
+    cache_id INTEGER NOT NULL REFERENCES answer_cache(id) ON DELETE CASCADE,
+    chunk_id INTEGER NOT NULL,
+    rank INTEGER NOT NULL,
+    distance REAL,
+    PRIMARY KEY (cache_id, chunk_id)
+);
+
+CREATE INDEX IF NOT EXISTS answer_cache_chunks_chunk_id_idx ON answer_cache_chunks(chunk_id);
+
+-- Попадания, промахи и обходы кэша по дням
+CREATE TABLE IF NOT EXISTS answer_cache_stats (
+    day DATE PRIMARY KEY,
+    hits INTEGER NOT NULL DEFAULT 0,
+    misses INTEGER NOT NULL DEFAULT 0,
+    bypassed INTEGER NOT NULL DEFAULT 0
+);
+
+-- Ответ устаревает при изменении или удалении любого фрагмента, из которого он собран.
+-- Триггер срабатывает для всех источников знаний, а не только для админ-бота.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION invalidate_answer_cache() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM answer_cache
+    WHERE id IN (SELECT cache_id FROM answer_cache_chunks WHERE chunk_id = OLD.id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chunks_invalidate_answer_cache_update
+    AFTER UPDATE OF content ON chunks
+    FOR EACH ROW
+    WHEN (OLD.content IS DISTINCT FROM NEW.content)
+    EXECUTE FUNCTION invalidate_answer_cache();
+
+CREATE TRIGGER chunks_invalidate_answer_cache_delete
+    AFTER DELETE ON chunks
+    FOR EACH ROW
+    EXECUTE FUNCTION invalidate_answer_cache();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS chunks_invalidate_answer_cache_delete ON chunks;
+DROP TRIGGER IF EXISTS chunks_invalidate_answer_cache_update ON chunks;
+DROP FUNCTION IF EXISTS invalidate_answer_cache();
+DROP TABLE IF EXISTS answer_cache_stats;
+DROP TABLE IF EXISTS answer_cache_chunks;
+DROP TABLE IF EXISTS answer_cache;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Отписка от рассылок командой /unsubscribe и пользователи, заблокировавшие бота
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS unsubscribed_at TIMESTAMPTZ;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMPTZ;
+
+-- Рассылки из админ-бота: draft -> running -> done | canceled
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id SERIAL PRIMARY KEY,
+    text TEXT NOT NULL,
+    segment JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'draft',
+    created_by BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+-- Получатели рассылки фиксируются при запуске, поэтому её можно продолжить после перезапуска
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+    broadcast_id INTEGER NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    chat_id BIGINT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    sent_at TIMESTAMPTZ,
+    PRIMARY KEY (broadcast_id, chat_id)
+);
+
+CREATE INDEX IF NOT EXISTS broadcast_deliveries_status_idx ON broadcast_deliveries(broadcast_id, status);
+
+-- +goose Down
+DROP TABLE IF EXISTS broadcast_deliveries;
+DROP TABLE IF EXISTS broadcasts;
+ALTER TABLE conversations
+    DROP COLUMN IF EXISTS unsubscribed_at,
+    DROP COLUMN IF EXISTS blocked_at;
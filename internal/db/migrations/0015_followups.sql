@@ -0,0 +1,14 @@
+-- +goose Up
+-- Напоминания пользователям, которые перестали отвечать
+CREATE TABLE IF NOT EXISTS followups (
+    id SERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    rule TEXT NOT NULL,
+    text TEXT NOT NULL,
+    sent_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS followups_chat_id_idx ON followups(chat_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS followups;
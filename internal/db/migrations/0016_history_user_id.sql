@@ -0,0 +1,10 @@
+-- +goose Up
+-- Автор сообщения в групповых чатах: история ведётся отдельно для каждого участника группы.
+-- В личных чатах user_id не заполняется.
+ALTER TABLE conversation_history ADD COLUMN IF NOT EXISTS user_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS conversation_history_chat_user_idx ON conversation_history(chat_id, user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS conversation_history_chat_user_idx;
+ALTER TABLE conversation_history DROP COLUMN IF EXISTS user_id;
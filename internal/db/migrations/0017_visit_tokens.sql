@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS token TEXT UNIQUE;
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS chat_id BIGINT;
+ALTER TABLE visits ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS visits_chat_id_idx ON visits(chat_id);
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS visit_id INTEGER REFERENCES visits(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE conversations DROP COLUMN IF EXISTS visit_id;
+DROP INDEX IF EXISTS visits_chat_id_idx;
+ALTER TABLE visits DROP COLUMN IF EXISTS started_at;
+ALTER TABLE visits DROP COLUMN IF EXISTS chat_id;
+ALTER TABLE visits DROP COLUMN IF EXISTS token;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS channel TEXT NOT NULL DEFAULT 'telegram';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS external_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS conversations_channel_external_id_idx ON conversations(channel, external_id);
+
+-- Идентификаторы чатов веб-сессий выдаются выше диапазона идентификаторов Telegram
+CREATE SEQUENCE IF NOT EXISTS web_chat_id_seq START WITH 9000000000000000;
+
+-- +goose Down
+DROP SEQUENCE IF EXISTS web_chat_id_seq;
+DROP INDEX IF EXISTS conversations_channel_external_id_idx;
+ALTER TABLE conversations DROP COLUMN IF EXISTS external_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS channel;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Беседы определяются каналом и идентификатором пользователя в нём
+UPDATE conversations SET external_id = chat_id::text WHERE external_id IS NULL;
+ALTER TABLE conversations ALTER COLUMN external_id SET NOT NULL;
+ALTER SEQUENCE IF EXISTS web_chat_id_seq RENAME TO channel_chat_id_seq;
+
+-- +goose Down
+ALTER SEQUENCE IF EXISTS channel_chat_id_seq RENAME TO web_chat_id_seq;
+ALTER TABLE conversations ALTER COLUMN external_id DROP NOT NULL;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- История изменений фрагментов базы знаний: кто, когда и что изменил.
+-- chunk_id без внешнего ключа: удалённый фрагмент можно восстановить из истории.
+CREATE TABLE IF NOT EXISTS chunk_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    chunk_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    author TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    content_before TEXT NOT NULL DEFAULT '',
+    content_after TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS chunk_revisions_chunk_id_idx ON chunk_revisions(chunk_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS chunk_revisions;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Учётные записи администраторов. Один и тот же человек входит в админ-бот
+-- по chat_id и в веб-интерфейс по логину и паролю.
+CREATE TABLE IF NOT EXISTS admins (
+    id SERIAL PRIMARY KEY,
+    role TEXT NOT NULL,
+    chat_id BIGINT UNIQUE,
+    login TEXT UNIQUE,
+    password_hash TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS admins;
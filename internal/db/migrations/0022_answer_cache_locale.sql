@@ -0,0 +1,13 @@
+-- +goose Up
+-- Кэшированный ответ написан на языке промпта, поэтому выдаётся только
+-- вопросам той же локали. Старые записи могли быть собраны с историей
+-- разговора конкретного пользователя и удаляются.
+DELETE FROM answer_cache;
+ALTER TABLE answer_cache ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS answer_cache_question_idx;
+CREATE INDEX IF NOT EXISTS answer_cache_question_idx ON answer_cache(locale, question);
+
+-- +goose Down
+DROP INDEX IF EXISTS answer_cache_question_idx;
+ALTER TABLE answer_cache DROP COLUMN IF EXISTS locale;
+CREATE INDEX IF NOT EXISTS answer_cache_question_idx ON answer_cache(question);
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Неудачные попытки отправки тоже записываются, чтобы беседа не выбиралась
+-- заново при каждой проверке. В лимит и статистику они не входят.
+ALTER TABLE followups ADD COLUMN IF NOT EXISTS failed BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE followups DROP COLUMN IF EXISTS failed;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Внешний ID фрагмента, который синхронизируется из источника. Такие фрагменты
+-- не восстанавливаются из истории: синхронизация создала бы рядом копию без
+-- ext_id или откатила бы восстановленную версию. Для уже удалённых фрагментов
+-- внешний ID неизвестен.
+ALTER TABLE chunk_revisions ADD COLUMN IF NOT EXISTS ext_id TEXT NOT NULL DEFAULT '';
+UPDATE chunk_revisions r SET ext_id = c.ext_id
+FROM chunks c
+WHERE c.id = r.chunk_id AND c.ext_id IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE chunk_revisions DROP COLUMN IF EXISTS ext_id;
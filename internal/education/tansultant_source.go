@@ -0,0 +1,153 @@
+package education
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ragbot/internal/repository"
+	"ragbot/internal/tansultant"
+	"ragbot/internal/util"
+)
+
+const tansultantSource = "tansultant"
+
+// TansultantSource keeps branches and prices from the Tansultant API in chunks.
+// Every entry is keyed by ext_id, so changes and removals follow the API.
+type TansultantSource struct {
+	Client   *tansultant.Client
+	Interval time.Duration
+}
+
+func (t *TansultantSource) Start(ctx context.Context, repo *repository.Repository) {
+	go t.run(ctx, repo)
+}
+
+func (t *TansultantSource) run(ctx context.Context, repo *repository.Repository) {
+	defer util.Recover("TansultantSource.run")
+	t.process(ctx, repo)
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.process(ctx, repo)
+		}
+	}
+}
+
+func (t *TansultantSource) process(ctx context.Context, repo *repository.Repository) {
+	defer util.Recover("TansultantSource.process")
+
+	// Записи удаляются только после успешного ответа API, иначе сбой
+	// сервиса стёр бы из базы знаний все адреса и цены.
+	contents := make(map[string]string)
+	complete := true
+	branches, err := t.Client.Branches(ctx)
+	if err != nil && !errors.Is(err, tansultant.ErrNotConfigured) {
+		log.Printf("tansultant source branches error: %v", err)
+		complete = false
+	}
+	for _, b := range branches {
+		contents[fmt.Sprintf("branch:%d", b.ID)] = branchContent(b)
+	}
+	prices, err := t.Client.Prices(ctx)
+	if err != nil && !errors.Is(err, tansultant.ErrNotConfigured) {
+		log.Printf("tansultant source prices error: %v", err)
+		complete = false
+	}
+	for _, p := range prices {
+		contents["price:"+p.ID] = priceContent(p)
+	}
+
+	existing, err := repo.ListChunkExtIDs(ctx, tansultantSource)
+	if err != nil {
+		log.Printf("tansultant source list error: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for extID, content := range contents {
+		id, _, oldContent, found, err := repo.GetChunkByExtID(ctx, tansultantSource, extID)
+		if err != nil {
+			log.Printf("tansultant source select error: %v", err)
+			continue
+		}
+		if !found {
+			if err := repo.InsertChunkWithExtID(ctx, content, tansultantSource, extID, now); err != nil {
+				log.Printf("tansultant source insert error: %v", err)
+			} else {
+				log.Printf("Chunk added from tansultant: %s", extID)
+			}
+			continue
+		}
+		if oldContent != content {
+			if err := repo.UpdateChunkWithCreatedAt(ctx, id, content, now); err != nil {
+				log.Printf("tansultant source update error: %v", err)
+			} else {
+				log.Printf("Chunk updated from tansultant: %s", extID)
+			}
+		}
+	}
+
+	if !complete {
+		return
+	}
+	for extID, id := range existing {
+		if _, ok := contents[extID]; ok {
+			continue
+		}
+		if _, err := repo.DeleteChunk(ctx, id); err != nil {
+			log.Printf("tansultant source delete error: %v", err)
+		} else {
+			log.Printf("Chunk deleted from tansultant: %s", extID)
+		}
+	}
+}
+
+func branchContent(b tansultant.Branch) string {
+	title := b.Title
+	if title == "" {
+		title = b.Name
+	}
+	parts := []string{"Филиал «" + title + "»"}
+	if b.Address != "" {
+		parts = append(parts, "адрес: "+b.Address)
+	}
+	if b.Phone != "" {
+		parts = append(parts, "телефон: "+b.Phone)
+	}
+	if b.ScheduleLink != "" {
+		parts = append(parts, "расписание занятий: "+b.ScheduleLink)
+	}
+	return strings.Join(parts, ", ") + "."
+}
+
+func priceContent(p tansultant.Price) string {
+	parts := []string{"Абонемент «" + p.Name + "»"}
+	if p.Price != "" {
+		parts = append(parts, "стоимость "+p.Price+" ₽")
+	}
+	if p.Hours != "" {
+		parts = append(parts, "занятий: "+p.Hours)
+	}
+	if p.Lifetime != "" {
+		parts = append(parts, "срок действия: "+p.Lifetime+" дн.")
+	}
+	if p.FreezeAllowed != "" {
+		parts = append(parts, "можно заморозить на 30 дней")
+	}
+	if p.GuestVisits != "" {
+		parts = append(parts, "гостевых посещений для друзей: "+p.GuestVisits)
+	}
+	content := strings.Join(parts, ", ") + "."
+	if desc := strings.TrimSpace(p.Description); desc != "" {
+		content += " " + desc
+	}
+	return content
+}
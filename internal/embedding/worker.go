@@ -2,6 +2,7 @@ package embedding
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -31,14 +32,22 @@ func process(repo *repository.Repository, aiClient *ai.AIClient) {
 		return
 	}
 	for _, ch := range chunks {
-		emb, err := aiClient.GenerateEmbedding(ch.Content)
-		if err != nil {
-			log.Printf("embedding generation error: %v", err)
-			continue
-		}
-		if err := repo.UpdateChunkEmbedding(context.Background(), ch.ID, emb); err != nil {
-			log.Printf("embedding update error: %v", err)
+		if err := EmbedChunk(context.Background(), repo, aiClient, ch.ID, ch.Content); err != nil {
+			log.Printf("embedding error: %v", err)
 		}
 		time.Sleep(200 * time.Millisecond)
 	}
 }
+
+// EmbedChunk generates and stores the embedding of a chunk right away,
+// so that it becomes searchable without waiting for the worker.
+func EmbedChunk(ctx context.Context, repo *repository.Repository, aiClient *ai.AIClient, id int, content string) error {
+	emb, err := aiClient.GenerateEmbedding(content)
+	if err != nil {
+		return fmt.Errorf("embedding generation error: %w", err)
+	}
+	if err := repo.UpdateChunkEmbedding(ctx, id, emb); err != nil {
+		return fmt.Errorf("embedding update error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package followup
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ragbot/internal/util"
+)
+
+type fc struct {
+	rules           []Rule
+	maxPerChat      int
+	quietFrom       int
+	quietTo         int
+	interval        time.Duration
+	attributionDays int
+}
+
+var followUpConfig *fc
+
+func loadConfig() {
+	from, to := loadQuietHours()
+	followUpConfig = &fc{
+		rules:           loadRules(),
+		maxPerChat:      util.GetEnvInt("FOLLOWUP_MAX_PER_CHAT", 2),
+		quietFrom:       from,
+		quietTo:         to,
+		interval:        time.Duration(util.GetEnvInt("FOLLOWUP_CHECK_INTERVAL", 600)) * time.Second,
+		attributionDays: util.GetEnvInt("FOLLOWUP_ATTRIBUTION_DAYS", 7),
+	}
+}
+
+// loadRules читает правила из FOLLOWUP_RULES: JSON-массив объектов Rule.
+func loadRules() []Rule {
+	raw := util.GetEnvString("FOLLOWUP_RULES", "")
+	if raw == "" {
+		return nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("Invalid FOLLOWUP_RULES: %v", err)
+		return nil
+	}
+	valid := rules[:0]
+	for _, r := range rules {
+		if r.Name == "" || r.AfterHours <= 0 {
+			log.Printf("Skipping follow-up rule %q: name and after_hours are required", r.Name)
+			continue
+		}
+		valid = append(valid, r)
+	}
+	return valid
+}
+
+// loadQuietHours читает FOLLOWUP_QUIET_HOURS в виде "21-10": часы начала и конца
+// периода, когда напоминания не отправляются. Пустое значение — без ограничений.
+func loadQuietHours() (int, int) {
+	raw := util.GetEnvString("FOLLOWUP_QUIET_HOURS", "21-10")
+	from, to, ok := parseQuietHours(raw)
+	if !ok {
+		log.Printf("Invalid FOLLOWUP_QUIET_HOURS: %q", raw)
+	}
+	return from, to
+}
+
+func parseQuietHours(raw string) (int, int, bool) {
+	if strings.TrimSpace(raw) == "" {
+		return 0, 0, true
+	}
+	fromStr, toStr, found := strings.Cut(raw, "-")
+	from, err1 := strconv.Atoi(strings.TrimSpace(fromStr))
+	to, err2 := strconv.Atoi(strings.TrimSpace(toStr))
+	if !found || err1 != nil || err2 != nil || from < 0 || from > 23 || to < 0 || to > 23 {
+		return 0, 0, false
+	}
+	return from, to, true
+}
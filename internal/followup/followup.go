@@ -0,0 +1,177 @@
+package followup
+
+import (
+	"context"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"ragbot/internal/ai"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+	"ragbot/internal/util"
+)
+
+// Ключи каталога сообщений
+const (
+	msgFollowUpDefault = "followup_default"
+	promptFollowUp     = "prompt_followup"
+)
+
+const (
+	defaultWindowHours = 72
+	batchSize          = 20
+)
+
+// Rule describes when and what to send to a user who went quiet.
+type Rule struct {
+	// Name identifies the rule in stats
+	Name string `json:"name"`
+	// AfterHours is the silence after the last user message before the follow-up
+	AfterHours float64 `json:"after_hours"`
+	// WindowHours limits how long after AfterHours the chat is still nudged (72 by default)
+	WindowHours float64 `json:"window_hours"`
+	// TriggerWords select chats where the user wrote any of them; empty means any chat
+	TriggerWords []string `json:"trigger_words"`
+	// Template is a text/template with .Name, .Interest and .Summary;
+	// empty means the followup_default catalog message
+	Template string `json:"template"`
+	// Personalize rewrites the text with the LLM using the chat summary and interest
+	Personalize bool `json:"personalize"`
+}
+
+// Sender delivers a follow-up to a chat.
+type Sender func(chatID int64, text string) error
+
+// StartWorker runs a goroutine that periodically sends follow-ups by FOLLOWUP_RULES.
+func StartWorker(repo *repository.Repository, aiClient *ai.AIClient, send Sender) {
+	if followUpConfig == nil {
+		loadConfig()
+	}
+	if len(followUpConfig.rules) == 0 {
+		log.Println("Follow-ups are disabled: FOLLOWUP_RULES is empty")
+		return
+	}
+	go func() {
+		defer util.Recover("followup worker")
+		ticker := time.NewTicker(followUpConfig.interval)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			process(repo, aiClient, send)
+		}
+	}()
+}
+
+// AttributionDays returns how many days after a follow-up a lead is attributed to it.
+func AttributionDays() int {
+	if followUpConfig == nil {
+		loadConfig()
+	}
+	return followUpConfig.attributionDays
+}
+
+func process(repo *repository.Repository, aiClient *ai.AIClient, send Sender) {
+	defer util.Recover("followup process")
+	if inQuietHours(time.Now().Hour(), followUpConfig.quietFrom, followUpConfig.quietTo) {
+		return
+	}
+	ctx := context.Background()
+	notified := make(map[int64]bool)
+	for _, rule := range followUpConfig.rules {
+		window := rule.WindowHours
+		if window <= 0 {
+			window = defaultWindowHours
+		}
+		candidates, err := repo.FollowUpCandidates(ctx, repository.FollowUpQuery{
+			Rule:         rule.Name,
+			After:        hours(rule.AfterHours),
+			Window:       hours(window),
+			TriggerWords: rule.TriggerWords,
+			MaxPerChat:   followUpConfig.maxPerChat,
+			Limit:        batchSize,
+		})
+		if err != nil {
+			log.Printf("follow-up candidates error for rule %s: %v", rule.Name, err)
+			continue
+		}
+		for _, c := range candidates {
+			// Беседа получает не больше одного напоминания за проверку,
+			// даже если подходит под несколько правил
+			if notified[c.ChatID] {
+				continue
+			}
+			notified[c.ChatID] = true
+			text := compose(aiClient, rule, c)
+			sendErr := send(c.ChatID, text)
+			if sendErr != nil {
+				log.Printf("follow-up %s to %d failed: %v", rule.Name, c.ChatID, sendErr)
+			}
+			// Неудачная попытка тоже записывается, иначе беседа выбиралась бы при каждой проверке
+			if err := repo.SaveFollowUp(ctx, c.ChatID, rule.Name, text, sendErr != nil); err != nil {
+				log.Printf("follow-up save error: %v", err)
+			}
+			if sendErr == nil {
+				conversation.AppendHistory(repo, c.ChatID, "assistant", text)
+			}
+		}
+	}
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// inQuietHours reports whether hour falls into [from, to), which may wrap midnight.
+func inQuietHours(hour, from, to int) bool {
+	if from == to {
+		return false
+	}
+	if from < to {
+		return hour >= from && hour < to
+	}
+	return hour >= from || hour < to
+}
+
+// compose renders the rule text and personalizes it with the LLM when enabled.
+// On any LLM error the rendered template is used.
+func compose(aiClient *ai.AIClient, rule Rule, c repository.FollowUpCandidate) string {
+	locale := i18n.Resolve(c.Language)
+	vars := i18n.Vars{"Name": c.Name, "Interest": c.Interest, "Summary": c.Summary}
+	text := render(locale, rule.Template, vars)
+	if !rule.Personalize || aiClient == nil || (c.Summary == "" && c.Interest == "") {
+		return text
+	}
+	prompt := i18n.T(locale, promptFollowUp, i18n.Vars{
+		"Preamble": config.LoadSettings().Preamble,
+		"Summary":  c.Summary,
+		"Interest": c.Interest,
+		"Draft":    text,
+	})
+	personal, err := aiClient.GenerateResponse(prompt)
+	if err != nil || strings.TrimSpace(personal) == "" {
+		log.Printf("follow-up personalization error: %v", err)
+		return text
+	}
+	return strings.TrimSpace(personal)
+}
+
+func render(locale, tmpl string, vars i18n.Vars) string {
+	if tmpl == "" {
+		return i18n.T(locale, msgFollowUpDefault, vars)
+	}
+	t, err := template.New("followup").Parse(tmpl)
+	if err != nil {
+		log.Printf("Invalid follow-up template: %v", err)
+		return i18n.T(locale, msgFollowUpDefault, vars)
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, vars); err != nil {
+		log.Printf("Follow-up template error: %v", err)
+		return i18n.T(locale, msgFollowUpDefault, vars)
+	}
+	return sb.String()
+}
@@ -0,0 +1,51 @@
+package followup
+
+import (
+	"testing"
+
+	"ragbot/internal/i18n"
+)
+
+func TestInQuietHours(t *testing.T) {
+	cases := []struct {
+		hour, from, to int
+		want           bool
+	}{
+		{22, 21, 10, true},
+		{3, 21, 10, true},
+		{10, 21, 10, false},
+		{15, 21, 10, false},
+		{13, 13, 15, true},
+		{15, 13, 15, false},
+		{3, 0, 0, false},
+	}
+	for _, c := range cases {
+		if got := inQuietHours(c.hour, c.from, c.to); got != c.want {
+			t.Errorf("inQuietHours(%d, %d, %d) = %v, want %v", c.hour, c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	if from, to, ok := parseQuietHours("21-10"); !ok || from != 21 || to != 10 {
+		t.Fatalf("unexpected quiet hours %d-%d, %v", from, to, ok)
+	}
+	if _, _, ok := parseQuietHours(""); !ok {
+		t.Fatal("empty quiet hours should be valid")
+	}
+	for _, raw := range []string{"21", "25-10", "a-b"} {
+		if _, _, ok := parseQuietHours(raw); ok {
+			t.Errorf("expected %q to be invalid", raw)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	vars := i18n.Vars{"Name": "Анна", "Interest": "бачата"}
+	if got := render("ru", "{{.Name}}, ждём вас на {{.Interest}}!", vars); got != "Анна, ждём вас на бачата!" {
+		t.Fatalf("unexpected text %q", got)
+	}
+	if got := render("ru", "{{.Name", vars); got != i18n.T("ru", msgFollowUpDefault, vars) {
+		t.Fatalf("invalid template should fall back to the catalog text, got %q", got)
+	}
+}
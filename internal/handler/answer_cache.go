@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"ragbot/internal/config"
+	"ragbot/internal/repository"
+)
+
+// Кэш ответов: похожий вопрос без контекста разговора получает недавний ответ
+// той же локали без обращения к модели. Промпт кэшируемого ответа собирается
+// без истории разговора, чтобы данные одного пользователя не попали к другим.
+// Записи удаляются триггером при изменении фрагментов.
+
+// normalizeQuestion приводит вопрос к виду для точного сравнения:
+// нижний регистр, без знаков препинания и лишних пробелов.
+func normalizeQuestion(question string) string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// isFollowUp reports whether the question depends on the previous conversation:
+// a very short question or one with referring words after earlier user messages.
+func isFollowUp(question string, history []repository.HistoryItem) bool {
+	asked := false
+	for _, item := range history {
+		if item.Role == "user" {
+			asked = true
+			break
+		}
+	}
+	if !asked {
+		return false
+	}
+	words := strings.Fields(normalizeQuestion(question))
+	if len(words) <= 2 {
+		return true
+	}
+	for _, w := range words {
+		for _, trigger := range config.LoadSettings().FollowUpTriggerWords {
+			if w == strings.TrimSpace(trigger) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func answerCacheEnabled() bool {
+	return config.Config != nil && config.Config.AnswerCacheEnabled
+}
+
+func countAnswerCacheEvent(repo *repository.Repository, kind string) {
+	if err := repo.CountAnswerCacheEvent(context.Background(), kind); err != nil {
+		log.Printf("answer cache stats error: %v", err)
+	}
+}
+
+// cachedAnswer looks up an answer in locale by the normalized question or, when vec is set,
+// by the closest question embedding.
+func cachedAnswer(repo *repository.Repository, locale, question string, vec []float32) (Answer, bool) {
+	ctx := context.Background()
+	since := time.Now().Add(-config.Config.AnswerCacheTTL)
+	var (
+		cached repository.CachedAnswer
+		found  bool
+		err    error
+	)
+	if vec == nil {
+		cached, found, err = repo.FindCachedAnswerByQuestion(ctx, locale, question, since)
+	} else {
+		cached, found, err = repo.FindCachedAnswer(ctx, locale, vec, config.Config.AnswerCacheMaxDistance, since)
+	}
+	if err != nil {
+		log.Printf("answer cache lookup error: %v", err)
+		return Answer{}, false
+	}
+	if !found {
+		return Answer{}, false
+	}
+	if err := repo.MarkCachedAnswerHit(ctx, cached.ID); err != nil {
+		log.Printf("answer cache hit error: %v", err)
+	}
+	countAnswerCacheEvent(repo, repository.AnswerCacheHit)
+	log.Printf("Answer cache hit #%d (%.3f) for %q", cached.ID, cached.Distance, question)
+	return Answer{Text: cached.Answer, Chunks: cached.Chunks}, true
+}
+
+func saveCachedAnswer(repo *repository.Repository, locale, question string, vec []float32, answer Answer) {
+	countAnswerCacheEvent(repo, repository.AnswerCacheMiss)
+	expireBefore := time.Now().Add(-config.Config.AnswerCacheTTL)
+	saved, err := repo.SaveCachedAnswer(context.Background(), locale, question, vec, answer.Text, answer.Chunks, expireBefore)
+	if err != nil {
+		log.Printf("answer cache save error: %v", err)
+	} else if !saved {
+		log.Println("answer cache: chunks changed while answering, not caching")
+	}
+}
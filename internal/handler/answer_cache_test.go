@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"testing"
+
+	"ragbot/internal/repository"
+)
+
+func TestNormalizeQuestion(t *testing.T) {
+	got := normalizeQuestion("  Сколько стоит   абонемент?! ")
+	if got != "сколько стоит абонемент" {
+		t.Fatalf("unexpected normalized question %q", got)
+	}
+}
+
+func TestIsFollowUp(t *testing.T) {
+	history := []repository.HistoryItem{
+		{Role: "user", Content: "Сколько стоит абонемент?"},
+		{Role: "assistant", Content: "Абонемент на 8 занятий стоит 5000 рублей."},
+	}
+	cases := []struct {
+		question string
+		history  []repository.HistoryItem
+		want     bool
+	}{
+		{"а на 12?", history, true},
+		{"А сколько стоит это в другой студии?", history, true},
+		{"Где вы находитесь в Москве?", history, false},
+		{"а на 12?", nil, false},
+	}
+	for _, c := range cases {
+		if got := isFollowUp(c.question, c.history); got != c.want {
+			t.Errorf("isFollowUp(%q) = %v, want %v", c.question, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"ragbot/internal/ai"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+	"ragbot/internal/util"
+)
+
+// CallbackRequester registers a call-me-back request with the visitor's contacts.
+type CallbackRequester func(chatID int64, name, phone string) error
+
+const (
+	apiKeyHeader        = "X-API-Key"
+	sessionHeader       = "X-Session-Token"
+	sessionCookie       = "ragbot_session"
+	sessionTokenBytes   = 24
+	maxQuestionLength   = 2000
+	maxContactLength    = 100
+	sessionCookieMaxAge = 365 * 24 * 60 * 60
+	// maxRequestBody fits the longest question even with escaped characters
+	maxRequestBody = 32 << 10
+)
+
+// Ограничители частоты запросов веб-API, см. initWebAPILimits
+var (
+	sessionLimiter    *rateLimiter
+	questionLimiter   *rateLimiter
+	ipQuestionLimiter *rateLimiter
+)
+
+// initWebAPILimits creates the web API rate limiters from the configuration.
+func initWebAPILimits() {
+	sessionLimiter = newRateLimiter(config.Config.WebAPISessionsPerHour, time.Hour)
+	questionLimiter = newRateLimiter(config.Config.WebAPIQuestionsPerMinute, time.Minute)
+	ipQuestionLimiter = newRateLimiter(config.Config.WebAPIIPQuestionsPerMinute, time.Minute)
+	trustedProxies = parseTrustedProxies(config.Config.TrustedProxies)
+}
+
+type sessionRequest struct {
+	Language string `json:"language"`
+}
+
+type sessionResponse struct {
+	Session string `json:"session"`
+	// Texts are the widget interface texts in the visitor's language
+	Texts map[string]string `json:"texts"`
+}
+
+type historyMessage struct {
+	ID   int64  `json:"id"`
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+type historyResponse struct {
+	Messages []historyMessage `json:"messages"`
+}
+
+type callbackRequest struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write json error: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// withWebAPI allows the request only from an origin listed in WEB_API_KEYS
+// with the API key of that origin and answers CORS preflight requests.
+func withWebAPI(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer util.Recover("web api " + r.URL.Path)
+		origin := r.Header.Get("Origin")
+		key, ok := config.Config.WebAPIKeys[origin]
+		if !ok || origin == "" {
+			writeJSONError(w, http.StatusForbidden, "origin is not allowed")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", method+", OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+apiKeyHeader+", "+sessionHeader)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Method != method {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(apiKeyHeader)), []byte(key)) != 1 {
+			writeJSONError(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+		next(w, r)
+	}
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionToken returns the token from the X-Session-Token header or the session cookie.
+func sessionToken(r *http.Request) string {
+	if token := r.Header.Get(sessionHeader); token != "" {
+		return token
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// webSession returns the chat ID of the request session and writes
+// an error response when there is no valid session.
+func webSession(w http.ResponseWriter, r *http.Request, repo *repository.Repository) (int64, bool) {
+	token := sessionToken(r)
+	if token == "" {
+		writeJSONError(w, http.StatusUnauthorized, "session required")
+		return 0, false
+	}
+	chatID, err := repo.GetWebSession(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSONError(w, http.StatusUnauthorized, "unknown session")
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("get web session error: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+		return 0, false
+	}
+	return chatID, true
+}
+
+// APISession creates an anonymous visitor session or returns the current one.
+// The token is returned in the body and set as a cookie.
+func APISession(repo *repository.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req sessionRequest
+		if r.ContentLength != 0 && !readJSON(w, r, &req) {
+			return
+		}
+		texts := widgetTexts(i18n.Resolve(req.Language))
+		if token := sessionToken(r); token != "" {
+			if _, err := repo.GetWebSession(r.Context(), token); err == nil {
+				writeJSON(w, http.StatusOK, sessionResponse{Session: token, Texts: texts})
+				return
+			}
+		}
+		if !sessionLimiter.allow(clientIP(r), time.Now()) {
+			writeJSONError(w, http.StatusTooManyRequests, "too many sessions")
+			return
+		}
+		token, err := newSessionToken()
+		if err != nil {
+			log.Printf("session token error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if _, err := repo.CreateWebSession(r.Context(), token, req.Language); err != nil {
+			log.Printf("create web session error: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    token,
+			Path:     "/api/",
+			MaxAge:   sessionCookieMaxAge,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteNoneMode,
+		})
+		writeJSON(w, http.StatusCreated, sessionResponse{Session: token, Texts: texts})
+	}
+}
+
+// APIAsk answers a visitor question with the conversation history of the session.
+func APIAsk(repo *repository.Repository, aiClient *ai.AIClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, ok := webSession(w, r, repo)
+		if !ok || !allowQuestion(w, r, chatID) {
+			return
+		}
+		question, ok := readQuestion(w, r)
+		if !ok {
+			return
+		}
+		result, err := ProcessQuestionWithSources(repo, aiClient, chatID, conversation.GetLocale(repo, chatID), question)
+		if err != nil {
+			log.Printf("web api answer error: %v", err)
+			writeJSONError(w, http.StatusBadGateway, "failed to generate an answer")
+			return
+		}
+		writeJSON(w, http.StatusOK, saveWebAnswer(repo, chatID, question, result))
+	}
+}
+
+// readJSON decodes the request body and writes an error response when it
+// is not valid JSON or exceeds maxRequestBody.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "request is too large")
+		return false
+	case err != nil:
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON")
+		return false
+	}
+	return true
+}
+
+// allowQuestion applies the question limits of the session and of the visitor address.
+func allowQuestion(w http.ResponseWriter, r *http.Request, chatID int64) bool {
+	now := time.Now()
+	if !questionLimiter.allow(strconv.FormatInt(chatID, 10), now) || !ipQuestionLimiter.allow(clientIP(r), now) {
+		writeJSONError(w, http.StatusTooManyRequests, "too many questions")
+		return false
+	}
+	return true
+}
+
+// readQuestion decodes and validates the question of the request.
+func readQuestion(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req QueryRequest
+	if !readJSON(w, r, &req) {
+		return "", false
+	}
+	question := strings.TrimSpace(req.Question)
+	if question == "" || utf8.RuneCountInString(question) > maxQuestionLength {
+		writeJSONError(w, http.StatusBadRequest, "question is empty or too long")
+		return "", false
+	}
+	return question, true
+}
+
+// saveWebAnswer stores the question and the answer in the history of the session.
+func saveWebAnswer(repo *repository.Repository, chatID int64, question string, result Answer) QueryResponse {
+	conversation.AppendHistory(repo, chatID, "user", question)
+	historyID := conversation.AppendAnswer(repo, chatID, result.Text)
+	if historyID != 0 {
+		conversation.SaveAnswerChunks(repo, historyID, result.Chunks)
+	}
+	settings := config.LoadSettings()
+	offer := util.ContainsStringFromSlice(strings.ToLower(question), settings.CallManagerTriggerWords) ||
+		util.ContainsStringFromSlice(strings.ToLower(result.Text), settings.CallManagerTriggerWordsInAnswer)
+	return QueryResponse{Answer: result.Text, HistoryID: historyID, OfferCallback: offer}
+}
+
+// APIHistory returns the messages of the session. Service records such as
+// a call request and widget actions are not shown to the visitor.
+func APIHistory(repo *repository.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, ok := webSession(w, r, repo)
+		if !ok {
+			return
+		}
+		resp := historyResponse{Messages: []historyMessage{}}
+		for _, item := range conversation.GetFullHistory(repo, chatID) {
+			if strings.HasPrefix(item.Content, "**") || strings.HasPrefix(item.Content, "/") {
+				continue
+			}
+			resp.Messages = append(resp.Messages, historyMessage{ID: item.ID, Role: item.Role, Text: item.Content})
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// APICallback stores the visitor's contacts and passes the call-me-back request to managers.
+func APICallback(repo *repository.Repository, requestCallback CallbackRequester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, ok := webSession(w, r, repo)
+		if !ok {
+			return
+		}
+		var req callbackRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		phone := strings.TrimSpace(req.Phone)
+		if name == "" || phone == "" || utf8.RuneCountInString(name) > maxContactLength || utf8.RuneCountInString(phone) > maxContactLength {
+			writeJSONError(w, http.StatusBadRequest, "name and phone are required")
+			return
+		}
+		if err := requestCallback(chatID, name, phone); err != nil {
+			writeJSONError(w, http.StatusBadGateway, "failed to register the request")
+			return
+		}
+		writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+	}
+}
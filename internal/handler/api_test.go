@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ragbot/internal/config"
+)
+
+func TestWithWebAPI(t *testing.T) {
+	config.Config = &config.AppConfig{WebAPIKeys: map[string]string{"https://school.example": "secret"}}
+	called := false
+	h := withWebAPI(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name   string
+		method string
+		origin string
+		key    string
+		want   int
+		called bool
+	}{
+		{"unknown origin", http.MethodPost, "https://other.example", "secret", http.StatusForbidden, false},
+		{"no origin", http.MethodPost, "", "secret", http.StatusForbidden, false},
+		{"preflight", http.MethodOptions, "https://school.example", "", http.StatusNoContent, false},
+		{"wrong key", http.MethodPost, "https://school.example", "other", http.StatusUnauthorized, false},
+		{"wrong method", http.MethodGet, "https://school.example", "secret", http.StatusMethodNotAllowed, false},
+		{"allowed", http.MethodPost, "https://school.example", "secret", http.StatusOK, true},
+	}
+	for _, c := range cases {
+		called = false
+		req := httptest.NewRequest(c.method, "/api/ask", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		req.Header.Set(apiKeyHeader, c.key)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != c.want || called != c.called {
+			t.Errorf("%s: got status %d, called %v; want %d, %v", c.name, rec.Code, called, c.want, c.called)
+		}
+		if c.want != http.StatusForbidden && rec.Header().Get("Access-Control-Allow-Origin") != c.origin {
+			t.Errorf("%s: unexpected CORS origin %q", c.name, rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
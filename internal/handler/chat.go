@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"ragbot/internal/access"
 	"ragbot/internal/conversation"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
@@ -48,9 +49,11 @@ var chatTemplate = template.Must(template.New("chat").Parse(`<!DOCTYPE html>
 				<span>{{.Content}}</span>
 			</div>
 			{{else}}
-			<div class="bg-gray-100 dark:bg-gray-900/40 rounded p-3 mb-2">
+			<div id="m{{.ID}}" class="bg-gray-100 dark:bg-gray-900/40 rounded p-3 mb-2">
 				<span class="text-blue-600 dark:text-blue-400">Ассистент:</span>
 				<span>{{.Content}}</span>
+				{{if gt .Rating 0}}<span title="Ответ помог">👍</span>{{else if lt .Rating 0}}<span title="Ответ не помог">👎</span>{{end}}
+				<a href="#m{{.ID}}" class="text-xs text-gray-400" title="/fix {{.ID}}">#{{.ID}}</a>
 			</div>
 			{{end}}
         {{end}}
@@ -62,6 +65,9 @@ var chatTemplate = template.Must(template.New("chat").Parse(`<!DOCTYPE html>
 func ChatHandler(repo *repository.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer util.Recover("ChatHandler")
+		if _, ok := authorize(repo, w, r, access.ViewTranscripts); !ok {
+			return
+		}
 		uuid := strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), "/chat/")
 		info, err := conversation.GetChatInfoByUUID(repo, uuid)
 		if err != nil {
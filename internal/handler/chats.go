@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"ragbot/internal/access"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
 )
@@ -26,6 +27,7 @@ var chatsTemplate = template.Must(template.New("chats").Parse(`<!DOCTYPE html>
         <thead class="bg-gray-200 dark:bg-gray-700">
         <tr>
             <th class="px-4 py-2 text-left">Дата</th>
+            <th class="px-4 py-2 text-left">Канал</th>
             <th class="px-4 py-2 text-left">Пользователь</th>
             <th class="px-4 py-2 text-left">Чат</th>
             <th class="px-4 py-2 text-left">Лид</th>
@@ -36,6 +38,7 @@ var chatsTemplate = template.Must(template.New("chats").Parse(`<!DOCTYPE html>
         {{range .Chats}}
         <tr class="border-t border-gray-200 dark:border-gray-700">
             <td class="px-4 py-2 whitespace-nowrap"><a class="text-blue-600 dark:text-blue-400" href="/chat/{{.ID}}">{{.LastAt.Format "2006-01-02 15:04"}}</a></td>
+            <td class="px-4 py-2 whitespace-nowrap">{{if eq .Channel "web"}}Сайт{{else if eq .Channel "vk"}}ВКонтакте{{else}}Telegram{{end}}</td>
             <td class="px-4 py-2 whitespace-nowrap">
 			{{if .Name.Valid}}
 				{{.Name.String}}
@@ -69,7 +72,7 @@ func ChatsHandler(repo *repository.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer util.Recover("ChatsHandler")
 
-		if !authorize(w, r) {
+		if _, ok := authorize(repo, w, r, access.ViewTranscripts); !ok {
 			return
 		}
 
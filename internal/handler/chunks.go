@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ragbot/internal/access"
+	"ragbot/internal/repository"
+	"ragbot/internal/util"
+)
+
+var chunkHistoryTemplate = template.Must(template.New("chunkHistory").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <script src="https://cdn.tailwindcss.com"></script>
+    <script>tailwind.config={darkMode:'media'}</script>
+    <title>{{.Title}}</title>
+</head>
+<body class="min-h-screen bg-gray-100 dark:bg-gray-900 text-gray-900 dark:text-gray-100">
+<div class="max-w-4xl mx-auto p-4 space-y-4">
+    <h1 class="text-2xl font-bold">{{.Title}}</h1>
+    <form method="get" class="flex gap-2">
+        <input name="id" type="number" min="1" value="{{if .ChunkID}}{{.ChunkID}}{{end}}" placeholder="ID фрагмента" class="px-3 py-2 rounded bg-white dark:bg-gray-800">
+        <button class="px-3 py-2 rounded bg-blue-600 text-white">Показать</button>
+        {{if .ChunkID}}<a class="px-3 py-2 text-blue-600 dark:text-blue-400" href="?">Все изменения</a>{{end}}
+    </form>
+    {{if not .Revisions}}<p>Изменений не найдено.</p>{{end}}
+    {{range .Revisions}}
+    <div class="bg-white dark:bg-gray-800 p-4 rounded shadow space-y-2">
+        <div class="flex flex-wrap justify-between gap-2 text-sm text-gray-500 dark:text-gray-400">
+            <span>
+                r{{.ID}} · {{.CreatedAt.Format "2006-01-02 15:04"}} ·
+                <a class="text-blue-600 dark:text-blue-400" href="?id={{.ChunkID}}">#{{.ChunkID}}</a>
+                {{if eq .Action "create"}}добавлен{{else if eq .Action "update"}}изменён{{else if eq .Action "delete"}}удалён{{else}}восстановлен{{end}}
+                {{if .Author}}· {{.Author}}{{end}}
+            </span>
+            {{if and .Content (not .ExtID)}}
+            <form method="post" action="/chunks/restore">
+                <input type="hidden" name="revision" value="{{.ID}}">
+                <button class="text-blue-600 dark:text-blue-400">Восстановить эту версию</button>
+            </form>
+            {{end}}
+        </div>
+        {{if .Before}}<p class="bg-red-50 dark:bg-red-900/20 rounded p-2 whitespace-pre-wrap">{{.Before}}</p>{{end}}
+        {{if .After}}<p class="bg-green-50 dark:bg-green-900/20 rounded p-2 whitespace-pre-wrap">{{.After}}</p>{{end}}
+    </div>
+    {{end}}
+</div>
+</body>
+</html>`))
+
+// ChunkHistoryHandler shows the changes of a chunk (?id=) or the latest changes of all chunks.
+func ChunkHistoryHandler(repo *repository.Repository) http.HandlerFunc {
+	const limit = 50
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer util.Recover("ChunkHistoryHandler")
+
+		if _, ok := authorize(repo, w, r, access.ViewKnowledge); !ok {
+			return
+		}
+
+		var revs []repository.ChunkRevision
+		var err error
+		title := "История базы знаний"
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		if id > 0 {
+			title = fmt.Sprintf("История фрагмента #%d", id)
+			revs, err = repo.ListChunkRevisions(r.Context(), id, limit)
+		} else {
+			revs, err = repo.ListRecentRevisions(r.Context(), limit)
+		}
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		data := struct {
+			Title     string
+			ChunkID   int
+			Revisions []repository.ChunkRevision
+		}{
+			Title:     title,
+			ChunkID:   id,
+			Revisions: revs,
+		}
+		chunkHistoryTemplate.Execute(w, data)
+	}
+}
+
+// ChunkRestoreHandler returns a chunk to the version of the posted revision.
+func ChunkRestoreHandler(repo *repository.Repository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer util.Recover("ChunkRestoreHandler")
+
+		admin, ok := authorize(repo, w, r, access.EditKnowledge)
+		if !ok {
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		id, err := strconv.ParseInt(r.FormValue("revision"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid revision", http.StatusBadRequest)
+			return
+		}
+		rev, err := repo.RestoreChunkRevision(repository.WithAuthor(r.Context(), "web:"+admin.Login.String), id)
+		if errors.Is(err, repository.ErrSyncedChunk) {
+			http.Error(w, fmt.Sprintf("chunk #%d is synced from %q: change it in the source", rev.ChunkID, rev.Source), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Restore revision %d error: %v", id, err)
+			http.Error(w, "could not restore the revision", http.StatusConflict)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/chunks/history?id=%d", rev.ChunkID), http.StatusSeeOther)
+	}
+}
@@ -1,12 +1,28 @@
 package handler
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"log"
 	"net/http"
 	"ragbot/internal/config"
 	"ragbot/internal/repository"
 )
 
+// visitTokenBytes gives a 12-character token, the /start payload allows up to 64 characters of [A-Za-z0-9_-]
+const visitTokenBytes = 9
+
+// newVisitToken returns a random URL-safe token for the /start payload.
+func newVisitToken() string {
+	b := make([]byte, visitTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("visit token error: %v", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
 func HandleEntry(repo *repository.Repository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -21,11 +37,18 @@ func HandleEntry(repo *repository.Repository) http.HandlerFunc {
 		ua := r.UserAgent()
 		ref := r.Referer()
 		q := r.URL.Query()
-		repo.AddVisit(r.Context(), ip, ua, ref,
-			q.Get("utm_source"), q.Get("utm_medium"), q.Get("utm_campaign"), q.Get("utm_content"), q.Get("utm_term"))
+		token := newVisitToken()
+		if err := repo.AddVisit(r.Context(), token, ip, ua, ref,
+			q.Get("utm_source"), q.Get("utm_medium"), q.Get("utm_campaign"), q.Get("utm_content"), q.Get("utm_term")); err != nil {
+			log.Printf("add visit error: %v", err)
+			token = ""
+		}
 
 		if config.Config.UserTelegramBotName != "" {
 			url := fmt.Sprintf(telegramWebUrlFormat, config.Config.UserTelegramBotName)
+			if token != "" {
+				url = fmt.Sprintf(telegramStartUrlFormat, config.Config.UserTelegramBotName, token)
+			}
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			fmt.Fprintf(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Redirect</title><script>window.location.replace('%s');</script></head><body><noscript><a href=\"%s\">Перейти в чат с ассистентом</a></noscript></body></html>", url, url)
 			return
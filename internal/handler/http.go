@@ -1,26 +1,35 @@
 package handler
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
-	"ragbot/internal/config"
+	"net/url"
 
+	"ragbot/internal/access"
 	"ragbot/internal/ai"
+	"ragbot/internal/config"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
 )
 
-const telegramWebUrlFormat = "https://t.me/%s"
+const (
+	telegramWebUrlFormat   = "https://t.me/%s"
+	telegramStartUrlFormat = "https://t.me/%s?start=%s"
+)
 
 type QueryRequest struct {
 	Question string `json:"question"`
 }
 
 type QueryResponse struct {
-	Answer string `json:"answer"`
+	Answer    string `json:"answer"`
+	HistoryID int64  `json:"history_id,omitempty"`
+	// OfferCallback suggests showing the call-me-back form
+	OfferCallback bool `json:"offer_callback,omitempty"`
 }
 
-func StartHTTP(repo *repository.Repository, aiClient *ai.AIClient) {
+func StartHTTP(repo *repository.Repository, aiClient *ai.AIClient, requestCallback CallbackRequester, web WebChannel) {
 	defer util.Recover("StartHTTP")
 
 	http.HandleFunc("/", HandleEntry(repo))
@@ -28,17 +37,64 @@ func StartHTTP(repo *repository.Repository, aiClient *ai.AIClient) {
 	http.HandleFunc("/chat/", ChatHandler(repo))
 	http.HandleFunc("/chats", ChatsHandler(repo))
 	http.HandleFunc("/stats", StatsHandler(repo))
+	http.HandleFunc("/chunks/history", ChunkHistoryHandler(repo))
+	http.HandleFunc("/chunks/restore", ChunkRestoreHandler(repo))
+
+	initWebAPILimits()
+	http.HandleFunc("/api/session", withWebAPI(http.MethodPost, APISession(repo)))
+	http.HandleFunc("/api/ask", withWebAPI(http.MethodPost, APIAsk(repo, aiClient)))
+	http.HandleFunc("/api/stream", withWebAPI(http.MethodPost, APIStream(repo, aiClient)))
+	http.HandleFunc("/api/history", withWebAPI(http.MethodGet, APIHistory(repo)))
+	http.HandleFunc("/api/callback", withWebAPI(http.MethodPost, APICallback(repo, requestCallback)))
+	http.HandleFunc("/api/prices", withWebAPI(http.MethodGet, APIPrices(repo, web)))
+	http.HandleFunc("/api/addresses", withWebAPI(http.MethodGet, APIAddresses(repo, web)))
+	http.HandleFunc("/api/action", withWebAPI(http.MethodPost, APIAction(repo, web)))
+	http.Handle("/widget/", WidgetHandler())
 
 	log.Println("HTTP server listening on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
 
-func authorize(w http.ResponseWriter, r *http.Request) bool {
+// Mount adds a handler of another package to the HTTP server, e.g. the webhook of a messenger.
+func Mount(pattern string, h http.Handler) {
+	http.Handle(pattern, h)
+}
+
+// authorize checks the web login of an admin account and its role.
+func authorize(repo *repository.Repository, w http.ResponseWriter, r *http.Request, p access.Permission) (repository.Admin, bool) {
 	user, pass, ok := r.BasicAuth()
-	if !ok || user != config.Config.AdminUsername || pass != config.Config.AdminPassword {
+	var admin repository.Admin
+	var err error
+	if ok && user != "" {
+		admin, err = repo.GetAdmin(r.Context(), repository.AdminRef{Login: user})
+	}
+	if !ok || user == "" || err != nil || !access.CheckPassword(admin.PasswordHash, pass) {
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Admin login error: %v", err)
+		}
 		w.Header().Set("WWW-Authenticate", "Basic realm=restricted")
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return admin, false
+	}
+	if !access.Allowed(admin.Role, p) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return admin, false
+	}
+	return admin, true
+}
+
+// sameOrigin reports whether a form was posted from a page of BASE_URL.
+// Basic auth credentials are sent by the browser to any site's forms too,
+// so changes are accepted only with the Origin or, without it, the Referer of the bot.
+func sameOrigin(r *http.Request) bool {
+	base, err := url.Parse(config.Config.BaseURL)
+	if err != nil || base.Host == "" {
 		return false
 	}
-	return true
+	from := r.Header.Get("Origin")
+	if from == "" {
+		from = r.Referer()
+	}
+	u, err := url.Parse(from)
+	return err == nil && u.Scheme == base.Scheme && u.Host == base.Host
 }
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ragbot/internal/config"
+)
+
+func TestSameOrigin(t *testing.T) {
+	config.Config = &config.AppConfig{BaseURL: "https://bot.example"}
+	cases := []struct {
+		name    string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{"same origin", "https://bot.example", "", true},
+		{"referer without origin", "", "https://bot.example/chunks/history?id=3", true},
+		{"other site", "https://evil.example", "https://bot.example/chunks/history", false},
+		{"plain http", "http://bot.example", "", false},
+		{"opaque origin", "null", "", false},
+		{"no headers", "", "", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/chunks/restore", nil)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		if c.referer != "" {
+			req.Header.Set("Referer", c.referer)
+		}
+		if got := sameOrigin(req); got != c.want {
+			t.Errorf("%s: sameOrigin = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
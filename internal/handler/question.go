@@ -7,50 +7,188 @@ import (
 	"ragbot/internal/ai"
 	"ragbot/internal/config"
 	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
 )
 
+// Ключи шаблонов промпта в каталоге сообщений.
+const (
+	promptHistoryTitle    = "prompt_history_title"
+	promptUserPrefix      = "prompt_user_prefix"
+	promptAssistantPrefix = "prompt_assistant_prefix"
+	promptFragmentsTitle  = "prompt_fragments_title"
+	promptAnswer          = "prompt_answer"
+)
+
+// FragmentsLimit is the number of knowledge fragments added to the prompt.
+const FragmentsLimit = 5
+
+// Answer is a generated answer with the knowledge fragments it was based on.
+type Answer struct {
+	Text   string
+	Chunks []repository.ChunkMatch
+	// Prompt is the prompt sent to the model, empty for a cached answer
+	Prompt string
+}
+
 // ProcessQuestionWithHistory builds prompt using conversation history and knowledge fragments.
+// The prompt is written in locale, an empty locale means the default one.
 func ProcessQuestionWithHistory(
 	repo *repository.Repository,
 	aiClient *ai.AIClient,
 	chatID int64,
+	locale string,
 	question string,
 ) (string, error) {
-	defer util.Recover("ProcessQuestionWithHistory")
+	answer, err := ProcessQuestionWithSources(repo, aiClient, chatID, locale, question)
+	return answer.Text, err
+}
+
+// ProcessQuestionWithSources works like ProcessQuestionWithHistory and also
+// returns the retrieved fragments. With chatID 0 neither the history
+// nor the answer cache is used.
+func ProcessQuestionWithSources(
+	repo *repository.Repository,
+	aiClient *ai.AIClient,
+	chatID int64,
+	locale string,
+	question string,
+) (Answer, error) {
+	return ProcessQuestionForUser(repo, aiClient, chatID, 0, locale, question)
+}
+
+// ProcessQuestionForUser works like ProcessQuestionWithSources for a participant
+// of a group chat: only their own messages are used as the history.
+// userID 0 means the whole chat history.
+func ProcessQuestionForUser(
+	repo *repository.Repository,
+	aiClient *ai.AIClient,
+	chatID int64,
+	userID int64,
+	locale string,
+	question string,
+) (Answer, error) {
+	defer util.Recover("ProcessQuestionForUser")
+	return processQuestion(context.Background(), repo, aiClient, chatID, userID, locale, question, nil)
+}
+
+// ProcessQuestionStream works like ProcessQuestionWithSources and passes parts
+// of the answer to onDelta as they are generated. A cached answer is passed at once.
+// Generation stops once ctx is done, e.g. when the visitor closes the page.
+func ProcessQuestionStream(
+	ctx context.Context,
+	repo *repository.Repository,
+	aiClient *ai.AIClient,
+	chatID int64,
+	locale string,
+	question string,
+	onDelta func(string),
+) (Answer, error) {
+	defer util.Recover("ProcessQuestionStream")
+	return processQuestion(ctx, repo, aiClient, chatID, 0, locale, question, onDelta)
+}
+
+func processQuestion(
+	ctx context.Context,
+	repo *repository.Repository,
+	aiClient *ai.AIClient,
+	chatID int64,
+	userID int64,
+	locale string,
+	question string,
+	onDelta func(string),
+) (Answer, error) {
+	if locale == "" {
+		locale = i18n.DefaultLocale()
+	}
 	var histText string
+	var history []conversation.HistoryItem
 	if chatID != 0 {
-		history := conversation.GetHistory(repo, chatID)
-		histText = "История беседы:\n"
+		if userID != 0 {
+			history = conversation.GetUserHistory(repo, chatID, userID)
+		} else {
+			history = conversation.GetHistory(repo, chatID)
+		}
+		histText = i18n.T(locale, promptHistoryTitle)
 		for _, item := range history {
 			if item.Role == "user" {
-				histText += "Пользователь: " + item.Content + "\n"
+				histText += i18n.T(locale, promptUserPrefix) + item.Content + "\n"
 			} else if item.Role == "assistant" {
-				histText += "Помощник: " + item.Content + "\n"
+				histText += i18n.T(locale, promptAssistantPrefix) + item.Content + "\n"
 			}
 		}
 	}
 
+	// Расписание меняется, поэтому ответы с ним не кэшируются
+	schedText := scheduleContext(locale, question)
+	cacheable := chatID != 0 && answerCacheEnabled()
+	if cacheable && (schedText != "" || isFollowUp(question, history)) {
+		countAnswerCacheEvent(repo, repository.AnswerCacheBypassed)
+		cacheable = false
+	}
+	// Кэшированный ответ получат другие пользователи, поэтому вопрос
+	// без отсылок к разговору задаётся модели без истории
+	if cacheable {
+		histText = ""
+	}
+	normalized := normalizeQuestion(question)
+	if cacheable {
+		if answer, ok := cachedAnswer(repo, locale, normalized, nil); ok {
+			return streamCached(answer, onDelta), nil
+		}
+	}
+
 	queryVec, err := aiClient.GenerateEmbedding(question)
 	if err != nil {
-		return "", err
+		return Answer{}, err
+	}
+	if cacheable {
+		if answer, ok := cachedAnswer(repo, locale, normalized, queryVec); ok {
+			return streamCached(answer, onDelta), nil
+		}
 	}
 
-	fragments, err := repo.SearchChunks(context.Background(), queryVec, 5)
+	fragments, err := repo.SearchChunkMatches(ctx, queryVec, FragmentsLimit)
 	if err != nil {
-		return "", fmt.Errorf("DB query error: %v", err)
+		return Answer{}, fmt.Errorf("DB query error: %v", err)
 	}
 
 	var fragText string
-	fragText = "Используй фрагменты базы знаний:\n---\n"
+	fragText = i18n.T(locale, promptFragmentsTitle)
 	for _, c := range fragments {
-		fragText += c + "\n"
+		fragText += c.Content + "\n"
 	}
 	fragText += "---\n"
+	fragText += schedText
 
-	prompt := config.LoadSettings().Preamble + "\n" + histText + "\n" + fragText + "Вопрос: " + question + "\nОтвет:\n"
+	prompt := i18n.T(locale, promptAnswer, i18n.Vars{
+		"Preamble":  config.LoadSettings().Preamble,
+		"History":   histText,
+		"Fragments": fragText,
+		"Question":  question,
+	})
 
 	fmt.Println("Prompt: " + prompt)
-	return aiClient.GenerateResponse(prompt)
+	var text string
+	if onDelta != nil {
+		text, err = aiClient.GenerateResponseStream(ctx, prompt, onDelta)
+	} else {
+		text, err = aiClient.GenerateResponse(prompt)
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+	answer := Answer{Text: text, Chunks: fragments, Prompt: prompt}
+	if cacheable {
+		saveCachedAnswer(repo, locale, normalized, queryVec, answer)
+	}
+	return answer, nil
+}
+
+func streamCached(answer Answer, onDelta func(string)) Answer {
+	if onDelta != nil {
+		onDelta(answer.Text)
+	}
+	return answer
 }
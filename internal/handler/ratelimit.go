@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows up to limit events per key within a fixed window.
+// A nil limiter or a non-positive limit disables the check.
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	hits   map[string]rateWindow
+	pruned time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, hits: make(map[string]rateWindow)}
+}
+
+// allow counts the event of the key and reports whether it is within the limit.
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	if l == nil || l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Истёкшие окна удаляются не чаще раза за окно
+	if now.Sub(l.pruned) > l.window {
+		for k, w := range l.hits {
+			if now.Sub(w.start) >= l.window {
+				delete(l.hits, k)
+			}
+		}
+		l.pruned = now
+	}
+	w, ok := l.hits[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = rateWindow{start: now}
+	}
+	w.count++
+	l.hits[key] = w
+	return w.count <= l.limit
+}
+
+// trustedProxies are the proxies in front of the server, see TRUSTED_PROXIES.
+var trustedProxies []netip.Prefix
+
+// parseTrustedProxies reads proxy addresses and CIDR ranges, skipping invalid ones.
+func parseTrustedProxies(values []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(v); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			log.Printf("Invalid TRUSTED_PROXIES entry %q", v)
+			continue
+		}
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out
+}
+
+// clientIP returns the visitor address. When the request comes from a trusted
+// proxy, it is the last X-Forwarded-For entry, the one added by the proxy itself.
+// Otherwise the header is set by the client and ignored.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	fwd := r.Header.Get("X-Forwarded-For")
+	if fwd == "" || !trustedProxy(host) {
+		return host
+	}
+	parts := strings.Split(fwd, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+func trustedProxy(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
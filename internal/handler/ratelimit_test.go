@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, time.Minute)
+	now := time.Now()
+	if !l.allow("a", now) || !l.allow("a", now.Add(time.Second)) {
+		t.Fatal("events within the limit must be allowed")
+	}
+	if l.allow("a", now.Add(2*time.Second)) {
+		t.Error("an event over the limit must be rejected")
+	}
+	if !l.allow("b", now.Add(2*time.Second)) {
+		t.Error("keys must be limited separately")
+	}
+	if !l.allow("a", now.Add(time.Minute)) {
+		t.Error("the limit must reset in the next window")
+	}
+
+	var disabled *rateLimiter
+	if !disabled.allow("a", now) || !newRateLimiter(0, time.Minute).allow("a", now) {
+		t.Error("a nil limiter or zero limit must allow everything")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/session", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	if ip := clientIP(r); ip != "10.0.0.1" {
+		t.Errorf("expected the remote address, got %q", ip)
+	}
+	r.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.7")
+	if ip := clientIP(r); ip != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For of an untrusted client must be ignored, got %q", ip)
+	}
+
+	trustedProxies = parseTrustedProxies([]string{"10.0.0.0/8", "invalid", "192.0.2.1"})
+	defer func() { trustedProxies = nil }()
+	if len(trustedProxies) != 2 {
+		t.Fatalf("unexpected trusted proxies %v", trustedProxies)
+	}
+	if ip := clientIP(r); ip != "203.0.113.7" {
+		t.Errorf("expected the address added by the proxy, got %q", ip)
+	}
+	r.RemoteAddr = "192.0.2.2:5000"
+	if ip := clientIP(r); ip != "192.0.2.2" {
+		t.Errorf("expected the remote address of an untrusted proxy, got %q", ip)
+	}
+}
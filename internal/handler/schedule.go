@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ragbot/internal/config"
+	"ragbot/internal/i18n"
+	"ragbot/internal/tansultant"
+	"ragbot/internal/util"
+)
+
+const (
+	promptScheduleTitle  = "prompt_schedule_title"
+	promptScheduleLesson = "prompt_schedule_lesson"
+)
+
+var tansClient *tansultant.Client
+
+// UseScheduleSource enables adding the class schedule to prompts
+// for questions about dates and times. The client also serves prices
+// and addresses to the web widget.
+func UseScheduleSource(c *tansultant.Client) {
+	tansClient = c
+}
+
+// scheduleContext returns upcoming lessons of all branches formatted for the prompt,
+// or an empty string when the question is not about the schedule.
+func scheduleContext(locale, question string) string {
+	if tansClient == nil || !tansClient.ScheduleConfigured() {
+		return ""
+	}
+	settings := config.LoadSettings()
+	if !util.ContainsStringFromSlice(strings.ToLower(question), settings.ScheduleTriggerWords) {
+		return ""
+	}
+	days := settings.ScheduleContextDays
+	if days < 1 {
+		days = 1
+	}
+
+	// Один общий срок на все запросы, чтобы ответ не ждал каждый филиал по очереди
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	branches, err := tansClient.Branches(ctx)
+	if err != nil {
+		log.Printf("schedule context branches error: %v", err)
+		return ""
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := from.AddDate(0, 0, days-1)
+	schedules := make([][]tansultant.Lesson, len(branches))
+	var wg sync.WaitGroup
+	for i, b := range branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lessons, err := tansClient.Schedule(ctx, b.ID, from, to)
+			if err != nil {
+				log.Printf("schedule context lessons error for branch %d: %v", b.ID, err)
+				return
+			}
+			schedules[i] = lessons
+		}()
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	for i, b := range branches {
+		for _, l := range schedules[i] {
+			sb.WriteString(i18n.T(locale, promptScheduleLesson, i18n.Vars{
+				"Branch":     b.Title,
+				"Weekday":    i18n.Weekday(locale, l.StartsAt.Time),
+				"Date":       l.StartsAt.Format("02.01"),
+				"Start":      l.StartsAt.Format("15:04"),
+				"End":        l.EndsAt.Format("15:04"),
+				"Name":       l.Name,
+				"Instructor": l.Instructor,
+				"Canceled":   l.Canceled(),
+			}))
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	today := i18n.Weekday(locale, now) + " " + now.Format("02.01.2006")
+	return i18n.T(locale, promptScheduleTitle, i18n.Vars{"Today": today}) + sb.String() + "---\n"
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"html/template"
 	"net/http"
+	"ragbot/internal/access"
+	"ragbot/internal/followup"
 	"ragbot/internal/repository"
 	"ragbot/internal/util"
 )
@@ -39,6 +41,32 @@ var statsTemplate = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
             <tr class="border-t border-gray-200 dark:border-gray-700"><td class="px-4 py-2">Нажатий кнопки «Цены»</td><td class="px-4 py-2">{{.PriceCount}}</td></tr>
         </tbody>
     </table>
+    <h2 class="text-xl font-bold">Кампании за {{.FeedbackDays}} дней</h2>
+    <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
+        <thead class="bg-gray-200 dark:bg-gray-700">
+            <tr>
+                <th class="px-4 py-2 text-left">Источник</th>
+                <th class="px-4 py-2 text-left">Кампания</th>
+                <th class="px-4 py-2 text-left">Переходы</th>
+                <th class="px-4 py-2 text-left">Диалоги</th>
+                <th class="px-4 py-2 text-left">Лиды</th>
+                <th class="px-4 py-2 text-left">Конверсия в лиды</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{range .Campaigns}}
+            <tr class="border-t border-gray-200 dark:border-gray-700">
+                <td class="px-4 py-2">{{.Source}}</td>
+                <td class="px-4 py-2">{{.Campaign}}</td>
+                <td class="px-4 py-2">{{.Visits}}</td>
+                <td class="px-4 py-2">{{.Chats}} ({{printf "%.1f" .ChatRate}}%)</td>
+                <td class="px-4 py-2">{{.Leads}}</td>
+                <td class="px-4 py-2">{{printf "%.1f" .LeadRate}}%</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    {{if .Transcripts}}
     <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
         <thead class="bg-gray-200 dark:bg-gray-700">
             <tr>
@@ -68,10 +96,121 @@ var statsTemplate = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
             {{end}}
         </tbody>
     </table>
+    {{end}}
+    <h2 class="text-xl font-bold">Оценки ответов за {{.FeedbackDays}} дней</h2>
+    <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
+        <thead class="bg-gray-200 dark:bg-gray-700">
+            <tr>
+                <th class="px-4 py-2 text-left">День</th>
+                <th class="px-4 py-2 text-left">👍</th>
+                <th class="px-4 py-2 text-left">👎</th>
+                <th class="px-4 py-2 text-left">Удовлетворённость</th>
+            </tr>
+        </thead>
+        <tbody>
+            <tr>
+                <td class="px-4 py-2 font-semibold">Всего</td>
+                <td class="px-4 py-2 font-semibold">{{.FeedbackTotal.Up}}</td>
+                <td class="px-4 py-2 font-semibold">{{.FeedbackTotal.Down}}</td>
+                <td class="px-4 py-2 font-semibold">{{printf "%.1f" .FeedbackTotal.Satisfaction}}%</td>
+            </tr>
+            {{range .Feedback}}
+            <tr class="border-t border-gray-200 dark:border-gray-700">
+                <td class="px-4 py-2">{{.Day.Format "02.01.2006"}}</td>
+                <td class="px-4 py-2">{{.Up}}</td>
+                <td class="px-4 py-2">{{.Down}}</td>
+                <td class="px-4 py-2">{{printf "%.1f" .Satisfaction}}%</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    <h2 class="text-xl font-bold">Кэш ответов за {{.FeedbackDays}} дней</h2>
+    <p>Записей в кэше: {{.CachedAnswers}}</p>
+    <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
+        <thead class="bg-gray-200 dark:bg-gray-700">
+            <tr>
+                <th class="px-4 py-2 text-left">День</th>
+                <th class="px-4 py-2 text-left">Из кэша</th>
+                <th class="px-4 py-2 text-left">Сгенерировано</th>
+                <th class="px-4 py-2 text-left">Без кэша (уточнения, расписание)</th>
+                <th class="px-4 py-2 text-left">Попадания</th>
+            </tr>
+        </thead>
+        <tbody>
+            <tr>
+                <td class="px-4 py-2 font-semibold">Всего</td>
+                <td class="px-4 py-2 font-semibold">{{.CacheTotal.Hits}}</td>
+                <td class="px-4 py-2 font-semibold">{{.CacheTotal.Misses}}</td>
+                <td class="px-4 py-2 font-semibold">{{.CacheTotal.Bypassed}}</td>
+                <td class="px-4 py-2 font-semibold">{{printf "%.1f" .CacheTotal.HitRate}}%</td>
+            </tr>
+            {{range .Cache}}
+            <tr class="border-t border-gray-200 dark:border-gray-700">
+                <td class="px-4 py-2">{{.Day.Format "02.01.2006"}}</td>
+                <td class="px-4 py-2">{{.Hits}}</td>
+                <td class="px-4 py-2">{{.Misses}}</td>
+                <td class="px-4 py-2">{{.Bypassed}}</td>
+                <td class="px-4 py-2">{{printf "%.1f" .HitRate}}%</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    <h2 class="text-xl font-bold">Напоминания за {{.FeedbackDays}} дней</h2>
+    <p>Ответом и заявкой считаются сообщения в течение {{.AttributionDays}} дней после напоминания.</p>
+    <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
+        <thead class="bg-gray-200 dark:bg-gray-700">
+            <tr>
+                <th class="px-4 py-2 text-left">Правило</th>
+                <th class="px-4 py-2 text-left">Отправлено</th>
+                <th class="px-4 py-2 text-left">Ответили</th>
+                <th class="px-4 py-2 text-left">Заявки и записи</th>
+                <th class="px-4 py-2 text-left">Конверсия</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{range .FollowUps}}
+            <tr class="border-t border-gray-200 dark:border-gray-700">
+                <td class="px-4 py-2">{{.Rule}}</td>
+                <td class="px-4 py-2">{{.Sent}}</td>
+                <td class="px-4 py-2">{{.Replied}}</td>
+                <td class="px-4 py-2">{{.Converted}}</td>
+                <td class="px-4 py-2">{{printf "%.1f" .ConversionRate}}%</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    {{if .Transcripts}}
+    <h2 class="text-xl font-bold">Ответы с оценкой 👎</h2>
+    <table class="min-w-full bg-white dark:bg-gray-800 rounded shadow divide-y divide-gray-200 dark:divide-gray-700">
+        <thead class="bg-gray-200 dark:bg-gray-700">
+            <tr>
+                <th class="px-4 py-2 text-left">Вопрос и ответ</th>
+                <th class="px-4 py-2 text-left">Комментарий</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{range .Downvoted}}
+            <tr class="border-t border-gray-200 dark:border-gray-700 align-top">
+                <td class="px-4 py-2">
+                    <a class="text-blue-600 dark:text-blue-400" href="/chat/{{.ChatUUID}}#m{{.HistoryID}}">{{.CreatedAt.Format "02.01.2006 15:04"}}</a>
+                    {{if .Question.Valid}}<p class="font-semibold">{{.Question.String}}</p>{{end}}
+                    <p class="text-sm">{{.Answer}}</p>
+                </td>
+                <td class="px-4 py-2">{{.Comment.String}}</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    {{end}}
 </div>
 </body>
 </html>`))
 
+const (
+	feedbackDays   = 30
+	downvotedLimit = 50
+)
+
 func StatsHandler(repo *repository.Repository) http.HandlerFunc {
 	type msgCount struct {
 		ID       string
@@ -82,9 +221,12 @@ func StatsHandler(repo *repository.Repository) http.HandlerFunc {
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer util.Recover("StatsHandler")
-		if !authorize(w, r) {
+		admin, ok := authorize(repo, w, r, access.ViewKnowledge)
+		if !ok {
 			return
 		}
+		// Переписки и пользователи видны только ролям с доступом к диалогам
+		transcripts := access.Allowed(admin.Role, access.ViewTranscripts)
 		ctx := r.Context()
 		visits, _ := repo.CountUniqueVisits(ctx)
 		uniqueChats, _ := repo.CountUniqueChats(ctx)
@@ -99,13 +241,36 @@ func StatsHandler(repo *repository.Repository) http.HandlerFunc {
 			visitToChatConv = float64(uniqueChats) / float64(visits) * 100
 			visitToLeadConv = float64(deals) / float64(visits) * 100
 		}
+		campaigns, _ := repo.CampaignFunnels(ctx, feedbackDays)
 		raspCount, _ := repo.CountCommandUsage(ctx, "/rasp")
 		addrCount, _ := repo.CountCommandUsage(ctx, "/address")
 		priceCount, _ := repo.CountCommandUsage(ctx, "/prices")
-		msgCountsRaw, _ := repo.MessageCountsBeforeDeal(ctx)
+		feedback, _ := repo.FeedbackByDay(ctx, feedbackDays)
+		var feedbackTotal repository.FeedbackDay
+		for _, d := range feedback {
+			feedbackTotal.Up += d.Up
+			feedbackTotal.Down += d.Down
+		}
+		var downvoted []repository.DownvotedAnswer
+		if transcripts {
+			downvoted, _ = repo.ListDownvotedAnswers(ctx, downvotedLimit)
+		}
+		cache, _ := repo.AnswerCacheByDay(ctx, feedbackDays)
+		var cacheTotal repository.AnswerCacheDay
+		for _, d := range cache {
+			cacheTotal.Hits += d.Hits
+			cacheTotal.Misses += d.Misses
+			cacheTotal.Bypassed += d.Bypassed
+		}
+		cachedAnswers, _ := repo.CountCachedAnswers(ctx)
+		attributionDays := followup.AttributionDays()
+		followUps, _ := repo.FollowUpStats(ctx, feedbackDays, attributionDays)
 		var msgCounts []msgCount
-		for _, m := range msgCountsRaw {
-			msgCounts = append(msgCounts, msgCount(m))
+		if transcripts {
+			msgCountsRaw, _ := repo.MessageCountsBeforeDeal(ctx)
+			for _, m := range msgCountsRaw {
+				msgCounts = append(msgCounts, msgCount(m))
+			}
 		}
 		data := struct {
 			Visits                int
@@ -114,10 +279,21 @@ func StatsHandler(repo *repository.Repository) http.HandlerFunc {
 			Deals                 int
 			ChatToLeadConversion  float64
 			VisitToLeadConversion float64
+			Campaigns             []repository.CampaignFunnel
 			RaspCount             int
 			AddrCount             int
 			PriceCount            int
+			Transcripts           bool
 			MsgCounts             []msgCount
+			FeedbackDays          int
+			Feedback              []repository.FeedbackDay
+			FeedbackTotal         repository.FeedbackDay
+			Downvoted             []repository.DownvotedAnswer
+			Cache                 []repository.AnswerCacheDay
+			CacheTotal            repository.AnswerCacheDay
+			CachedAnswers         int
+			FollowUps             []repository.FollowUpStat
+			AttributionDays       int
 		}{
 			Visits:                visits,
 			UniqueChats:           uniqueChats,
@@ -125,10 +301,21 @@ func StatsHandler(repo *repository.Repository) http.HandlerFunc {
 			Deals:                 deals,
 			ChatToLeadConversion:  chatToLeadConv,
 			VisitToLeadConversion: visitToLeadConv,
+			Campaigns:             campaigns,
 			RaspCount:             raspCount,
 			AddrCount:             addrCount,
 			PriceCount:            priceCount,
+			Transcripts:           transcripts,
 			MsgCounts:             msgCounts,
+			FeedbackDays:          feedbackDays,
+			Feedback:              feedback,
+			FeedbackTotal:         feedbackTotal,
+			Downvoted:             downvoted,
+			Cache:                 cache,
+			CacheTotal:            cacheTotal,
+			CachedAnswers:         cachedAnswers,
+			FollowUps:             followUps,
+			AttributionDays:       attributionDays,
 		}
 		statsTemplate.Execute(w, data)
 	}
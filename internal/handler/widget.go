@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"ragbot/internal/ai"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+//go:embed widget
+var widgetFiles embed.FS
+
+// widgetTextKeys are the catalog messages shown by the web widget.
+var widgetTextKeys = []string{
+	"widget_title",
+	"widget_greeting",
+	"widget_placeholder",
+	"widget_send",
+	"widget_prices",
+	"widget_addresses",
+	"widget_callback",
+	"widget_name",
+	"widget_phone",
+	"widget_callback_submit",
+	"manager_will_call",
+	"user_error",
+	"info_unavailable",
+	"prices_title",
+}
+
+type deltaEvent struct {
+	Text string `json:"text"`
+}
+
+// WebMessage is a reply of the conversation engine shown by the widget.
+type WebMessage struct {
+	Text    string      `json:"text"`
+	Buttons []WebButton `json:"buttons,omitempty"`
+}
+
+// WebButton is a widget button that sends its action to /api/action.
+type WebButton struct {
+	Text   string `json:"text"`
+	Action string `json:"action"`
+}
+
+// WebChannel runs a command or a button action of the widget session through
+// the conversation engine shared with the other channels and returns the replies.
+type WebChannel func(session, text, action string) []WebMessage
+
+type actionRequest struct {
+	Action string `json:"action"`
+}
+
+type messagesResponse struct {
+	Messages []WebMessage `json:"messages"`
+}
+
+// WidgetHandler serves the widget script and styles embedded into the binary.
+func WidgetHandler() http.Handler {
+	sub, err := fs.Sub(widgetFiles, "widget")
+	if err != nil {
+		log.Fatalf("widget files: %v", err)
+	}
+	return http.StripPrefix("/widget/", http.FileServer(http.FS(sub)))
+}
+
+func widgetTexts(locale string) map[string]string {
+	texts := make(map[string]string, len(widgetTextKeys))
+	for _, key := range widgetTextKeys {
+		texts[key] = i18n.T(locale, key)
+	}
+	return texts
+}
+
+func writeSSE(w http.ResponseWriter, event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("sse marshal error: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// APIStream answers a visitor question as server-sent events: "delta" events
+// with parts of the answer, then "done" with the whole answer or "error".
+func APIStream(repo *repository.Repository, aiClient *ai.AIClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, ok := webSession(w, r, repo)
+		if !ok || !allowQuestion(w, r, chatID) {
+			return
+		}
+		question, ok := readQuestion(w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Отключаем буферизацию ответа в nginx
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		ctx := r.Context()
+		result, err := ProcessQuestionStream(ctx, repo, aiClient, chatID, conversation.GetLocale(repo, chatID), question, func(delta string) {
+			writeSSE(w, "delta", deltaEvent{Text: delta})
+		})
+		if ctx.Err() != nil {
+			// Посетитель закрыл страницу: отвечать и сохранять ответ некому
+			return
+		}
+		if err != nil {
+			log.Printf("web api answer error: %v", err)
+			writeSSE(w, "error", errorResponse{Error: "failed to generate an answer"})
+			return
+		}
+		writeSSE(w, "done", saveWebAnswer(repo, chatID, question, result))
+	}
+}
+
+// APIPrices returns the passes for the widget "Prices" action as buttons that open their descriptions.
+func APIPrices(repo *repository.Repository, web WebChannel) http.HandlerFunc {
+	return webCommand(repo, web, "/prices")
+}
+
+// APIAddresses returns the studio addresses for the widget "Addresses" action.
+func APIAddresses(repo *repository.Repository, web WebChannel) http.HandlerFunc {
+	return webCommand(repo, web, "/address")
+}
+
+// webCommand runs the command of the widget through the conversation engine.
+func webCommand(repo *repository.Repository, web WebChannel, command string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := webSession(w, r, repo); !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, messagesResponse{Messages: web(sessionToken(r), command, "")})
+	}
+}
+
+// APIAction runs a button of an engine message, e.g. the description of a pass.
+func APIAction(repo *repository.Repository, web WebChannel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := webSession(w, r, repo); !ok {
+			return
+		}
+		var req actionRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Action == "" {
+			writeJSONError(w, http.StatusBadRequest, "action is required")
+			return
+		}
+		writeJSON(w, http.StatusOK, messagesResponse{Messages: web(sessionToken(r), "", req.Action)})
+	}
+}
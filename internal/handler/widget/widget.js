@@ -0,0 +1,307 @@
+// Виджет чата с ассистентом для сайта.
+// Подключение: <script src="https://<домен бота>/widget/widget.js" data-api-key="<ключ из WEB_API_KEYS>" async></script>
+(function () {
+    'use strict';
+
+    var script = document.currentScript;
+    if (!script) {
+        return;
+    }
+    var apiBase = new URL(script.src).origin;
+    var apiKey = script.getAttribute('data-api-key') || '';
+    var lang = script.getAttribute('data-lang') || navigator.language || '';
+    var storageKey = 'ragbot_session';
+
+    var session = '';
+    var texts = {};
+    var busy = false;
+    var ui = {};
+
+    function t(key) {
+        return texts[key] || key;
+    }
+
+    function headers(json) {
+        var h = {'X-API-Key': apiKey};
+        if (session) {
+            h['X-Session-Token'] = session;
+        }
+        if (json) {
+            h['Content-Type'] = 'application/json';
+        }
+        return h;
+    }
+
+    function request(method, path, body) {
+        return fetch(apiBase + path, {
+            method: method,
+            headers: headers(body !== undefined),
+            body: body !== undefined ? JSON.stringify(body) : undefined,
+            credentials: 'include'
+        }).then(function (resp) {
+            return resp.json().then(function (data) {
+                if (!resp.ok) {
+                    throw new Error(data.error || resp.statusText);
+                }
+                return data;
+            });
+        });
+    }
+
+    function el(tag, className, text) {
+        var node = document.createElement(tag);
+        if (className) {
+            node.className = className;
+        }
+        if (text) {
+            node.textContent = text;
+        }
+        return node;
+    }
+
+    function addMessage(role, text) {
+        var node = el('div', 'ragbot-message ragbot-' + role, text);
+        ui.messages.appendChild(node);
+        ui.messages.scrollTop = ui.messages.scrollHeight;
+        return node;
+    }
+
+    function setBusy(value) {
+        busy = value;
+        ui.send.disabled = value;
+        ui.input.disabled = value;
+    }
+
+    // Разбирает события SSE из потока ответа /api/stream
+    function readEvents(resp, onEvent) {
+        var reader = resp.body.getReader();
+        var decoder = new TextDecoder();
+        var buffer = '';
+        function pump() {
+            return reader.read().then(function (chunk) {
+                if (chunk.done) {
+                    return;
+                }
+                buffer += decoder.decode(chunk.value, {stream: true});
+                var parts = buffer.split('\n\n');
+                buffer = parts.pop();
+                parts.forEach(function (part) {
+                    var event = 'message';
+                    var data = '';
+                    part.split('\n').forEach(function (line) {
+                        if (line.indexOf('event: ') === 0) {
+                            event = line.slice(7);
+                        } else if (line.indexOf('data: ') === 0) {
+                            data += line.slice(6);
+                        }
+                    });
+                    if (data) {
+                        onEvent(event, JSON.parse(data));
+                    }
+                });
+                return pump();
+            });
+        }
+        return pump();
+    }
+
+    function ask(question) {
+        if (busy || !question) {
+            return;
+        }
+        setBusy(true);
+        addMessage('user', question);
+        var answer = addMessage('assistant', '');
+        answer.classList.add('ragbot-typing');
+        fetch(apiBase + '/api/stream', {
+            method: 'POST',
+            headers: headers(true),
+            body: JSON.stringify({question: question}),
+            credentials: 'include'
+        }).then(function (resp) {
+            if (!resp.ok || !resp.body) {
+                throw new Error(resp.statusText);
+            }
+            return readEvents(resp, function (event, data) {
+                if (event === 'delta') {
+                    answer.classList.remove('ragbot-typing');
+                    answer.textContent += data.text;
+                    ui.messages.scrollTop = ui.messages.scrollHeight;
+                } else if (event === 'done') {
+                    answer.textContent = data.answer;
+                    if (data.offer_callback) {
+                        showCallbackForm();
+                    }
+                } else if (event === 'error') {
+                    throw new Error(data.error);
+                }
+            });
+        }).catch(function () {
+            answer.textContent = t('user_error');
+        }).then(function () {
+            answer.classList.remove('ragbot-typing');
+            setBusy(false);
+            ui.input.focus();
+        });
+    }
+
+    // Показывает ответы движка бота; кнопки отправляют своё действие в /api/action
+    function addReplies(data) {
+        data.messages.forEach(function (m) {
+            var node = addMessage('assistant', m.text);
+            (m.buttons || []).forEach(function (b) {
+                var button = el('button', 'ragbot-price', b.text);
+                button.type = 'button';
+                button.addEventListener('click', function () {
+                    request('POST', '/api/action', {action: b.action}).then(addReplies).catch(function () {
+                        addMessage('assistant', t('info_unavailable'));
+                    });
+                });
+                node.appendChild(button);
+            });
+        });
+    }
+
+    function showPrices() {
+        request('GET', '/api/prices').then(addReplies).catch(function () {
+            addMessage('assistant', t('info_unavailable'));
+        });
+    }
+
+    function showAddresses() {
+        request('GET', '/api/addresses').then(addReplies).catch(function () {
+            addMessage('assistant', t('info_unavailable'));
+        });
+    }
+
+    function showCallbackForm() {
+        if (ui.messages.querySelector('.ragbot-callback')) {
+            return;
+        }
+        var form = el('form', 'ragbot-callback');
+        var name = el('input');
+        name.placeholder = t('widget_name');
+        name.required = true;
+        var phone = el('input');
+        phone.type = 'tel';
+        phone.placeholder = t('widget_phone');
+        phone.required = true;
+        var submit = el('button', '', t('widget_callback_submit'));
+        submit.type = 'submit';
+        form.appendChild(name);
+        form.appendChild(phone);
+        form.appendChild(submit);
+        form.addEventListener('submit', function (e) {
+            e.preventDefault();
+            submit.disabled = true;
+            request('POST', '/api/callback', {name: name.value, phone: phone.value}).then(function () {
+                form.remove();
+                addMessage('assistant', t('manager_will_call'));
+            }).catch(function () {
+                submit.disabled = false;
+                addMessage('assistant', t('user_error'));
+            });
+        });
+        ui.messages.appendChild(form);
+        ui.messages.scrollTop = ui.messages.scrollHeight;
+        name.focus();
+    }
+
+    function build() {
+        var link = el('link');
+        link.rel = 'stylesheet';
+        link.href = apiBase + '/widget/widget.css';
+        document.head.appendChild(link);
+
+        ui.toggle = el('button', 'ragbot-toggle', '💬');
+        ui.toggle.type = 'button';
+        ui.panel = el('div', 'ragbot-panel');
+        ui.panel.hidden = true;
+
+        var header = el('div', 'ragbot-header', t('widget_title'));
+        var close = el('button', 'ragbot-close', '×');
+        close.type = 'button';
+        header.appendChild(close);
+
+        ui.messages = el('div', 'ragbot-messages');
+
+        var actions = el('div', 'ragbot-actions');
+        [['widget_prices', showPrices], ['widget_addresses', showAddresses], ['widget_callback', showCallbackForm]].forEach(function (a) {
+            var button = el('button', '', t(a[0]));
+            button.type = 'button';
+            button.addEventListener('click', a[1]);
+            actions.appendChild(button);
+        });
+
+        var form = el('form', 'ragbot-form');
+        ui.input = el('input');
+        ui.input.placeholder = t('widget_placeholder');
+        ui.input.maxLength = 2000;
+        ui.send = el('button', '', t('widget_send'));
+        ui.send.type = 'submit';
+        form.appendChild(ui.input);
+        form.appendChild(ui.send);
+        form.addEventListener('submit', function (e) {
+            e.preventDefault();
+            var question = ui.input.value.trim();
+            ui.input.value = '';
+            ask(question);
+        });
+
+        ui.panel.appendChild(header);
+        ui.panel.appendChild(ui.messages);
+        ui.panel.appendChild(actions);
+        ui.panel.appendChild(form);
+        document.body.appendChild(ui.panel);
+        document.body.appendChild(ui.toggle);
+
+        ui.toggle.addEventListener('click', function () {
+            ui.panel.hidden = !ui.panel.hidden;
+            if (!ui.panel.hidden) {
+                ui.input.focus();
+            }
+        });
+        close.addEventListener('click', function () {
+            ui.panel.hidden = true;
+        });
+    }
+
+    function loadHistory() {
+        return request('GET', '/api/history').then(function (data) {
+            if (data.messages.length === 0) {
+                addMessage('assistant', t('widget_greeting'));
+                return;
+            }
+            data.messages.forEach(function (m) {
+                addMessage(m.role === 'user' ? 'user' : 'assistant', m.text);
+            });
+        });
+    }
+
+    function start() {
+        try {
+            session = localStorage.getItem(storageKey) || '';
+        } catch (e) {
+            session = '';
+        }
+        request('POST', '/api/session', {language: lang}).then(function (data) {
+            session = data.session;
+            texts = data.texts || {};
+            try {
+                localStorage.setItem(storageKey, session);
+            } catch (e) {
+                // Хранилище может быть недоступно, тогда сессия держится на cookie
+            }
+            build();
+            return loadHistory();
+        }).catch(function (err) {
+            console.error('ragbot widget:', err);
+        });
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', start);
+    } else {
+        start();
+    }
+})();
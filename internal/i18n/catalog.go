@@ -0,0 +1,219 @@
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// Vars holds named values substituted into message templates.
+type Vars map[string]any
+
+// Catalog stores message templates for every supported locale.
+type Catalog struct {
+	defaultLocale string
+	reference     []string
+	messages      map[string]map[string]string
+	templates     map[string]map[string]*template.Template
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   *Catalog
+)
+
+// NewCatalog builds a catalog from the embedded locales overlaid with
+// <locale>.json files from dir (if set). Keys of the embedded default
+// locale are the reference set every locale must provide. The returned
+// problems describe missing keys and broken templates.
+func NewCatalog(dir, defaultLocale string) (*Catalog, []string, error) {
+	c := &Catalog{
+		defaultLocale: defaultLocale,
+		messages:      make(map[string]map[string]string),
+		templates:     make(map[string]map[string]*template.Template),
+	}
+	if err := c.loadFS(localesFS, "locales"); err != nil {
+		return nil, nil, err
+	}
+	for key := range c.messages[defaultLocale] {
+		c.reference = append(c.reference, key)
+	}
+	sort.Strings(c.reference)
+	if dir != "" {
+		if err := c.loadFS(os.DirFS(dir), "."); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, nil, fmt.Errorf("default locale %q not found", defaultLocale)
+	}
+	return c, c.compile(), nil
+}
+
+func (c *Catalog) loadFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		locale := strings.TrimSuffix(path.Base(file), ".json")
+		if c.messages[locale] == nil {
+			c.messages[locale] = make(map[string]string)
+		}
+		for key, text := range messages {
+			c.messages[locale][key] = text
+		}
+	}
+	return nil
+}
+
+// compile parses all templates and reports missing keys.
+func (c *Catalog) compile() []string {
+	var problems []string
+	for _, locale := range c.Locales() {
+		c.templates[locale] = make(map[string]*template.Template)
+		for key, text := range c.messages[locale] {
+			tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s: %v", locale, key, err))
+				continue
+			}
+			c.templates[locale][key] = tmpl
+		}
+		for _, key := range c.reference {
+			if _, ok := c.messages[locale][key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing key %q", locale, key))
+			}
+		}
+	}
+	return problems
+}
+
+// Locales returns the sorted list of available locales.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// DefaultLocale returns the locale used when nothing better is known.
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// Resolve maps a Telegram language code such as "en-US" to a supported locale.
+func (c *Catalog) Resolve(languageCode string) string {
+	code := strings.ToLower(strings.TrimSpace(languageCode))
+	if _, ok := c.messages[code]; ok {
+		return code
+	}
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		if _, ok := c.messages[code[:i]]; ok {
+			return code[:i]
+		}
+	}
+	return c.defaultLocale
+}
+
+// T renders a message in the given locale, falling back to the default
+// locale and finally to the key itself.
+func (c *Catalog) T(locale, key string, vars ...Vars) string {
+	tmpl, ok := c.templates[locale][key]
+	if !ok {
+		tmpl, ok = c.templates[c.defaultLocale][key]
+	}
+	if !ok {
+		log.Printf("message %q not found in catalog", key)
+		return key
+	}
+	var data Vars
+	if len(vars) > 0 {
+		data = vars[0]
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("message %q render error: %v", key, err)
+		return key
+	}
+	return buf.String()
+}
+
+// Load replaces the global catalog and returns validation problems.
+func Load(dir, defaultLocale string) ([]string, error) {
+	c, problems, err := NewCatalog(dir, defaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	catalogMu.Lock()
+	catalog = c
+	catalogMu.Unlock()
+	return problems, nil
+}
+
+// Default returns the global catalog, loading the embedded one with the
+// DEFAULT_LOCALE locale if needed.
+func Default() *Catalog {
+	catalogMu.RLock()
+	c := catalog
+	catalogMu.RUnlock()
+	if c != nil {
+		return c
+	}
+	locale := os.Getenv("DEFAULT_LOCALE")
+	if locale == "" {
+		locale = "ru"
+	}
+	if _, err := Load("", locale); err != nil {
+		log.Fatalf("message catalog load error: %v", err)
+	}
+	return Default()
+}
+
+// T renders a message from the global catalog.
+func T(locale, key string, vars ...Vars) string {
+	return Default().T(locale, key, vars...)
+}
+
+// Weekday returns the short name of the day of week of t.
+// Names are taken from the comma-separated "weekdays" message starting with Sunday.
+func Weekday(locale string, t time.Time) string {
+	names := strings.Split(T(locale, "weekdays"), ",")
+	if len(names) != 7 {
+		return t.Weekday().String()[:3]
+	}
+	return strings.TrimSpace(names[t.Weekday()])
+}
+
+// DefaultLocale returns the default locale of the global catalog.
+func DefaultLocale() string {
+	return Default().DefaultLocale()
+}
+
+// Resolve maps a language code to a locale of the global catalog.
+func Resolve(languageCode string) string {
+	return Default().Resolve(languageCode)
+}
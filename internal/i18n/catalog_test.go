@@ -0,0 +1,92 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedCatalogIsComplete(t *testing.T) {
+	_, problems, err := NewCatalog("", "ru")
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("embedded catalog problems:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c, _, err := NewCatalog("", "ru")
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+	testCases := map[string]string{
+		"en":    "en",
+		"en-US": "en",
+		"RU":    "ru",
+		"de":    "ru",
+		"":      "ru",
+	}
+	for code, expected := range testCases {
+		if got := c.Resolve(code); got != expected {
+			t.Errorf("Resolve(%q) = %q, expected %q", code, got, expected)
+		}
+	}
+}
+
+func TestTemplating(t *testing.T) {
+	c, _, err := NewCatalog("", "ru")
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+	got := c.T("en", "confirm_contact", Vars{"Name": "Anna", "Phone": "+7999"})
+	if got != "We found your contact details: Anna, +7999. Is that correct?" {
+		t.Fatalf("unexpected message: %q", got)
+	}
+	if got := c.T("en", "no_such_key"); got != "no_such_key" {
+		t.Fatalf("unknown key should be returned as is, got %q", got)
+	}
+}
+
+func TestOverrideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("ru.json", `{"ask_name": "Как вас зовут?"}`)
+	write("kk.json", `{"ask_name": "Атыңыз кім?", "broken": "{{.Name"}`)
+
+	c, problems, err := NewCatalog(dir, "ru")
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+	if got := c.T("ru", "ask_name"); got != "Как вас зовут?" {
+		t.Fatalf("override not applied: %q", got)
+	}
+	if got := c.T("ru", "ask_phone"); got != "Напишите ваш телефон для связи." {
+		t.Fatalf("embedded message lost after override: %q", got)
+	}
+	if got := c.T("kk", "ask_phone"); got != "Напишите ваш телефон для связи." {
+		t.Fatalf("missing key should fall back to default locale: %q", got)
+	}
+
+	var missing, broken bool
+	for _, p := range problems {
+		if strings.HasPrefix(p, `kk: missing key "ask_phone"`) {
+			missing = true
+		}
+		if strings.HasPrefix(p, "kk: broken:") {
+			broken = true
+		}
+		if strings.HasPrefix(p, "ru:") || strings.HasPrefix(p, "en:") {
+			t.Errorf("unexpected problem: %s", p)
+		}
+	}
+	if !missing || !broken {
+		t.Fatalf("expected missing key and broken template to be reported, got:\n%s", strings.Join(problems, "\n"))
+	}
+}
@@ -0,0 +1,269 @@
+// Package knowledge reads and writes knowledge base chunks in the import
+// and export file formats of the admin bot.
+package knowledge
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ragbot/internal/repository"
+)
+
+// Форматы файлов импорта и экспорта базы знаний
+const (
+	FormatTXT   = "txt"
+	FormatMD    = "md"
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Formats lists the supported import formats.
+var Formats = []string{FormatTXT, FormatMD, FormatCSV, FormatJSONL}
+
+// ExportFormats lists the formats that keep chunk IDs, sources and line
+// breaks. A txt export would lose them, so txt is only read.
+var ExportFormats = []string{FormatMD, FormatCSV, FormatJSONL}
+
+// Record is a knowledge chunk in an import or export file. ID 0 marks a new chunk.
+type Record struct {
+	ID      int    `json:"id,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Content string `json:"content"`
+}
+
+// В Markdown каждый фрагмент — раздел с заголовком «## #<id> <источник>»,
+// раздел заканчивается только следующим таким заголовком. Текст вне
+// разделов делится на фрагменты по пустым строкам.
+var mdHeading = regexp.MustCompile(`^##\s+#(\d+)(?:\s+(\S+))?\s*$`)
+
+// FormatFromName returns the import format of a file by its extension.
+func FormatFromName(name string) (string, bool) {
+	return formatIn(Formats, name)
+}
+
+// ExportFormatFromName returns the export format of a file by its extension.
+func ExportFormatFromName(name string) (string, bool) {
+	return formatIn(ExportFormats, name)
+}
+
+func formatIn(formats []string, name string) (string, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	for _, f := range formats {
+		if ext == f {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// ParseRecords reads the chunks of an import file.
+func ParseRecords(format string, r io.Reader) ([]Record, error) {
+	switch format {
+	case FormatTXT:
+		return parseTXT(r)
+	case FormatMD:
+		return parseMD(r)
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSONL:
+		return parseJSONL(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// WriteRecords writes the chunks in one of ExportFormats that ParseRecords reads back.
+func WriteRecords(format string, w io.Writer, records []Record) error {
+	switch format {
+	case FormatMD:
+		bw := bufio.NewWriter(w)
+		for _, rec := range records {
+			fmt.Fprintf(bw, "## #%d %s\n\n%s\n\n", rec.ID, rec.Source, strings.TrimSpace(rec.Content))
+		}
+		return bw.Flush()
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "source", "content"})
+		for _, rec := range records {
+			cw.Write([]string{strconv.Itoa(rec.ID), rec.Source, rec.Content})
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func parseTXT(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			records = append(records, Record{Content: line})
+		}
+	}
+	return records, scanner.Err()
+}
+
+func parseMD(r io.Reader) ([]Record, error) {
+	var records []Record
+	var current *Record
+	var lines []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			rec := Record{Content: text}
+			if current != nil {
+				rec.ID, rec.Source = current.ID, current.Source
+			}
+			records = append(records, rec)
+		}
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			id, _ := strconv.Atoi(m[1])
+			current = &Record{ID: id, Source: m[2]}
+			continue
+		}
+		if current == nil && strings.HasPrefix(line, "#") {
+			// Прочие заголовки вне разделов фрагментов в текст не попадают
+			flush()
+			continue
+		}
+		if current == nil && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return records, scanner.Err()
+}
+
+func parseCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	// Без заголовка единственная колонка считается текстом фрагмента
+	idCol, sourceCol, contentCol := -1, -1, 0
+	header := false
+	for i, name := range rows[0] {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "id":
+			idCol, header = i, true
+		case "source":
+			sourceCol, header = i, true
+		case "content":
+			contentCol, header = i, true
+		}
+	}
+	if header {
+		rows = rows[1:]
+	}
+
+	var records []Record
+	for n, row := range rows {
+		if contentCol >= len(row) || strings.TrimSpace(row[contentCol]) == "" {
+			continue
+		}
+		rec := Record{Content: strings.TrimSpace(row[contentCol])}
+		if idCol >= 0 && idCol < len(row) && strings.TrimSpace(row[idCol]) != "" {
+			if rec.ID, err = strconv.Atoi(strings.TrimSpace(row[idCol])); err != nil {
+				return nil, fmt.Errorf("row %d: invalid id %q", n+1, row[idCol])
+			}
+		}
+		if sourceCol >= 0 && sourceCol < len(row) {
+			rec.Source = strings.TrimSpace(row[sourceCol])
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func parseJSONL(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if rec.Content = strings.TrimSpace(rec.Content); rec.Content != "" {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// ImportPlan is the result of comparing an import file with the knowledge base.
+type ImportPlan struct {
+	New     []Record
+	Updated []Record
+	// Duplicates are chunks that already exist or repeat in the file
+	Duplicates int
+}
+
+// PlanImport sorts the records into new chunks, changed chunks and duplicates.
+// A record with the ID of an existing chunk updates it, other records are added
+// unless the same text is already in the knowledge base.
+func PlanImport(records []Record, existing []repository.ChunkListItem) ImportPlan {
+	byID := make(map[int]string, len(existing))
+	contents := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		byID[c.ID] = c.Content
+		contents[c.Content] = true
+	}
+
+	var plan ImportPlan
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		rec.Content = strings.TrimSpace(rec.Content)
+		if rec.Content == "" {
+			continue
+		}
+		current, exists := byID[rec.ID]
+		switch {
+		case seen[rec.Content]:
+			plan.Duplicates++
+		case rec.ID != 0 && exists && current != rec.Content && !contents[rec.Content]:
+			plan.Updated = append(plan.Updated, rec)
+		case contents[rec.Content]:
+			plan.Duplicates++
+		default:
+			rec.ID = 0
+			plan.New = append(plan.New, rec)
+		}
+		seen[rec.Content] = true
+	}
+	return plan
+}
@@ -0,0 +1,75 @@
+package knowledge
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"ragbot/internal/repository"
+)
+
+func TestRecordsRoundTrip(t *testing.T) {
+	records := []Record{
+		{ID: 1, Source: "admin", Content: "абонемент на месяц включает 8 занятий"},
+		{ID: 2, Source: "file", Content: "скидка, если купить \"два\" абонемента\nсразу"},
+		{ID: 3, Source: "admin", Content: "# Цены\n\n## Абонементы\nабонемент на год"},
+	}
+	for _, format := range []string{FormatMD, FormatCSV, FormatJSONL} {
+		var buf bytes.Buffer
+		if err := WriteRecords(format, &buf, records); err != nil {
+			t.Fatalf("%s: write error: %v", format, err)
+		}
+		got, err := ParseRecords(format, &buf)
+		if err != nil {
+			t.Fatalf("%s: parse error: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, records) {
+			t.Errorf("%s: expected %+v, got %+v", format, records, got)
+		}
+	}
+}
+
+func TestParseRecordsWithoutIDs(t *testing.T) {
+	got, err := ParseRecords(FormatMD, bytes.NewBufferString("# Цены\n\nпервый абзац\nпродолжение\n\nвторой абзац\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Record{{Content: "первый абзац\nпродолжение"}, {Content: "второй абзац"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if err := WriteRecords(FormatTXT, &bytes.Buffer{}, nil); err == nil {
+		t.Error("txt must not be offered for export")
+	}
+
+	got, err = ParseRecords(FormatCSV, bytes.NewBufferString("первый\nвторой\n"))
+	if err != nil || len(got) != 2 || got[1].Content != "второй" {
+		t.Errorf("headerless csv must be read as content, got %+v, %v", got, err)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	existing := []repository.ChunkListItem{
+		{ID: 1, Content: "один"},
+		{ID: 2, Content: "два"},
+	}
+	plan := PlanImport([]Record{
+		{ID: 1, Content: "один"},     // без изменений
+		{ID: 2, Content: "два, три"}, // изменён
+		{Content: "один"},            // уже есть в базе
+		{ID: 7, Content: "четыре"},   // неизвестный ID — новый фрагмент
+		{Content: "четыре"},          // повтор в файле
+		{Content: "  "},
+	}, existing)
+
+	if plan.Duplicates != 3 {
+		t.Errorf("expected 3 duplicates, got %d", plan.Duplicates)
+	}
+	if len(plan.Updated) != 1 || plan.Updated[0].ID != 2 {
+		t.Errorf("unexpected updates: %+v", plan.Updated)
+	}
+	if len(plan.New) != 1 || plan.New[0].Content != "четыре" || plan.New[0].ID != 0 {
+		t.Errorf("unexpected new chunks: %+v", plan.New)
+	}
+}
@@ -0,0 +1,205 @@
+package messenger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"ragbot/internal/access"
+	"ragbot/internal/amo"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+)
+
+// Шаги сбора контактов для звонка менеджера
+const (
+	stageName = iota + 1
+	stagePhone
+	stageConfirm
+)
+
+const chatUrlFormat = "%s/chat/%s"
+
+type contactState struct {
+	Stage int
+	Name  string
+}
+
+// InContactFlow reports whether the user is leaving contacts for a call.
+func (e *Engine) InContactFlow(chatID int64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.contacts[chatID]
+	return ok
+}
+
+func (e *Engine) setStage(chatID int64, stage int) {
+	e.mu.Lock()
+	e.contacts[chatID] = &contactState{Stage: stage}
+	e.mu.Unlock()
+}
+
+// CallManager starts the contact flow: it asks to confirm the known contacts
+// or asks for the name.
+func (e *Engine) CallManager(c *Conversation) {
+	conversation.AppendHistory(e.repo, c.ChatID, "user", repository.HistoryCallRequested)
+	e.updateSummary(c.ChatID)
+
+	info, err := conversation.GetChatInfoByChatID(e.repo, c.ChatID)
+	if err == nil && info.Name.Valid && info.Phone.Valid && info.Name.String != "" && info.Phone.String != "" {
+		e.setStage(c.ChatID, stageConfirm)
+		c.Send(Outbound{
+			Text: c.T(msgConfirmContactFormat, i18n.Vars{"Name": info.Name.String, "Phone": info.Phone.String}),
+			Buttons: [][]Button{{
+				{Text: c.T(msgConfirmYes), Action: ActionConfirmYes},
+				{Text: c.T(msgConfirmNo), Action: ActionConfirmNo},
+			}},
+		})
+		return
+	}
+
+	e.setStage(c.ChatID, stageName)
+	c.Reply(c.T(msgAskName))
+}
+
+// HandleContactInput takes the user's reply as the next step of the contact flow.
+// It reports whether the reply was consumed.
+func (e *Engine) HandleContactInput(c *Conversation, text string) bool {
+	e.mu.Lock()
+	st, ok := e.contacts[c.ChatID]
+	stage := 0
+	if ok {
+		stage = st.Stage
+	}
+	e.mu.Unlock()
+
+	switch stage {
+	case stageName:
+		conversation.AppendHistory(e.repo, c.ChatID, "user", text)
+		conversation.UpdateName(e.repo, c.ChatID, text)
+		e.mu.Lock()
+		st.Stage = stagePhone
+		st.Name = text
+		e.mu.Unlock()
+		c.Reply(c.T(msgAskPhone))
+		return true
+	case stagePhone:
+		conversation.AppendHistory(e.repo, c.ChatID, "user", text)
+		conversation.UpdatePhone(e.repo, c.ChatID, text)
+		e.finishContacts(c)
+		return true
+	case stageConfirm:
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, strings.ToLower(c.T(msgConfirmYes))) {
+			e.ConfirmContacts(c)
+			return true
+		}
+		if strings.Contains(lower, strings.ToLower(c.T(msgConfirmNo))) {
+			e.RejectContacts(c)
+			return true
+		}
+	}
+	return false
+}
+
+// ConfirmContacts passes the request with the known contacts to managers.
+func (e *Engine) ConfirmContacts(c *Conversation) {
+	conversation.AppendHistory(e.repo, c.ChatID, "user", repository.HistoryConfirmYes)
+	e.finishContacts(c)
+}
+
+// RejectContacts forgets the amoCRM contact and asks for the name again.
+func (e *Engine) RejectContacts(c *Conversation) {
+	conversation.AppendHistory(e.repo, c.ChatID, "user", repository.HistoryConfirmNo)
+	conversation.ClearAmoContactID(e.repo, c.ChatID)
+	e.setStage(c.ChatID, stageName)
+	c.Reply(c.T(msgAskName))
+}
+
+func (e *Engine) finishContacts(c *Conversation) {
+	e.mu.Lock()
+	delete(e.contacts, c.ChatID)
+	e.mu.Unlock()
+	if err := e.SubmitLead(c.ChatID); err != nil {
+		c.Reply(c.T(msgUserError))
+		return
+	}
+	c.Reply(c.T(msgManagerWillCall))
+}
+
+// SubmitLead notifies the admins about the chat contacts and creates the amoCRM lead.
+func (e *Engine) SubmitLead(chatID int64) error {
+	info, err := conversation.GetChatInfoByChatID(e.repo, chatID)
+	if err != nil {
+		log.Printf("Error sending lead to AMO: %v", err)
+		e.notify(access.ViewKnowledge, adminText(msgAdminLeadError, i18n.Vars{"Error": err}))
+		return err
+	}
+
+	link := fmt.Sprintf(chatUrlFormat, config.Config.BaseURL, info.ID)
+	e.notify(access.ViewTranscripts, adminText(msgAdminSummaryFormat, i18n.Vars{
+		"Name":    info.Name.String,
+		"Phone":   info.Phone.String,
+		"Summary": info.Summary.String,
+		"Link":    link,
+	}))
+
+	if err := amo.SendLeadToAMO(e.repo, &info, link); err != nil {
+		log.Printf("Error sending lead to AMO: %v", err)
+		e.notify(access.ViewKnowledge, adminText(msgAdminLeadError, i18n.Vars{"Error": err}))
+		return err
+	}
+	return nil
+}
+
+// RequestCallback registers a call-me-back request that came with the contacts,
+// e.g. from the form of the web widget.
+func (e *Engine) RequestCallback(chatID int64, name, phone string) error {
+	conversation.AppendHistory(e.repo, chatID, "user", repository.HistoryCallRequested)
+	conversation.UpdateName(e.repo, chatID, name)
+	conversation.UpdatePhone(e.repo, chatID, phone)
+	e.updateSummary(chatID)
+	return e.SubmitLead(chatID)
+}
+
+func (e *Engine) updateSummary(chatID int64) {
+	summary, title, interest, err := e.summarize(chatID)
+	if err != nil {
+		log.Printf("summary error: %v", err)
+		return
+	}
+	conversation.UpdateSummary(e.repo, chatID, summary, title, interest)
+}
+
+// dialogText formats the chat history for summarization prompts.
+func (e *Engine) dialogText(chatID int64) string {
+	locale := i18n.DefaultLocale()
+	var sb strings.Builder
+	for _, h := range conversation.GetHistory(e.repo, chatID) {
+		if h.Role == "user" {
+			sb.WriteString(i18n.T(locale, promptUserPrefix) + h.Content + "\n")
+		} else {
+			sb.WriteString(i18n.T(locale, promptAssistantPrefix) + h.Content + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func (e *Engine) summarize(chatID int64) (summary, title, interest string, err error) {
+	locale := i18n.DefaultLocale()
+	summary, err = e.ai.GenerateResponse(i18n.T(locale, promptSummarizeGist, i18n.Vars{"Dialog": e.dialogText(chatID)}))
+	if err != nil {
+		return
+	}
+
+	title, err = e.ai.GenerateResponse(i18n.T(locale, promptSummarizeTitle, i18n.Vars{"Summary": summary}))
+	if err != nil {
+		return
+	}
+
+	interest, err = e.ai.GenerateResponse(i18n.T(locale, promptSummarizeInterest, i18n.Vars{"Summary": summary}))
+
+	return
+}
@@ -0,0 +1,197 @@
+package messenger
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"ragbot/internal/access"
+	"ragbot/internal/ai"
+	"ragbot/internal/config"
+	"ragbot/internal/conversation"
+	"ragbot/internal/handler"
+	"ragbot/internal/i18n"
+	"ragbot/internal/repository"
+	"ragbot/internal/tansultant"
+	"ragbot/internal/util"
+)
+
+// Notifier delivers a message to the admins whose role has the permission.
+type Notifier func(p access.Permission, text string)
+
+// Engine holds the conversation logic shared by all channels: answers of the
+// assistant, the contact flow with amoCRM leads, prices and addresses.
+type Engine struct {
+	repo     *repository.Repository
+	ai       *ai.AIClient
+	studio   *tansultant.Client
+	notify   Notifier
+	mu       sync.Mutex
+	contacts map[int64]*contactState
+}
+
+// NewEngine creates the engine. tansClient may be nil when the studio API is not configured.
+func NewEngine(repo *repository.Repository, aiClient *ai.AIClient, tansClient *tansultant.Client, notify Notifier) *Engine {
+	return &Engine{
+		repo:     repo,
+		ai:       aiClient,
+		studio:   tansClient,
+		notify:   notify,
+		contacts: make(map[int64]*contactState),
+	}
+}
+
+// Open returns the conversation the inbound message belongs to and creates
+// it on the first message of the user.
+func (e *Engine) Open(adapter Adapter, in Inbound) (*Conversation, error) {
+	language := ""
+	if in.Language != "" {
+		language = i18n.Resolve(in.Language)
+	}
+	chatID, err := conversation.EnsureConversation(e.repo, adapter.Channel(), in.ExternalID, in.Username, language)
+	if err != nil {
+		return nil, err
+	}
+	locale := language
+	if locale == "" {
+		locale = conversation.GetLocale(e.repo, chatID)
+	}
+	if locale == "" {
+		locale = i18n.DefaultLocale()
+	}
+	return NewConversation(adapter, chatID, in.ExternalID, locale), nil
+}
+
+// Handle runs the default flow for a message of a channel that has no
+// features of its own: commands, button actions, the contact flow and questions.
+func (e *Engine) Handle(adapter Adapter, in Inbound) {
+	defer util.Recover("messenger.Handle")
+	c, err := e.Open(adapter, in)
+	if err != nil {
+		return
+	}
+	if in.Action != "" {
+		if !e.HandleAction(c, in.Action) {
+			log.Printf("Unknown %s action: %s", adapter.Channel(), in.Action)
+		}
+		return
+	}
+
+	text := strings.TrimSpace(in.Text)
+	if text == "" {
+		return
+	}
+	switch strings.ToLower(strings.Fields(text)[0]) {
+	case "/start":
+		text = c.T(msgStartGreeting)
+	case "/prices":
+		conversation.AppendHistory(e.repo, c.ChatID, "user", text)
+		e.ShowPrices(c)
+		return
+	case "/address":
+		conversation.AppendHistory(e.repo, c.ChatID, "user", text)
+		e.ShowAddresses(c)
+		return
+	case "/call":
+		conversation.AppendHistory(e.repo, c.ChatID, "user", text)
+		e.OfferCallback(c)
+		return
+	}
+	if e.HandleContactInput(c, text) {
+		return
+	}
+	e.Answer(c, text, text)
+}
+
+// HandleAction runs the action of a pressed button and reports whether the action is known.
+func (e *Engine) HandleAction(c *Conversation, action string) bool {
+	switch {
+	case action == ActionCallManager:
+		e.CallManager(c)
+	case action == ActionConfirmYes:
+		e.ConfirmContacts(c)
+	case action == ActionConfirmNo:
+		e.RejectContacts(c)
+	case strings.HasPrefix(action, ActionPricePrefix):
+		e.ShowPrice(c, strings.TrimPrefix(action, ActionPricePrefix))
+	case strings.HasPrefix(action, ActionFeedbackUp):
+		e.rateAnswer(c, strings.TrimPrefix(action, ActionFeedbackUp), 1)
+	case strings.HasPrefix(action, ActionFeedbackDown):
+		e.rateAnswer(c, strings.TrimPrefix(action, ActionFeedbackDown), -1)
+	default:
+		return false
+	}
+	return true
+}
+
+// Answer replies to the question with the assistant answer and rating buttons
+// or offers a call with a manager when the question or the answer asks for it.
+// historyText is the user message as stored in the history, e.g. with a voice mark.
+func (e *Engine) Answer(c *Conversation, question, historyText string) {
+	if util.ContainsStringFromSlice(strings.ToLower(question), config.Settings.CallManagerTriggerWords) {
+		conversation.AppendHistory(e.repo, c.ChatID, "user", historyText)
+		e.OfferCallback(c)
+		return
+	}
+
+	result, err := handler.ProcessQuestionWithSources(e.repo, e.ai, c.ChatID, c.Locale, question)
+	if err != nil {
+		e.notify(access.ViewKnowledge, adminText(msgAdminErrorFormat, i18n.Vars{"Error": err}))
+		answer := c.T(msgUserError)
+		conversation.AppendHistory(e.repo, c.ChatID, "user", historyText)
+		conversation.AppendHistory(e.repo, c.ChatID, "assistant", answer)
+		c.Reply(answer)
+		return
+	}
+	if util.ContainsStringFromSlice(strings.ToLower(result.Text), config.Settings.CallManagerTriggerWordsInAnswer) {
+		conversation.AppendHistory(e.repo, c.ChatID, "user", historyText)
+		conversation.AppendHistory(e.repo, c.ChatID, "assistant", result.Text)
+		e.OfferCallback(c)
+		return
+	}
+
+	// Ответ сохраняется до отправки, чтобы кнопки оценки ссылались на его запись в истории
+	conversation.AppendHistory(e.repo, c.ChatID, "user", historyText)
+	historyID := conversation.AppendAnswer(e.repo, c.ChatID, result.Text)
+	if historyID != 0 {
+		conversation.SaveAnswerChunks(e.repo, historyID, result.Chunks)
+	}
+	msg := Outbound{Text: result.Text}
+	if config.Config.AnswerFeedbackEnabled && historyID != 0 {
+		msg.Buttons = feedbackButtons(c, historyID)
+	}
+	c.Send(msg)
+}
+
+func feedbackButtons(c *Conversation, historyID int64) [][]Button {
+	id := strconv.FormatInt(historyID, 10)
+	return [][]Button{{
+		{Text: c.T(msgFeedbackUp), Action: ActionFeedbackUp + id},
+		{Text: c.T(msgFeedbackDown), Action: ActionFeedbackDown + id},
+	}}
+}
+
+// rateAnswer stores the rating of an answer and thanks the user.
+func (e *Engine) rateAnswer(c *Conversation, payload string, rating int) {
+	historyID, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		log.Printf("Invalid feedback action: %s", payload)
+		return
+	}
+	if conversation.SaveFeedback(e.repo, c.ChatID, historyID, rating) {
+		c.Reply(c.T(msgFeedbackThanks))
+	}
+}
+
+// OfferCallback sends the button that starts the contact flow.
+func (e *Engine) OfferCallback(c *Conversation) {
+	c.Send(Outbound{
+		Text:    c.T(msgCallManagerPrompt),
+		Buttons: [][]Button{{{Text: c.T(msgCallManagerButton), Action: ActionCallManager}}},
+	})
+}
+
+func adminText(key string, vars ...i18n.Vars) string {
+	return i18n.T(i18n.DefaultLocale(), key, vars...)
+}
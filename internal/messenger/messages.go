@@ -0,0 +1,46 @@
+package messenger
+
+// Ключи сообщений каталога internal/i18n/locales, которые отправляет движок.
+const (
+	msgStartGreeting        = "start_greeting"
+	msgCallManagerButton    = "call_manager_button"
+	msgCallManagerPrompt    = "call_manager_prompt"
+	msgConfirmYes           = "confirm_yes"
+	msgConfirmNo            = "confirm_no"
+	msgConfirmContactFormat = "confirm_contact"
+	msgAskName              = "ask_name"
+	msgAskPhone             = "ask_phone"
+	msgManagerWillCall      = "manager_will_call"
+	msgUserError            = "user_error"
+	msgServiceUnavailable   = "service_unavailable"
+	msgInfoUnavailable      = "info_unavailable"
+	msgPricesTitle          = "prices_title"
+	msgAddressFormat        = "address"
+	msgPriceButtonFormat    = "price_button"
+	msgPriceDescription     = "price_description"
+	msgPriceDescPlain       = "price_description_plain"
+	msgPassHoursFormat      = "pass_hours"
+	msgGuestVisitsFormat    = "pass_guest_visits"
+	msgPassFreezeAllowed    = "pass_freeze_allowed"
+	msgPassLifetimeFormat   = "pass_lifetime"
+	msgPriceFormat          = "pass_price"
+	msgPriceFormatPlain     = "pass_price_plain"
+	msgPriceNotFound        = "price_not_found"
+	msgFeedbackUp           = "feedback_up"
+	msgFeedbackDown         = "feedback_down"
+	msgFeedbackThanks       = "feedback_thanks"
+)
+
+const (
+	msgAdminSummaryFormat = "admin_summary"
+	msgAdminErrorFormat   = "admin_error"
+	msgAdminLeadError     = "admin_lead_error"
+)
+
+const (
+	promptUserPrefix        = "prompt_user_prefix"
+	promptAssistantPrefix   = "prompt_assistant_prefix"
+	promptSummarizeGist     = "prompt_summarize_gist"
+	promptSummarizeTitle    = "prompt_summarize_title"
+	promptSummarizeInterest = "prompt_summarize_interest"
+)
@@ -0,0 +1,86 @@
+// Package messenger runs conversations with users independently of the
+// messaging channel. Channels plug in as adapters that turn their updates into
+// Inbound messages and deliver Outbound ones.
+package messenger
+
+import (
+	"log"
+
+	"ragbot/internal/i18n"
+)
+
+// Действия кнопок, общие для всех каналов
+const (
+	ActionCallManager  = "CALL_MANAGER"
+	ActionConfirmYes   = "CONFIRM_YES"
+	ActionConfirmNo    = "CONFIRM_NO"
+	ActionPricePrefix  = "PRICE_"
+	ActionFeedbackUp   = "FB_UP_"
+	ActionFeedbackDown = "FB_DOWN_"
+)
+
+// Inbound is a message or a button press of a user in a channel.
+type Inbound struct {
+	// ExternalID identifies the user's chat in the channel
+	ExternalID string
+	Username   string
+	// Language is the user's language code as reported by the channel
+	Language string
+	Text     string
+	// Action is the payload of the pressed button, empty for text messages
+	Action string
+}
+
+// Button is a keyboard button that either sends an action back or opens a URL.
+type Button struct {
+	Text   string
+	Action string
+	URL    string
+}
+
+// Outbound is a message to a user.
+type Outbound struct {
+	Text string
+	// MarkdownV2 is an optional Telegram MarkdownV2 variant of Text;
+	// adapters without markup send Text
+	MarkdownV2 string
+	// Buttons are keyboard rows attached to the message
+	Buttons [][]Button
+}
+
+// Adapter delivers messages to the users of one channel.
+type Adapter interface {
+	// Channel returns the channel name stored with conversations, e.g. "telegram"
+	Channel() string
+	Send(externalID string, msg Outbound) error
+}
+
+// Conversation is a chat with one user of a channel.
+type Conversation struct {
+	ChatID     int64
+	ExternalID string
+	Locale     string
+	adapter    Adapter
+}
+
+// NewConversation binds a stored conversation to the adapter of its channel.
+func NewConversation(adapter Adapter, chatID int64, externalID, locale string) *Conversation {
+	return &Conversation{ChatID: chatID, ExternalID: externalID, Locale: locale, adapter: adapter}
+}
+
+// T renders a catalog message in the conversation locale.
+func (c *Conversation) T(key string, vars ...i18n.Vars) string {
+	return i18n.T(c.Locale, key, vars...)
+}
+
+// Send delivers the message and logs delivery errors.
+func (c *Conversation) Send(msg Outbound) {
+	if err := c.adapter.Send(c.ExternalID, msg); err != nil {
+		log.Printf("Error sending %s message: %v", c.adapter.Channel(), err)
+	}
+}
+
+// Reply sends a plain text message.
+func (c *Conversation) Reply(text string) {
+	c.Send(Outbound{Text: text})
+}
@@ -0,0 +1,78 @@
+package messenger
+
+import (
+	"strings"
+	"testing"
+
+	"ragbot/internal/access"
+	"ragbot/internal/tansultant"
+)
+
+type recordingAdapter struct {
+	sent []Outbound
+}
+
+func (a *recordingAdapter) Channel() string { return "test" }
+
+func (a *recordingAdapter) Send(_ string, msg Outbound) error {
+	a.sent = append(a.sent, msg)
+	return nil
+}
+
+func TestOfferCallbackSendsCallButton(t *testing.T) {
+	adapter := &recordingAdapter{}
+	e := NewEngine(nil, nil, nil, func(access.Permission, string) {})
+	e.OfferCallback(NewConversation(adapter, 1, "1", "ru"))
+
+	if len(adapter.sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(adapter.sent))
+	}
+	buttons := adapter.sent[0].Buttons
+	if len(buttons) != 1 || len(buttons[0]) != 1 || buttons[0][0].Action != ActionCallManager {
+		t.Errorf("unexpected buttons: %+v", buttons)
+	}
+}
+
+func TestHandleContactInputOutsideFlow(t *testing.T) {
+	adapter := &recordingAdapter{}
+	e := NewEngine(nil, nil, nil, func(access.Permission, string) {})
+	if e.HandleContactInput(NewConversation(adapter, 1, "1", "ru"), "Анна") {
+		t.Error("text outside the contact flow must not be consumed")
+	}
+	if e.InContactFlow(1) {
+		t.Error("chat must not be in the contact flow")
+	}
+}
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	if got := escapeMarkdownV2("8 занятий (1.5 ч)!"); got != `8 занятий \(1\.5 ч\)\!` {
+		t.Errorf("unexpected escape: %s", got)
+	}
+}
+
+func TestPlainPriceDescriptionKeepsPassFields(t *testing.T) {
+	c := NewConversation(&recordingAdapter{}, 1, "1", "ru")
+	p := tansultant.Price{Name: "*VIP*", Description: "2 * 8 занятий", Price: "5000"}
+
+	got := priceDescription(c, p, plainPrice)
+	want := "Абонемент *VIP*\n2 * 8 занятий\n\n• Стоимость: 5000₽\n"
+	if got != want {
+		t.Errorf("unexpected plain description %q, want %q", got, want)
+	}
+	if md := priceDescription(c, p, markdownPrice); !strings.HasPrefix(md, `*Абонемент \*VIP\**`) {
+		t.Errorf("unexpected MarkdownV2 description %q", md)
+	}
+}
+
+func TestWebAdapterKeepsActionButtons(t *testing.T) {
+	a := &webAdapter{}
+	e := NewEngine(nil, nil, nil, func(access.Permission, string) {})
+	e.OfferCallback(NewConversation(a, 1, "token", "ru"))
+
+	if len(a.replies) != 1 || len(a.replies[0].Buttons) != 1 || a.replies[0].Buttons[0].Action != ActionCallManager {
+		t.Errorf("unexpected replies: %+v", a.replies)
+	}
+	if replies := e.HandleWeb("token", "", ActionCallManager); replies != nil {
+		t.Errorf("the widget must handle only price buttons, got %+v", replies)
+	}
+}
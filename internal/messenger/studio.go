@@ -0,0 +1,144 @@
+package messenger
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"ragbot/internal/i18n"
+	"ragbot/internal/tansultant"
+)
+
+// Кнопки цен содержат ID абонемента, а описание строится в момент нажатия
+// по закешированному клиентом Tansultant списку. Поэтому кнопки не зависят от того,
+// кто и когда последним открывал /prices, и продолжают работать после перезапуска.
+
+var markdownV2Replacer = strings.NewReplacer(
+	"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(",
+	"\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
+	"#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
+	"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// escapeMarkdownV2 escapes text inserted into a MarkdownV2 message.
+func escapeMarkdownV2(text string) string {
+	return markdownV2Replacer.Replace(text)
+}
+
+// ShowPrices sends the passes as buttons that open their descriptions.
+func (e *Engine) ShowPrices(c *Conversation) {
+	if e.studio == nil {
+		c.Reply(c.T(msgServiceUnavailable))
+		return
+	}
+	prices, err := e.studio.Prices(context.Background())
+	if err != nil || len(prices) == 0 {
+		log.Printf("Failed retrieving prices: %v", err)
+		c.Reply(c.T(msgInfoUnavailable))
+		return
+	}
+	var rows [][]Button
+	for _, p := range prices {
+		label := c.T(msgPriceButtonFormat, i18n.Vars{"Name": p.Name, "Price": p.Price})
+		rows = append(rows, []Button{{Text: label, Action: ActionPricePrefix + p.ID}})
+	}
+	c.Send(Outbound{Text: c.T(msgPricesTitle), Buttons: rows})
+}
+
+// ShowPrice sends the description of the pass and reports success.
+func (e *Engine) ShowPrice(c *Conversation, id string) bool {
+	if e.studio == nil {
+		c.Reply(c.T(msgServiceUnavailable))
+		return false
+	}
+	p, ok := e.findPrice(id)
+	if !ok {
+		log.Printf("Unknown price action: %s", id)
+		c.Reply(c.T(msgPriceNotFound))
+		return false
+	}
+	c.Send(Outbound{
+		Text:       priceDescription(c, p, plainPrice),
+		MarkdownV2: priceDescription(c, p, markdownPrice),
+	})
+	return true
+}
+
+// findPrice looks up a pass by its ID.
+func (e *Engine) findPrice(id string) (tansultant.Price, bool) {
+	prices, err := e.studio.Prices(context.Background())
+	if err != nil {
+		log.Printf("Failed retrieving prices: %v", err)
+		return tansultant.Price{}, false
+	}
+	for _, p := range prices {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return tansultant.Price{}, false
+}
+
+// priceMarkup selects the catalog messages of a pass description and the
+// escaping of the pass fields. The MarkdownV2 messages mark bold text with
+// asterisks, the plain ones have no markup.
+type priceMarkup struct {
+	description string
+	price       string
+	escape      func(string) string
+}
+
+var (
+	plainPrice    = priceMarkup{description: msgPriceDescPlain, price: msgPriceFormatPlain, escape: func(s string) string { return s }}
+	markdownPrice = priceMarkup{description: msgPriceDescription, price: msgPriceFormat, escape: escapeMarkdownV2}
+)
+
+// priceDescription renders the description of a pass with the markup.
+func priceDescription(c *Conversation, p tansultant.Price, m priceMarkup) string {
+	properties := ""
+	if p.Hours != "" {
+		properties += c.T(msgPassHoursFormat, i18n.Vars{"Hours": p.Hours})
+	}
+	if p.GuestVisits != "" {
+		properties += c.T(msgGuestVisitsFormat, i18n.Vars{"GuestVisits": p.GuestVisits})
+	}
+	if p.FreezeAllowed != "" {
+		properties += c.T(msgPassFreezeAllowed)
+	}
+	if p.Lifetime != "" {
+		properties += c.T(msgPassLifetimeFormat, i18n.Vars{"Lifetime": p.Lifetime})
+	}
+	properties = m.escape(properties)
+	if p.Price != "" {
+		properties += c.T(m.price, i18n.Vars{"Price": m.escape(p.Price)})
+	}
+	return c.T(m.description, i18n.Vars{"Name": m.escape(p.Name), "Description": m.escape(p.Description), "Properties": properties})
+}
+
+// Addresses returns the list of studio addresses and the branches. When the
+// addresses are unavailable it replies with an error and returns false.
+func (e *Engine) Addresses(c *Conversation) (string, []tansultant.Branch, bool) {
+	if e.studio == nil {
+		log.Printf("Failed retrieving addresses: Tansultant client is not instantiated")
+		c.Reply(c.T(msgServiceUnavailable))
+		return "", nil, false
+	}
+	branches, err := e.studio.Branches(context.Background())
+	if err != nil || len(branches) == 0 {
+		log.Printf("Failed retrieving addresses: %v", err)
+		c.Reply(c.T(msgInfoUnavailable))
+		return "", nil, false
+	}
+	var sb strings.Builder
+	for _, b := range branches {
+		sb.WriteString(c.T(msgAddressFormat, i18n.Vars{"Title": b.Title, "Address": b.Address}))
+	}
+	return sb.String(), branches, true
+}
+
+// ShowAddresses sends the studio addresses.
+func (e *Engine) ShowAddresses(c *Conversation) {
+	if text, _, ok := e.Addresses(c); ok {
+		c.Reply(text)
+	}
+}
@@ -0,0 +1,42 @@
+package messenger
+
+import (
+	"strings"
+
+	"ragbot/internal/handler"
+	"ragbot/internal/repository"
+)
+
+// webAdapter collects the replies of the engine to one request of the web widget.
+type webAdapter struct {
+	replies []handler.WebMessage
+}
+
+func (a *webAdapter) Channel() string {
+	return repository.ChannelWeb
+}
+
+func (a *webAdapter) Send(_ string, msg Outbound) error {
+	reply := handler.WebMessage{Text: msg.Text}
+	for _, row := range msg.Buttons {
+		for _, b := range row {
+			if b.Action != "" {
+				reply.Buttons = append(reply.Buttons, handler.WebButton{Text: b.Text, Action: b.Action})
+			}
+		}
+	}
+	a.replies = append(a.replies, reply)
+	return nil
+}
+
+// HandleWeb runs a command or a button action of the widget session through
+// the engine and returns the replies. It implements handler.WebChannel.
+// The widget collects contacts with its own form, so only price buttons are handled.
+func (e *Engine) HandleWeb(session, text, action string) []handler.WebMessage {
+	if action != "" && !strings.HasPrefix(action, ActionPricePrefix) {
+		return nil
+	}
+	a := &webAdapter{}
+	e.Handle(a, Inbound{ExternalID: session, Text: text, Action: action})
+	return a.replies
+}
@@ -3,6 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pgvector/pgvector-go"
@@ -13,7 +18,14 @@ type Repository struct {
 	db *sql.DB
 }
 
-const historyCallRequested = "** хочет, чтобы ему перезвонили **"
+// Служебные отметки в истории переписки, общие для всех каналов.
+// Не локализуются: по ним считается статистика.
+const (
+	HistoryCallRequested  = "** хочет, чтобы ему перезвонили **"
+	HistoryBookingCreated = "** записался на пробное занятие **"
+	HistoryConfirmYes     = "** подтвердил контактные данные **"
+	HistoryConfirmNo      = "** опроверг контактные данные **"
+)
 
 // botUserAgentRegex is a case-insensitive regular expression that matches
 // common bot user agents. It is used to filter out automated visits when
@@ -30,8 +42,14 @@ func (r *Repository) Ping(ctx context.Context) error {
 
 // AddChunk inserts a new chunk. It returns its ID the row was inserted.
 func (r *Repository) AddChunk(ctx context.Context, content, source string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	var id int
-	err := r.db.QueryRowContext(ctx,
+	err = tx.QueryRowContext(ctx,
 		"INSERT INTO chunks(content, source) VALUES($1,$2) ON CONFLICT (content) DO NOTHING RETURNING id",
 		content, source,
 	).Scan(&id)
@@ -41,24 +59,65 @@ func (r *Repository) AddChunk(ctx context.Context, content, source string) (int,
 	if err != nil {
 		return 0, err
 	}
-	return id, nil
+	if err := addRevision(ctx, tx, id, RevisionCreate, source, "", "", content); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
 }
 
 func (r *Repository) DeleteChunk(ctx context.Context, id int) (string, error) {
-	var content string
-	err := r.db.QueryRowContext(ctx, "DELETE FROM chunks WHERE id=$1 RETURNING content", id).Scan(&content)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", err
 	}
-	return content, nil
+	defer tx.Rollback()
+
+	var content, source, extID string
+	err = tx.QueryRowContext(ctx,
+		"DELETE FROM chunks WHERE id=$1 RETURNING content, COALESCE(source, ''), COALESCE(ext_id, '')", id,
+	).Scan(&content, &source, &extID)
+	if err != nil {
+		return "", err
+	}
+	if err := addRevision(ctx, tx, id, RevisionDelete, source, extID, content, ""); err != nil {
+		return "", err
+	}
+	return content, tx.Commit()
 }
 
 func (r *Repository) UpdateChunk(ctx context.Context, id int, content string) error {
-	_, err := r.db.ExecContext(ctx,
-		"UPDATE chunks SET content=$1, embedding=NULL, processed_at=NULL WHERE id=$2",
-		content, id,
-	)
-	return err
+	return r.updateChunk(ctx, id, content, RevisionUpdate,
+		"UPDATE chunks SET content=$1, embedding=NULL, processed_at=NULL WHERE id=$2", content, id)
+}
+
+// updateChunk runs the update query of the chunk and records the change of its content.
+func (r *Repository) updateChunk(ctx context.Context, id int, content, action, query string, args ...any) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before, source, extID string
+	err = tx.QueryRowContext(ctx,
+		"SELECT content, COALESCE(source, ''), COALESCE(ext_id, '') FROM chunks WHERE id=$1 FOR UPDATE", id,
+	).Scan(&before, &source, &extID)
+	if err == sql.ErrNoRows {
+		// Как и раньше, обновление отсутствующего фрагмента ничего не меняет
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	if before != content {
+		if err := addRevision(ctx, tx, id, action, source, extID, before, content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // GetUnprocessedChunks returns chunks without embedding limited by n.
@@ -90,25 +149,50 @@ func (r *Repository) UpdateChunkEmbedding(ctx context.Context, id int, vec []flo
 }
 
 func (r *Repository) SearchChunks(ctx context.Context, vec []float32, limit int) ([]string, error) {
+	matches, err := r.SearchChunkMatches(ctx, vec, limit)
+	out := make([]string, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.Content)
+	}
+	return out, err
+}
+
+// ChunkMatch is a chunk found by a vector search with its distance to the query.
+type ChunkMatch struct {
+	ID       int
+	Content  string
+	Source   string
+	Distance float64
+}
+
+// SearchChunkMatches returns the closest chunks to the vector with their IDs and distances.
+func (r *Repository) SearchChunkMatches(ctx context.Context, vec []float32, limit int) ([]ChunkMatch, error) {
 	rows, err := r.db.QueryContext(ctx,
-		"SELECT content FROM chunks WHERE processed_at IS NOT NULL ORDER BY embedding <-> $1 LIMIT $2",
+		"SELECT id, content, COALESCE(source, ''), embedding <-> $1 FROM chunks WHERE processed_at IS NOT NULL ORDER BY embedding <-> $1 LIMIT $2",
 		pgvector.NewVector(vec), limit,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var out []string
+	var out []ChunkMatch
 	for rows.Next() {
-		var c string
-		if err := rows.Scan(&c); err != nil {
+		var m ChunkMatch
+		if err := rows.Scan(&m.ID, &m.Content, &m.Source, &m.Distance); err != nil {
 			return out, err
 		}
-		out = append(out, c)
+		out = append(out, m)
 	}
 	return out, nil
 }
 
+// GetChunk returns a chunk by ID.
+func (r *Repository) GetChunk(ctx context.Context, id int) (models.Chunk, error) {
+	c := models.Chunk{ID: id}
+	err := r.db.QueryRowContext(ctx, "SELECT content FROM chunks WHERE id=$1", id).Scan(&c.Content)
+	return c, err
+}
+
 func (r *Repository) GetChunkByExtID(ctx context.Context, source, extID string) (id int, createdAt time.Time, content string, found bool, err error) {
 	err = r.db.QueryRowContext(ctx,
 		"SELECT id, created_at, content FROM chunks WHERE source=$1 AND ext_id=$2",
@@ -124,19 +208,30 @@ func (r *Repository) GetChunkByExtID(ctx context.Context, source, extID string)
 }
 
 func (r *Repository) InsertChunkWithExtID(ctx context.Context, content, source, extID string, createdAt time.Time) error {
-	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO chunks(content, source, ext_id, created_at) VALUES($1,$2,$3,$4)",
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO chunks(content, source, ext_id, created_at) VALUES($1,$2,$3,$4) RETURNING id",
 		content, source, extID, createdAt,
-	)
-	return err
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+	if err := addRevision(ctx, tx, id, RevisionCreate, source, extID, "", content); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *Repository) UpdateChunkWithCreatedAt(ctx context.Context, id int, content string, createdAt time.Time) error {
-	_, err := r.db.ExecContext(ctx,
+	return r.updateChunk(ctx, id, content, RevisionUpdate,
 		"UPDATE chunks SET content=$1, created_at=$2, embedding=NULL, processed_at=NULL WHERE id=$3",
-		content, createdAt, id,
-	)
-	return err
+		content, createdAt, id)
 }
 
 func (r *Repository) UpdateChunkCreatedAt(ctx context.Context, id int, createdAt time.Time) error {
@@ -147,24 +242,437 @@ func (r *Repository) UpdateChunkCreatedAt(ctx context.Context, id int, createdAt
 	return err
 }
 
-// ListChunksWithoutExtID returns all chunks that don't have an external ID.
-func (r *Repository) ListChunksWithoutExtID(ctx context.Context) ([]models.Chunk, error) {
+// ListChunkExtIDs returns chunk IDs keyed by external ID for the given source.
+func (r *Repository) ListChunkExtIDs(ctx context.Context, source string) (map[string]int, error) {
 	rows, err := r.db.QueryContext(ctx,
-		"SELECT id, content FROM chunks WHERE ext_id IS NULL ORDER BY id")
+		"SELECT id, ext_id FROM chunks WHERE source=$1 AND ext_id IS NOT NULL", source)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var chunks []models.Chunk
+	ids := make(map[string]int)
 	for rows.Next() {
-		var c models.Chunk
-		if err := rows.Scan(&c.ID, &c.Content); err != nil {
-			return chunks, err
+		var id int
+		var extID string
+		if err := rows.Scan(&id, &extID); err != nil {
+			return ids, err
+		}
+		ids[extID] = id
+	}
+	return ids, rows.Err()
+}
+
+// Статусы векторизации фрагментов для фильтра списка
+const (
+	ChunkStatusReady   = "ready"
+	ChunkStatusPending = "pending"
+)
+
+// ChunkFilter selects chunks for the admin listing. Empty fields match all chunks.
+type ChunkFilter struct {
+	Source string
+	// Text is a case-insensitive substring of the content
+	Text   string
+	Status string
+}
+
+// ChunkListItem is a chunk of the admin listing.
+type ChunkListItem struct {
+	ID        int
+	Content   string
+	Source    string
+	Processed bool
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ListChunks returns a page of chunks matching the filter ordered by ID
+// and the total number of matching chunks.
+func (r *Repository) ListChunks(ctx context.Context, f ChunkFilter, offset, limit int) ([]ChunkListItem, int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, content, COALESCE(source, ''), processed_at IS NOT NULL, COUNT(*) OVER()
+                 FROM chunks
+                 WHERE ($1 = '' OR source = $1)
+                   AND ($2 = '' OR content ILIKE '%' || $2 || '%')
+                   AND ($3 = '' OR ($3 = 'ready') = (processed_at IS NOT NULL))
+                 ORDER BY id
+                 OFFSET $4 LIMIT $5`,
+		f.Source, likeEscaper.Replace(f.Text), f.Status, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var items []ChunkListItem
+	total := 0
+	for rows.Next() {
+		var c ChunkListItem
+		if err := rows.Scan(&c.ID, &c.Content, &c.Source, &c.Processed, &total); err != nil {
+			return items, total, err
+		}
+		items = append(items, c)
+	}
+	if len(items) == 0 && offset > 0 {
+		// Страница за концом списка, например после удаления последнего фрагмента на ней
+		err = r.db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM chunks
+                         WHERE ($1 = '' OR source = $1)
+                           AND ($2 = '' OR content ILIKE '%' || $2 || '%')
+                           AND ($3 = '' OR ($3 = 'ready') = (processed_at IS NOT NULL))`,
+			f.Source, likeEscaper.Replace(f.Text), f.Status).Scan(&total)
+	}
+	return items, total, err
+}
+
+// ListAllChunks returns all chunks of the source, or of the whole knowledge base
+// when source is empty, ordered by ID.
+func (r *Repository) ListAllChunks(ctx context.Context, source string) ([]ChunkListItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, content, COALESCE(source, ''), processed_at IS NOT NULL
+                 FROM chunks
+                 WHERE ($1 = '' OR source = $1)
+                 ORDER BY id`, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ChunkListItem
+	for rows.Next() {
+		var c ChunkListItem
+		if err := rows.Scan(&c.ID, &c.Content, &c.Source, &c.Processed); err != nil {
+			return items, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// --- chunk history ---
+
+// Действия в истории фрагментов
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+type authorKey struct{}
+
+// WithAuthor returns a context whose chunk changes are recorded under the author,
+// e.g. an admin chat ID. Without it the source of the chunk is the author.
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// ChunkRevision is a recorded change of a chunk. ExtID is set for chunks
+// synced from their source.
+type ChunkRevision struct {
+	ID        int64
+	ChunkID   int
+	Action    string
+	Author    string
+	Source    string
+	ExtID     string
+	Before    string
+	After     string
+	CreatedAt time.Time
+}
+
+// Content returns the chunk text the revision restores: the text after the
+// change or, for a deletion, the deleted text.
+func (rev ChunkRevision) Content() string {
+	if rev.Action == RevisionDelete {
+		return rev.Before
+	}
+	return rev.After
+}
+
+func addRevision(ctx context.Context, tx *sql.Tx, chunkID int, action, source, extID, before, after string) error {
+	author, _ := ctx.Value(authorKey{}).(string)
+	if author == "" {
+		author = source
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO chunk_revisions(chunk_id, action, author, source, ext_id, content_before, content_after) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		chunkID, action, author, source, extID, before, after,
+	)
+	return err
+}
+
+const revisionColumns = "id, chunk_id, action, author, source, ext_id, content_before, content_after, created_at"
+
+func scanRevisions(rows *sql.Rows) ([]ChunkRevision, error) {
+	defer rows.Close()
+	var out []ChunkRevision
+	for rows.Next() {
+		var rev ChunkRevision
+		if err := rows.Scan(&rev.ID, &rev.ChunkID, &rev.Action, &rev.Author, &rev.Source, &rev.ExtID, &rev.Before, &rev.After, &rev.CreatedAt); err != nil {
+			return out, err
+		}
+		out = append(out, rev)
+	}
+	return out, rows.Err()
+}
+
+// ListChunkRevisions returns the latest changes of the chunk, newest first.
+func (r *Repository) ListChunkRevisions(ctx context.Context, chunkID, limit int) ([]ChunkRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+revisionColumns+" FROM chunk_revisions WHERE chunk_id=$1 ORDER BY id DESC LIMIT $2",
+		chunkID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRevisions(rows)
+}
+
+// ListRecentRevisions returns the latest changes of all chunks, newest first.
+func (r *Repository) ListRecentRevisions(ctx context.Context, limit int) ([]ChunkRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+revisionColumns+" FROM chunk_revisions ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRevisions(rows)
+}
+
+// GetChunkRevision returns a change by its ID.
+func (r *Repository) GetChunkRevision(ctx context.Context, id int64) (ChunkRevision, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+revisionColumns+" FROM chunk_revisions WHERE id=$1", id)
+	if err != nil {
+		return ChunkRevision{}, err
+	}
+	revs, err := scanRevisions(rows)
+	if err == nil && len(revs) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return ChunkRevision{}, err
+	}
+	return revs[0], nil
+}
+
+// ErrSyncedChunk is returned when restoring a chunk synced from its source:
+// the next sync would undo the restore or keep a copy of the chunk.
+var ErrSyncedChunk = errors.New("chunk is synced from its source")
+
+// RestoreChunkRevision returns the chunk to the version of the revision.
+// A deleted chunk is recreated with the same ID. The restore is recorded as
+// a new revision, so it can be undone as well. Synced chunks are not restored.
+func (r *Repository) RestoreChunkRevision(ctx context.Context, revisionID int64) (ChunkRevision, error) {
+	rev, err := r.GetChunkRevision(ctx, revisionID)
+	if err != nil {
+		return rev, err
+	}
+	if rev.ExtID != "" {
+		return rev, ErrSyncedChunk
+	}
+	content := rev.Content()
+	if content == "" {
+		return rev, fmt.Errorf("revision %d has no content", revisionID)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return rev, err
+	}
+	defer tx.Rollback()
+
+	var before, source, extID string
+	err = tx.QueryRowContext(ctx,
+		"SELECT content, COALESCE(source, ''), COALESCE(ext_id, '') FROM chunks WHERE id=$1 FOR UPDATE", rev.ChunkID,
+	).Scan(&before, &source, &extID)
+	switch {
+	case err == nil && extID != "":
+		return rev, ErrSyncedChunk
+	case err == sql.ErrNoRows:
+		source = rev.Source
+		_, err = tx.ExecContext(ctx, "INSERT INTO chunks(id, content, source) VALUES($1,$2,$3)", rev.ChunkID, content, source)
+	case err == nil && before == content:
+		return rev, nil
+	case err == nil:
+		_, err = tx.ExecContext(ctx, "UPDATE chunks SET content=$1, embedding=NULL, processed_at=NULL WHERE id=$2", content, rev.ChunkID)
+	}
+	if err != nil {
+		return rev, err
+	}
+	if err := addRevision(ctx, tx, rev.ChunkID, RevisionRestore, source, "", before, content); err != nil {
+		return rev, err
+	}
+	return rev, tx.Commit()
+}
+
+// --- admin accounts ---
+
+// Admin is an account of the admin bot and the web pages.
+type Admin struct {
+	ID           int
+	Role         string
+	ChatID       sql.NullInt64
+	Login        sql.NullString
+	PasswordHash string
+}
+
+// AdminRef identifies an admin account by its web login or, when Login is empty, by its chat ID.
+type AdminRef struct {
+	ChatID int64
+	Login  string
+}
+
+func (ref AdminRef) where() (string, any) {
+	if ref.Login != "" {
+		return "login=$1", ref.Login
+	}
+	return "chat_id=$1", ref.ChatID
+}
+
+const adminColumns = "id, role, chat_id, login, password_hash"
+
+func scanAdmin(row interface{ Scan(...any) error }) (Admin, error) {
+	var a Admin
+	err := row.Scan(&a.ID, &a.Role, &a.ChatID, &a.Login, &a.PasswordHash)
+	return a, err
+}
+
+// ListAdmins returns all admin accounts ordered by ID.
+func (r *Repository) ListAdmins(ctx context.Context) ([]Admin, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+adminColumns+" FROM admins ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Admin
+	for rows.Next() {
+		a, err := scanAdmin(rows)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// GetAdmin returns the admin account or sql.ErrNoRows.
+func (r *Repository) GetAdmin(ctx context.Context, ref AdminRef) (Admin, error) {
+	cond, arg := ref.where()
+	return scanAdmin(r.db.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE "+cond, arg))
+}
+
+// BootstrapAdmins creates the accounts when there are no admins yet.
+// It reports whether the accounts were created.
+func (r *Repository) BootstrapAdmins(ctx context.Context, admins []Admin) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка не даёт двум экземплярам создать учётные записи одновременно
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE admins IN EXCLUSIVE MODE"); err != nil {
+		return false, err
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM admins").Scan(&n); err != nil {
+		return false, err
+	}
+	if n > 0 || len(admins) == 0 {
+		return false, nil
+	}
+	for _, a := range admins {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO admins(role, chat_id, login, password_hash) VALUES($1,$2,$3,$4)",
+			a.Role, a.ChatID, a.Login, a.PasswordHash)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// SetAdminRole changes the role of the account or creates it. It reports whether the account was created.
+func (r *Repository) SetAdminRole(ctx context.Context, ref AdminRef, role string) (bool, error) {
+	cond, arg := ref.where()
+	res, err := r.db.ExecContext(ctx, "UPDATE admins SET role=$2 WHERE "+cond, arg, role)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return false, nil
+	}
+	var chatID sql.NullInt64
+	var login sql.NullString
+	if ref.Login != "" {
+		login = sql.NullString{String: ref.Login, Valid: true}
+	} else {
+		chatID = sql.NullInt64{Int64: ref.ChatID, Valid: true}
+	}
+	_, err = r.db.ExecContext(ctx, "INSERT INTO admins(role, chat_id, login) VALUES($1,$2,$3)", role, chatID, login)
+	return err == nil, err
+}
+
+// LinkAdminLogin gives the Telegram account the web login. A web-only account
+// with the same login is merged into it, keeping its password.
+func (r *Repository) LinkAdminLogin(ctx context.Context, chatID int64, login string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	target, err := scanAdmin(tx.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE chat_id=$1 FOR UPDATE", chatID))
+	if err != nil {
+		return err
+	}
+	other, err := scanAdmin(tx.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE login=$1 FOR UPDATE", login))
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case other.ID == target.ID:
+		return tx.Commit()
+	case other.ChatID.Valid:
+		return fmt.Errorf("login %q belongs to chat %d", login, other.ChatID.Int64)
+	default:
+		if _, err := tx.ExecContext(ctx, "DELETE FROM admins WHERE id=$1", other.ID); err != nil {
+			return err
 		}
-		chunks = append(chunks, c)
+		if target.PasswordHash == "" {
+			target.PasswordHash = other.PasswordHash
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE admins SET login=$1, password_hash=$2 WHERE id=$3", login, target.PasswordHash, target.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetAdminPassword stores the web password hash of the account.
+func (r *Repository) SetAdminPassword(ctx context.Context, ref AdminRef, hash string) error {
+	cond, arg := ref.where()
+	res, err := r.db.ExecContext(ctx, "UPDATE admins SET password_hash=$2 WHERE "+cond, arg, hash)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
 	}
-	return chunks, nil
+	return nil
+}
+
+// DeleteAdmin removes the account or returns sql.ErrNoRows.
+func (r *Repository) DeleteAdmin(ctx context.Context, ref AdminRef) error {
+	cond, arg := ref.where()
+	res, err := r.db.ExecContext(ctx, "DELETE FROM admins WHERE "+cond, arg)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 // --- conversation operations ---
@@ -179,17 +687,27 @@ type ChatInfo struct {
 	Name         sql.NullString
 	Phone        sql.NullString
 	AmoContactID sql.NullInt64
+	// UTM-метки визита на лендинг, с которого пришёл пользователь
+	UtmSource   sql.NullString
+	UtmMedium   sql.NullString
+	UtmCampaign sql.NullString
+	UtmContent  sql.NullString
+	UtmTerm     sql.NullString
 }
 
 type HistoryItem struct {
+	ID      int64
 	Role    string
 	Content string
+	// Rating is the user's feedback on an assistant answer: 1, -1 or 0 when not rated.
+	Rating int
 }
 
 // ChatSummary holds information for listing chats.
 type ChatSummary struct {
 	ID       string
 	ChatID   int64
+	Channel  string
 	Username sql.NullString
 	Name     sql.NullString
 	Title    sql.NullString
@@ -198,33 +716,108 @@ type ChatSummary struct {
 	LastAt   time.Time
 }
 
-// AddVisit stores a visit to the landing page.
-func (r *Repository) AddVisit(ctx context.Context, ip, ua, referer, utmSource, utmMedium, utmCampaign, utmContent, utmTerm string) error {
+// AddVisit stores a visit to the landing page. The token is passed to the bot
+// in the /start payload to attribute the conversation to the visit.
+func (r *Repository) AddVisit(ctx context.Context, token, ip, ua, referer, utmSource, utmMedium, utmCampaign, utmContent, utmTerm string) error {
 	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO visits(ip, user_agent, referer, utm_source, utm_medium, utm_campaign, utm_content, utm_term) VALUES($1,$2,$3,$4,$5,$6,$7,$8)`,
-		ip, ua, referer, utmSource, utmMedium, utmCampaign, utmContent, utmTerm,
+		`INSERT INTO visits(token, ip, user_agent, referer, utm_source, utm_medium, utm_campaign, utm_content, utm_term) VALUES(NULLIF($1,''),$2,$3,$4,$5,$6,$7,$8,$9)`,
+		token, ip, ua, referer, utmSource, utmMedium, utmCampaign, utmContent, utmTerm,
 	)
 	return err
 }
 
-func (r *Repository) EnsureSession(ctx context.Context, chatID int64, username string) (string, error) {
+// LinkVisit attributes the conversation to the visit with the token. The first
+// visit wins: later tokens are recorded on their visits but do not replace it.
+// It reports whether the token was found.
+func (r *Repository) LinkVisit(ctx context.Context, token string, chatID int64) (bool, error) {
+	var visitID int64
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE visits SET chat_id=$2, started_at=NOW() WHERE token=$1 AND chat_id IS NULL RETURNING id`,
+		token, chatID).Scan(&visitID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE conversations SET visit_id=$1 WHERE chat_id=$2 AND visit_id IS NULL`, visitID, chatID)
+	return true, err
+}
+
+// EnsureSession returns the UUID of the Telegram conversation with the chat, creating it if needed.
+func (r *Repository) EnsureSession(ctx context.Context, chatID int64, username, language string) (string, error) {
+	_, uuid, err := r.EnsureConversation(ctx, ChannelTelegram, strconv.FormatInt(chatID, 10), username, language)
+	return uuid, err
+}
+
+// EnsureConversation returns the chat ID and UUID of the conversation with the user
+// of the channel, creating it on the first message. Telegram conversations keep
+// the Telegram chat ID, other channels get IDs from channel_chat_id_seq.
+func (r *Repository) EnsureConversation(ctx context.Context, channel, externalID, username, language string) (int64, string, error) {
+	var chatID int64
 	var uuid string
-	err := r.db.QueryRowContext(ctx, `SELECT uuid FROM conversations WHERE chat_id=$1`, chatID).Scan(&uuid)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT chat_id, uuid FROM conversations WHERE channel=$1 AND external_id=$2`, channel, externalID).Scan(&chatID, &uuid)
 	if err == sql.ErrNoRows {
-		err = r.db.QueryRowContext(ctx, `INSERT INTO conversations(chat_id, username) VALUES($1,$2) RETURNING uuid`, chatID, username).Scan(&uuid)
+		if channel == ChannelTelegram {
+			chatID, err = strconv.ParseInt(externalID, 10, 64)
+			if err != nil {
+				return 0, "", err
+			}
+			err = r.db.QueryRowContext(ctx,
+				`INSERT INTO conversations(chat_id, channel, external_id, username, language) VALUES($1,$2,$3,$4,NULLIF($5,'')) RETURNING uuid`,
+				chatID, channel, externalID, username, language).Scan(&uuid)
+		} else {
+			err = r.db.QueryRowContext(ctx,
+				`INSERT INTO conversations(chat_id, channel, external_id, username, language)
+                                 VALUES(nextval('channel_chat_id_seq'),$1,$2,$3,NULLIF($4,'')) RETURNING chat_id, uuid`,
+				channel, externalID, username, language).Scan(&chatID, &uuid)
+		}
 	} else if err == nil {
+		// Пользователь снова пишет боту, значит, он его разблокировал
+		r.db.ExecContext(ctx, `UPDATE conversations SET blocked_at=NULL WHERE chat_id=$1 AND blocked_at IS NOT NULL`, chatID)
 		if username != "" {
 			r.db.ExecContext(ctx, `UPDATE conversations SET username=$1 WHERE chat_id=$2`, username, chatID)
 		}
+		if language != "" {
+			r.db.ExecContext(ctx, `UPDATE conversations SET language=$1 WHERE chat_id=$2`, language, chatID)
+		}
 	}
-	return uuid, err
+	return chatID, uuid, err
+}
+
+// Каналы, по которым пользователи общаются с ассистентом
+const (
+	ChannelTelegram = "telegram"
+	ChannelWeb      = "web"
+	ChannelVK       = "vk"
+)
+
+// CreateWebSession creates a conversation for an anonymous website visitor
+// identified by the session token and returns its chat ID.
+func (r *Repository) CreateWebSession(ctx context.Context, token, language string) (int64, error) {
+	chatID, _, err := r.EnsureConversation(ctx, ChannelWeb, token, "", language)
+	return chatID, err
+}
+
+// GetWebSession returns the chat ID of the web session with the token.
+func (r *Repository) GetWebSession(ctx context.Context, token string) (int64, error) {
+	var chatID int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT chat_id FROM conversations WHERE channel=$1 AND external_id=$2`, ChannelWeb, token).Scan(&chatID)
+	return chatID, err
 }
 
 func (r *Repository) GetChatInfoByChatID(ctx context.Context, chatID int64) (ChatInfo, error) {
 	var info ChatInfo
 	err := r.db.QueryRowContext(ctx,
-		`SELECT uuid, username, summary, title, interest, name, phone, amo_contact_id FROM conversations WHERE chat_id=$1`, chatID).
-		Scan(&info.ID, &info.Username, &info.Summary, &info.Title, &info.Interest, &info.Name, &info.Phone, &info.AmoContactID)
+		`SELECT c.uuid, c.username, c.summary, c.title, c.interest, c.name, c.phone, c.amo_contact_id,
+                        v.utm_source, v.utm_medium, v.utm_campaign, v.utm_content, v.utm_term
+                 FROM conversations c LEFT JOIN visits v ON v.id=c.visit_id
+                 WHERE c.chat_id=$1`, chatID).
+		Scan(&info.ID, &info.Username, &info.Summary, &info.Title, &info.Interest, &info.Name, &info.Phone, &info.AmoContactID,
+			&info.UtmSource, &info.UtmMedium, &info.UtmCampaign, &info.UtmContent, &info.UtmTerm)
 	if err != nil {
 		return info, err
 	}
@@ -235,8 +828,12 @@ func (r *Repository) GetChatInfoByChatID(ctx context.Context, chatID int64) (Cha
 func (r *Repository) GetChatInfoByUUID(ctx context.Context, uuid string) (ChatInfo, error) {
 	var info ChatInfo
 	err := r.db.QueryRowContext(ctx,
-		`SELECT chat_id, username, summary, title, interest, name, phone, amo_contact_id FROM conversations WHERE uuid=$1`, uuid).
-		Scan(&info.ChatID, &info.Username, &info.Summary, &info.Title, &info.Interest, &info.Name, &info.Phone, &info.AmoContactID)
+		`SELECT c.chat_id, c.username, c.summary, c.title, c.interest, c.name, c.phone, c.amo_contact_id,
+                        v.utm_source, v.utm_medium, v.utm_campaign, v.utm_content, v.utm_term
+                 FROM conversations c LEFT JOIN visits v ON v.id=c.visit_id
+                 WHERE c.uuid=$1`, uuid).
+		Scan(&info.ChatID, &info.Username, &info.Summary, &info.Title, &info.Interest, &info.Name, &info.Phone, &info.AmoContactID,
+			&info.UtmSource, &info.UtmMedium, &info.UtmCampaign, &info.UtmContent, &info.UtmTerm)
 	if err != nil {
 		return info, err
 	}
@@ -244,6 +841,14 @@ func (r *Repository) GetChatInfoByUUID(ctx context.Context, uuid string) (ChatIn
 	return info, nil
 }
 
+// GetChatLanguage returns the language stored for the conversation, empty if unknown.
+func (r *Repository) GetChatLanguage(ctx context.Context, chatID int64) (string, error) {
+	var language string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COALESCE(language, '') FROM conversations WHERE chat_id=$1`, chatID).Scan(&language)
+	return language, err
+}
+
 func (r *Repository) UpdateSummary(ctx context.Context, chatID int64, summary, title, interest string) error {
 	_, err := r.db.ExecContext(ctx,
 		`UPDATE conversations SET summary=$1, title=$2, interest=$3, updated_at=NOW() WHERE chat_id=$4`, summary, title, interest, chatID)
@@ -276,9 +881,32 @@ func (r *Repository) AppendHistory(ctx context.Context, chatID int64, role, text
 	return err
 }
 
-func (r *Repository) GetHistory(ctx context.Context, chatID int64, limit int) ([]HistoryItem, error) {
+// AppendHistoryReturningID stores a history message and returns its ID.
+func (r *Repository) AppendHistoryReturningID(ctx context.Context, chatID int64, role, text string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO conversation_history(chat_id, role, content) VALUES ($1, $2, $3) RETURNING id`,
+		chatID, role, text,
+	).Scan(&id)
+	return id, err
+}
+
+// AppendUserHistory stores a message of a group chat participant or the answer to them
+// and returns its ID.
+func (r *Repository) AppendUserHistory(ctx context.Context, chatID, userID int64, role, text string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO conversation_history(chat_id, user_id, role, content) VALUES ($1, $2, $3, $4) RETURNING id`,
+		chatID, userID, role, text,
+	).Scan(&id)
+	return id, err
+}
+
+// GetUserHistory returns the last messages of a group chat participant and the answers to them.
+func (r *Repository) GetUserHistory(ctx context.Context, chatID, userID int64, limit int) ([]HistoryItem, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT role, content FROM conversation_history WHERE chat_id=$1 ORDER BY id DESC LIMIT $2`, chatID, limit)
+		`SELECT role, content FROM conversation_history WHERE chat_id=$1 AND user_id=$2 ORDER BY id DESC LIMIT $3`,
+		chatID, userID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -291,16 +919,15 @@ func (r *Repository) GetHistory(ctx context.Context, chatID int64, limit int) ([
 		}
 		items = append(items, it)
 	}
-	// reverse
 	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
 		items[i], items[j] = items[j], items[i]
 	}
-	return items, nil
+	return items, rows.Err()
 }
 
-func (r *Repository) GetFullHistory(ctx context.Context, chatID int64) ([]HistoryItem, error) {
+func (r *Repository) GetHistory(ctx context.Context, chatID int64, limit int) ([]HistoryItem, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT role, content FROM conversation_history WHERE chat_id=$1 ORDER BY id ASC`, chatID)
+		`SELECT role, content FROM conversation_history WHERE chat_id=$1 ORDER BY id DESC LIMIT $2`, chatID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -313,24 +940,57 @@ func (r *Repository) GetFullHistory(ctx context.Context, chatID int64) ([]Histor
 		}
 		items = append(items, it)
 	}
+	// reverse
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
 	return items, nil
 }
 
-// CountUniqueChats returns number of unique chat IDs in conversation history.
-func (r *Repository) CountUniqueChats(ctx context.Context) (int, error) {
-	var n int
-	err := r.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT chat_id) FROM conversation_history`).Scan(&n)
-	return n, err
-}
-
+func (r *Repository) GetFullHistory(ctx context.Context, chatID int64) ([]HistoryItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT h.id, h.role, h.content, COALESCE(f.rating, 0)
+                 FROM conversation_history h
+                 LEFT JOIN answer_feedback f ON f.history_id = h.id
+                 WHERE h.chat_id=$1 ORDER BY h.id ASC`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []HistoryItem
+	for rows.Next() {
+		var it HistoryItem
+		if err := rows.Scan(&it.ID, &it.Role, &it.Content, &it.Rating); err != nil {
+			return items, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// CountUniqueChats returns number of unique chat IDs in conversation history.
+func (r *Repository) CountUniqueChats(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT chat_id) FROM conversation_history`).Scan(&n)
+	return n, err
+}
+
 // CountDeals returns number of unique chats where a call to manager was requested.
 func (r *Repository) CountDeals(ctx context.Context) (int, error) {
 	var n int
 	err := r.db.QueryRowContext(ctx,
-		`SELECT COUNT(DISTINCT chat_id) FROM conversation_history WHERE content=$1`, historyCallRequested).Scan(&n)
+		`SELECT COUNT(DISTINCT chat_id) FROM conversation_history WHERE content=$1`, HistoryCallRequested).Scan(&n)
 	return n, err
 }
 
+// HasCallRequest reports whether the chat has ever requested a call from a manager.
+func (r *Repository) HasCallRequest(ctx context.Context, chatID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM conversation_history WHERE chat_id=$1 AND content=$2)`, chatID, HistoryCallRequested).Scan(&exists)
+	return exists, err
+}
+
 // CountUniqueVisits returns number of unique visits based on IP and user agent.
 func (r *Repository) CountUniqueVisits(ctx context.Context) (int, error) {
 	var n int
@@ -351,6 +1011,63 @@ func (r *Repository) CountCommandUsage(ctx context.Context, cmd string) (int, er
 	return n, err
 }
 
+// CampaignFunnel is the funnel of one UTM source and campaign:
+// landing visits, conversations started from them and leads.
+type CampaignFunnel struct {
+	Source   string
+	Campaign string
+	Visits   int
+	Chats    int
+	Leads    int
+}
+
+// ChatRate returns the share of visits that started a conversation in percent.
+func (f CampaignFunnel) ChatRate() float64 {
+	if f.Visits == 0 {
+		return 0
+	}
+	return float64(f.Chats) / float64(f.Visits) * 100
+}
+
+// LeadRate returns the share of visits that ended with a lead in percent.
+func (f CampaignFunnel) LeadRate() float64 {
+	if f.Visits == 0 {
+		return 0
+	}
+	return float64(f.Leads) / float64(f.Visits) * 100
+}
+
+// CampaignFunnels returns the funnel by utm_source and utm_campaign for visits in the last days.
+// A lead is a conversation attributed to the visit where the user requested a call or booked a lesson.
+func (r *Repository) CampaignFunnels(ctx context.Context, days int) ([]CampaignFunnel, error) {
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT COALESCE(NULLIF(v.utm_source, ''), '—'), COALESCE(NULLIF(v.utm_campaign, ''), '—'),
+                      COUNT(*),
+                      COUNT(c.chat_id),
+                      COUNT(c.chat_id) FILTER (WHERE EXISTS(
+                          SELECT 1 FROM conversation_history h
+                          WHERE h.chat_id=c.chat_id AND h.content IN ($3, $4)))
+               FROM visits v
+               LEFT JOIN conversations c ON c.visit_id=v.id
+               WHERE v.created_at >= NOW() - make_interval(days => $1)
+                 AND v.user_agent !~* $2 AND v.user_agent IS NOT NULL AND v.user_agent != ''
+               GROUP BY 1, 2
+               ORDER BY 3 DESC, 1, 2`, days, botUserAgentRegex, HistoryCallRequested, HistoryBookingCreated)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CampaignFunnel
+	for rows.Next() {
+		var f CampaignFunnel
+		if err := rows.Scan(&f.Source, &f.Campaign, &f.Visits, &f.Chats, &f.Leads); err != nil {
+			return out, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
 // MessageCountsBeforeDeal returns for each chat id that generated a lead the number of user messages before the request.
 func (r *Repository) MessageCountsBeforeDeal(ctx context.Context) ([]struct {
 	ID       string
@@ -360,7 +1077,7 @@ func (r *Repository) MessageCountsBeforeDeal(ctx context.Context) ([]struct {
 	Count    int
 }, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT DISTINCT c.uuid, c.chat_id, c.username, c.name FROM conversations c JOIN conversation_history h ON c.chat_id=h.chat_id WHERE h.content=$1`, historyCallRequested)
+		`SELECT DISTINCT c.uuid, c.chat_id, c.username, c.name FROM conversations c JOIN conversation_history h ON c.chat_id=h.chat_id WHERE h.content=$1`, HistoryCallRequested)
 	if err != nil {
 		return nil, err
 	}
@@ -383,7 +1100,7 @@ func (r *Repository) MessageCountsBeforeDeal(ctx context.Context) ([]struct {
 		var count int
 		err := r.db.QueryRowContext(ctx,
 			`SELECT COUNT(*) FROM conversation_history WHERE chat_id=$1 AND role='user' AND id <= (SELECT id FROM conversation_history WHERE chat_id=$1 AND content=$2 ORDER BY id ASC LIMIT 1)`,
-			chatID, historyCallRequested).Scan(&count)
+			chatID, HistoryCallRequested).Scan(&count)
 		if err != nil {
 			return result, err
 		}
@@ -401,7 +1118,7 @@ func (r *Repository) MessageCountsBeforeDeal(ctx context.Context) ([]struct {
 // ListChats returns chats sorted by last message time desc with pagination.
 func (r *Repository) ListChats(ctx context.Context, limit, offset int) ([]ChatSummary, error) {
 	rows, err := r.db.QueryContext(ctx, `
-               SELECT c.uuid, c.chat_id, c.username, c.name, c.title,
+               SELECT c.uuid, c.chat_id, c.channel, c.username, c.name, c.title,
                       EXISTS(SELECT 1 FROM conversation_history h2 WHERE h2.chat_id=c.chat_id AND h2.content=$1) AS has_deal,
                       h.content, h.created_at
                FROM conversations c
@@ -411,7 +1128,7 @@ func (r *Repository) ListChats(ctx context.Context, limit, offset int) ([]ChatSu
                        ORDER BY id DESC LIMIT 1
                ) h ON true
                ORDER BY h.created_at DESC
-               LIMIT $2 OFFSET $3`, historyCallRequested, limit, offset)
+               LIMIT $2 OFFSET $3`, HistoryCallRequested, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -420,10 +1137,733 @@ func (r *Repository) ListChats(ctx context.Context, limit, offset int) ([]ChatSu
 	var out []ChatSummary
 	for rows.Next() {
 		var cs ChatSummary
-		if err := rows.Scan(&cs.ID, &cs.ChatID, &cs.Username, &cs.Name, &cs.Title, &cs.HasDeal, &cs.LastMsg, &cs.LastAt); err != nil {
+		if err := rows.Scan(&cs.ID, &cs.ChatID, &cs.Channel, &cs.Username, &cs.Name, &cs.Title, &cs.HasDeal, &cs.LastMsg, &cs.LastAt); err != nil {
 			return out, err
 		}
 		out = append(out, cs)
 	}
 	return out, nil
 }
+
+// --- answer feedback ---
+
+// FeedbackDay is the number of positive and negative ratings given on a day.
+type FeedbackDay struct {
+	Day  time.Time
+	Up   int
+	Down int
+}
+
+// Satisfaction returns the share of positive ratings in percent.
+func (d FeedbackDay) Satisfaction() float64 {
+	if d.Up+d.Down == 0 {
+		return 0
+	}
+	return float64(d.Up) / float64(d.Up+d.Down) * 100
+}
+
+// DownvotedAnswer is an assistant answer rated 👎 with the question it answered.
+type DownvotedAnswer struct {
+	HistoryID int64
+	ChatUUID  string
+	ChatID    int64
+	Question  sql.NullString
+	Answer    string
+	Comment   sql.NullString
+	CreatedAt time.Time
+}
+
+// SaveFeedback stores or changes the rating of an assistant answer of the chat.
+// It returns false when the history row is not an answer in this chat.
+func (r *Repository) SaveFeedback(ctx context.Context, chatID, historyID int64, rating int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+               INSERT INTO answer_feedback(history_id, chat_id, rating)
+               SELECT id, chat_id, $3 FROM conversation_history
+               WHERE id=$1 AND chat_id=$2 AND role='assistant'
+               ON CONFLICT (history_id) DO UPDATE SET rating=EXCLUDED.rating, updated_at=NOW()`,
+		historyID, chatID, rating)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SaveFeedbackComment attaches a free-text comment to a rating of the chat.
+func (r *Repository) SaveFeedbackComment(ctx context.Context, chatID, historyID int64, comment string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE answer_feedback SET comment=$1, updated_at=NOW() WHERE history_id=$2 AND chat_id=$3`,
+		comment, historyID, chatID)
+	return err
+}
+
+// FeedbackByDay returns ratings grouped by day for the last days, newest first.
+func (r *Repository) FeedbackByDay(ctx context.Context, days int) ([]FeedbackDay, error) {
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT date_trunc('day', created_at) AS day,
+                      COUNT(*) FILTER (WHERE rating > 0),
+                      COUNT(*) FILTER (WHERE rating < 0)
+               FROM answer_feedback
+               WHERE created_at >= NOW() - make_interval(days => $1)
+               GROUP BY day
+               ORDER BY day DESC`, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FeedbackDay
+	for rows.Next() {
+		var d FeedbackDay
+		if err := rows.Scan(&d.Day, &d.Up, &d.Down); err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ListDownvotedAnswers returns the latest answers rated 👎.
+func (r *Repository) ListDownvotedAnswers(ctx context.Context, limit int) ([]DownvotedAnswer, error) {
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT h.id, c.uuid, h.chat_id,
+                      (SELECT q.content FROM conversation_history q
+                       WHERE q.chat_id=h.chat_id AND q.id < h.id AND q.role='user'
+                       ORDER BY q.id DESC LIMIT 1),
+                      h.content, f.comment, f.updated_at
+               FROM answer_feedback f
+               JOIN conversation_history h ON h.id = f.history_id
+               JOIN conversations c ON c.chat_id = h.chat_id
+               WHERE f.rating < 0
+               ORDER BY f.updated_at DESC
+               LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DownvotedAnswer
+	for rows.Next() {
+		var a DownvotedAnswer
+		if err := rows.Scan(&a.HistoryID, &a.ChatUUID, &a.ChatID, &a.Question, &a.Answer, &a.Comment, &a.CreatedAt); err != nil {
+			return out, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// --- answer sources ---
+
+// SaveAnswerChunks remembers which chunks were used to generate an answer.
+func (r *Repository) SaveAnswerChunks(ctx context.Context, historyID int64, matches []ChunkMatch) error {
+	for i, m := range matches {
+		_, err := r.db.ExecContext(ctx,
+			`INSERT INTO answer_chunks(history_id, chunk_id, rank, distance) VALUES ($1, $2, $3, $4)
+                         ON CONFLICT (history_id, chunk_id) DO NOTHING`,
+			historyID, m.ID, i+1, m.Distance)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnswerSource is a chunk used for an answer. Content is empty when the chunk was deleted.
+type AnswerSource struct {
+	ChunkID  int
+	Content  sql.NullString
+	Distance sql.NullFloat64
+}
+
+// AnswerReview is an assistant answer with the question and the chunks it was based on.
+type AnswerReview struct {
+	HistoryID int64
+	ChatUUID  string
+	ChatID    int64
+	Question  sql.NullString
+	Answer    string
+	Rating    int
+	Comment   sql.NullString
+	Sources   []AnswerSource
+}
+
+// GetAnswerReview returns an assistant answer with its question, rating and sources.
+func (r *Repository) GetAnswerReview(ctx context.Context, historyID int64) (AnswerReview, error) {
+	a := AnswerReview{HistoryID: historyID}
+	err := r.db.QueryRowContext(ctx, `
+               SELECT c.uuid, h.chat_id,
+                      (SELECT q.content FROM conversation_history q
+                       WHERE q.chat_id=h.chat_id AND q.id < h.id AND q.role='user'
+                       ORDER BY q.id DESC LIMIT 1),
+                      h.content, COALESCE(f.rating, 0), f.comment
+               FROM conversation_history h
+               JOIN conversations c ON c.chat_id = h.chat_id
+               LEFT JOIN answer_feedback f ON f.history_id = h.id
+               WHERE h.id=$1 AND h.role='assistant'`, historyID,
+	).Scan(&a.ChatUUID, &a.ChatID, &a.Question, &a.Answer, &a.Rating, &a.Comment)
+	if err != nil {
+		return a, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT a.chunk_id, ch.content, a.distance
+               FROM answer_chunks a
+               LEFT JOIN chunks ch ON ch.id = a.chunk_id
+               WHERE a.history_id=$1
+               ORDER BY a.rank`, historyID)
+	if err != nil {
+		return a, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s AnswerSource
+		if err := rows.Scan(&s.ChunkID, &s.Content, &s.Distance); err != nil {
+			return a, err
+		}
+		a.Sources = append(a.Sources, s)
+	}
+	return a, rows.Err()
+}
+
+// --- answer cache ---
+
+// CachedAnswer is a stored answer to a question with the chunks it was built from.
+type CachedAnswer struct {
+	ID       int
+	Question string
+	Answer   string
+	Distance float64
+	Chunks   []ChunkMatch
+}
+
+// Виды обращений к кэшу ответов для answer_cache_stats
+const (
+	AnswerCacheHit      = "hits"
+	AnswerCacheMiss     = "misses"
+	AnswerCacheBypassed = "bypassed"
+)
+
+// FindCachedAnswerByQuestion returns a cached answer in locale to exactly the same
+// normalized question created after since. found is false when there is none.
+func (r *Repository) FindCachedAnswerByQuestion(ctx context.Context, locale, question string, since time.Time) (a CachedAnswer, found bool, err error) {
+	err = r.db.QueryRowContext(ctx,
+		`SELECT id, question, answer FROM answer_cache
+                 WHERE locale=$1 AND question=$2 AND created_at >= $3
+                 ORDER BY created_at DESC LIMIT 1`,
+		locale, question, since,
+	).Scan(&a.ID, &a.Question, &a.Answer)
+	if err == sql.ErrNoRows {
+		return a, false, nil
+	}
+	if err != nil {
+		return a, false, err
+	}
+	a.Chunks, err = r.cachedAnswerChunks(ctx, a.ID)
+	return a, err == nil, err
+}
+
+// FindCachedAnswer returns the cached answer in locale whose question embedding is
+// closest to vec, if it is within maxDistance and was created after since.
+func (r *Repository) FindCachedAnswer(ctx context.Context, locale string, vec []float32, maxDistance float64, since time.Time) (a CachedAnswer, found bool, err error) {
+	err = r.db.QueryRowContext(ctx,
+		`SELECT id, question, answer, embedding <-> $1 FROM answer_cache
+                 WHERE locale=$2 AND created_at >= $3
+                 ORDER BY embedding <-> $1 LIMIT 1`,
+		pgvector.NewVector(vec), locale, since,
+	).Scan(&a.ID, &a.Question, &a.Answer, &a.Distance)
+	if err == sql.ErrNoRows || (err == nil && a.Distance > maxDistance) {
+		return a, false, nil
+	}
+	if err != nil {
+		return a, false, err
+	}
+	a.Chunks, err = r.cachedAnswerChunks(ctx, a.ID)
+	return a, err == nil, err
+}
+
+func (r *Repository) cachedAnswerChunks(ctx context.Context, cacheID int) ([]ChunkMatch, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.chunk_id, ch.content, COALESCE(a.distance, 0)
+                 FROM answer_cache_chunks a
+                 JOIN chunks ch ON ch.id = a.chunk_id
+                 WHERE a.cache_id=$1
+                 ORDER BY a.rank`, cacheID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ChunkMatch
+	for rows.Next() {
+		var m ChunkMatch
+		if err := rows.Scan(&m.ID, &m.Content, &m.Distance); err != nil {
+			return out, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SaveCachedAnswer stores an answer in locale with the chunks it was built from
+// and removes entries created before expireBefore. It reports whether the answer
+// was cached: an answer is skipped when any of its chunks changed or was deleted
+// while it was generated, since the invalidation trigger has already fired.
+func (r *Repository) SaveCachedAnswer(ctx context.Context, locale, question string, vec []float32, answer string, matches []ChunkMatch, expireBefore time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка не даёт изменить фрагменты, пока ответ не связан с ними
+	for _, m := range matches {
+		var content string
+		err := tx.QueryRowContext(ctx, "SELECT content FROM chunks WHERE id=$1 FOR SHARE", m.ID).Scan(&content)
+		if err == sql.ErrNoRows || (err == nil && content != m.Content) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM answer_cache WHERE created_at < $1", expireBefore); err != nil {
+		return false, err
+	}
+	var id int
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO answer_cache(locale, question, embedding, answer) VALUES ($1, $2, $3, $4) RETURNING id",
+		locale, question, pgvector.NewVector(vec), answer,
+	).Scan(&id)
+	if err != nil {
+		return false, err
+	}
+	for i, m := range matches {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO answer_cache_chunks(cache_id, chunk_id, rank, distance) VALUES ($1, $2, $3, $4)
+                         ON CONFLICT (cache_id, chunk_id) DO NOTHING`,
+			id, m.ID, i+1, m.Distance)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// MarkCachedAnswerHit counts a reuse of a cached answer.
+func (r *Repository) MarkCachedAnswerHit(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE answer_cache SET hits = hits + 1, last_hit_at = NOW() WHERE id=$1", id)
+	return err
+}
+
+// InvalidateCachedAnswersForHistory removes cached answers built from any chunk
+// used for the given assistant answer.
+func (r *Repository) InvalidateCachedAnswersForHistory(ctx context.Context, historyID int64) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM answer_cache WHERE id IN (
+                     SELECT c.cache_id FROM answer_cache_chunks c
+                     JOIN answer_chunks a ON a.chunk_id = c.chunk_id
+                     WHERE a.history_id=$1)`, historyID)
+	return err
+}
+
+// CountAnswerCacheEvent increments today's counter of the given kind:
+// AnswerCacheHit, AnswerCacheMiss or AnswerCacheBypassed.
+func (r *Repository) CountAnswerCacheEvent(ctx context.Context, kind string) error {
+	switch kind {
+	case AnswerCacheHit, AnswerCacheMiss, AnswerCacheBypassed:
+	default:
+		return fmt.Errorf("unknown answer cache event %q", kind)
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO answer_cache_stats(day, `+kind+`) VALUES (CURRENT_DATE, 1)
+                 ON CONFLICT (day) DO UPDATE SET `+kind+` = answer_cache_stats.`+kind+` + 1`)
+	return err
+}
+
+// AnswerCacheDay is the answer cache usage for a day.
+type AnswerCacheDay struct {
+	Day      time.Time
+	Hits     int
+	Misses   int
+	Bypassed int
+}
+
+// HitRate returns the share of cache hits among cacheable questions in percent.
+func (d AnswerCacheDay) HitRate() float64 {
+	total := d.Hits + d.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(d.Hits) / float64(total) * 100
+}
+
+// AnswerCacheByDay returns cache usage for the last days, newest first.
+func (r *Repository) AnswerCacheByDay(ctx context.Context, days int) ([]AnswerCacheDay, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT day, hits, misses, bypassed FROM answer_cache_stats
+                 WHERE day > CURRENT_DATE - $1::int
+                 ORDER BY day DESC`, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AnswerCacheDay
+	for rows.Next() {
+		var d AnswerCacheDay
+		if err := rows.Scan(&d.Day, &d.Hits, &d.Misses, &d.Bypassed); err != nil {
+			return out, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// CountCachedAnswers returns the number of cached answers.
+func (r *Repository) CountCachedAnswers(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM answer_cache").Scan(&n)
+	return n, err
+}
+
+// --- broadcasts ---
+
+// Статусы рассылки и доставки отдельному получателю
+const (
+	BroadcastDraft    = "draft"
+	BroadcastRunning  = "running"
+	BroadcastDone     = "done"
+	BroadcastCanceled = "canceled"
+
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliveryBlocked = "blocked"
+)
+
+// BroadcastSegment selects broadcast recipients. Empty fields do not filter.
+type BroadcastSegment struct {
+	HasLead     bool      `json:"has_lead,omitempty"`
+	Interest    string    `json:"interest,omitempty"`
+	ActiveSince time.Time `json:"active_since,omitempty"`
+}
+
+// where returns the condition on conversations c for the segment.
+// Only private chats that neither unsubscribed nor blocked the bot are included.
+func (s BroadcastSegment) where() (string, []any) {
+	cond := "c.chat_id > 0 AND c.channel='telegram' AND c.unsubscribed_at IS NULL AND c.blocked_at IS NULL"
+	var args []any
+	if s.HasLead {
+		args = append(args, HistoryCallRequested)
+		cond += fmt.Sprintf(" AND EXISTS(SELECT 1 FROM conversation_history h WHERE h.chat_id=c.chat_id AND h.content=$%d)", len(args))
+	}
+	if s.Interest != "" {
+		args = append(args, s.Interest)
+		cond += fmt.Sprintf(" AND c.interest ILIKE '%%' || $%d || '%%'", len(args))
+	}
+	if !s.ActiveSince.IsZero() {
+		args = append(args, s.ActiveSince)
+		cond += fmt.Sprintf(" AND EXISTS(SELECT 1 FROM conversation_history h WHERE h.chat_id=c.chat_id AND h.created_at >= $%d)", len(args))
+	}
+	return cond, args
+}
+
+// Broadcast is a message sent to a segment of bot users with its delivery progress.
+type Broadcast struct {
+	ID        int
+	Text      string
+	Segment   BroadcastSegment
+	Status    string
+	CreatedBy int64
+	Total     int
+	Pending   int
+	Sent      int
+	Failed    int
+	Blocked   int
+}
+
+// CountBroadcastRecipients returns the number of chats in the segment.
+func (r *Repository) CountBroadcastRecipients(ctx context.Context, s BroadcastSegment) (int, error) {
+	cond, args := s.where()
+	var n int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM conversations c WHERE "+cond, args...).Scan(&n)
+	return n, err
+}
+
+// CreateBroadcast saves a broadcast draft and returns its ID.
+func (r *Repository) CreateBroadcast(ctx context.Context, text string, s BroadcastSegment, createdBy int64) (int, error) {
+	segment, err := json.Marshal(s)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = r.db.QueryRowContext(ctx,
+		"INSERT INTO broadcasts(text, segment, created_by) VALUES ($1, $2, $3) RETURNING id",
+		text, segment, createdBy,
+	).Scan(&id)
+	return id, err
+}
+
+// StartBroadcast moves a draft to running and fixes its recipients.
+// started is false when the broadcast is not a draft anymore.
+func (r *Repository) StartBroadcast(ctx context.Context, id int) (started bool, err error) {
+	b, err := r.GetBroadcast(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE broadcasts SET status=$1, started_at=NOW() WHERE id=$2 AND status=$3",
+		BroadcastRunning, id, BroadcastDraft)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	cond, args := b.Segment.where()
+	args = append(args, id)
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO broadcast_deliveries(broadcast_id, chat_id) SELECT $%d, c.chat_id FROM conversations c WHERE %s",
+		len(args), cond), args...)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetBroadcast returns a broadcast with delivery counters.
+func (r *Repository) GetBroadcast(ctx context.Context, id int) (Broadcast, error) {
+	b := Broadcast{ID: id}
+	var segment []byte
+	err := r.db.QueryRowContext(ctx, `
+               SELECT b.text, b.segment, b.status, b.created_by,
+                      COUNT(d.chat_id),
+                      COUNT(*) FILTER (WHERE d.status=$2),
+                      COUNT(*) FILTER (WHERE d.status=$3),
+                      COUNT(*) FILTER (WHERE d.status=$4),
+                      COUNT(*) FILTER (WHERE d.status=$5)
+               FROM broadcasts b
+               LEFT JOIN broadcast_deliveries d ON d.broadcast_id = b.id
+               WHERE b.id=$1
+               GROUP BY b.id`, id, DeliveryPending, DeliverySent, DeliveryFailed, DeliveryBlocked,
+	).Scan(&b.Text, &segment, &b.Status, &b.CreatedBy, &b.Total, &b.Pending, &b.Sent, &b.Failed, &b.Blocked)
+	if err != nil {
+		return b, err
+	}
+	err = json.Unmarshal(segment, &b.Segment)
+	return b, err
+}
+
+// ListRunningBroadcasts returns IDs of broadcasts that have not finished sending.
+func (r *Repository) ListRunningBroadcasts(ctx context.Context) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM broadcasts WHERE status=$1 ORDER BY id", BroadcastRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PendingBroadcastRecipients returns chats that have not received the broadcast yet.
+func (r *Repository) PendingBroadcastRecipients(ctx context.Context, id, limit int) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT chat_id FROM broadcast_deliveries WHERE broadcast_id=$1 AND status=$2 ORDER BY chat_id LIMIT $3",
+		id, DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return out, err
+		}
+		out = append(out, chatID)
+	}
+	return out, rows.Err()
+}
+
+// SaveBroadcastDelivery records the delivery result for a chat.
+// A blocked delivery also excludes the chat from future broadcasts.
+func (r *Repository) SaveBroadcastDelivery(ctx context.Context, id int, chatID int64, status, errText string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE broadcast_deliveries SET status=$1, error=NULLIF($2, ''), sent_at=NOW()
+                 WHERE broadcast_id=$3 AND chat_id=$4`,
+		status, errText, id, chatID)
+	if err != nil || status != DeliveryBlocked {
+		return err
+	}
+	return r.MarkChatBlocked(ctx, chatID)
+}
+
+// MarkChatBlocked excludes a chat that blocked the bot from broadcasts and follow-ups
+// until the user writes again.
+func (r *Repository) MarkChatBlocked(ctx context.Context, chatID int64) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE conversations SET blocked_at=NOW() WHERE chat_id=$1", chatID)
+	return err
+}
+
+// FinishBroadcast sets the final status of a running or draft broadcast.
+func (r *Repository) FinishBroadcast(ctx context.Context, id int, status string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE broadcasts SET status=$1, finished_at=NOW() WHERE id=$2 AND status IN ($3, $4)",
+		status, id, BroadcastDraft, BroadcastRunning)
+	return err
+}
+
+// SetUnsubscribed turns broadcasts off or back on for a chat.
+func (r *Repository) SetUnsubscribed(ctx context.Context, chatID int64, unsubscribed bool) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE conversations SET unsubscribed_at = CASE WHEN $1 THEN NOW() END WHERE chat_id=$2",
+		unsubscribed, chatID)
+	return err
+}
+
+// --- follow-ups ---
+
+// FollowUpQuery selects chats for a follow-up rule.
+type FollowUpQuery struct {
+	Rule string
+	// Последнее сообщение пользователя было не раньше Window и не позже After назад
+	After  time.Duration
+	Window time.Duration
+	// Хотя бы одно сообщение пользователя содержит одно из слов; пустой список — любые беседы
+	TriggerWords []string
+	MaxPerChat   int
+	Limit        int
+}
+
+// FollowUpCandidate is a quiet chat that may receive a follow-up.
+type FollowUpCandidate struct {
+	ChatID   int64
+	Name     string
+	Summary  string
+	Interest string
+	Language string
+}
+
+// FollowUpCandidates returns private chats without a lead that went quiet, have not
+// reached the follow-up limit and got no follow-up of the rule since the last user
+// message. A failed attempt counts as sent until the user writes again, so other
+// rules of the same silence still apply.
+func (r *Repository) FollowUpCandidates(ctx context.Context, q FollowUpQuery) ([]FollowUpCandidate, error) {
+	args := []any{
+		q.After.Seconds(), (q.After + q.Window).Seconds(),
+		HistoryCallRequested, HistoryBookingCreated, q.MaxPerChat, q.Limit, q.Rule,
+	}
+	var words []string
+	for _, w := range q.TriggerWords {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			args = append(args, "%"+w+"%")
+			words = append(words, fmt.Sprintf("lower(h.content) LIKE $%d", len(args)))
+		}
+	}
+	triggerCond := ""
+	if len(words) > 0 {
+		triggerCond = `AND EXISTS(SELECT 1 FROM conversation_history h
+                                  WHERE h.chat_id=c.chat_id AND h.role='user' AND (` + strings.Join(words, " OR ") + `))`
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT c.chat_id, COALESCE(c.name, ''), COALESCE(c.summary, ''),
+                      COALESCE(c.interest, ''), COALESCE(c.language, '')
+               FROM conversations c
+               JOIN LATERAL (
+                       SELECT MAX(created_at) AS last_at FROM conversation_history h
+                       WHERE h.chat_id = c.chat_id AND h.role = 'user'
+               ) u ON true
+               WHERE c.chat_id > 0 AND c.channel='telegram' AND c.unsubscribed_at IS NULL AND c.blocked_at IS NULL
+                 AND c.amo_contact_id IS NULL
+                 AND u.last_at < NOW() - make_interval(secs => $1)
+                 AND u.last_at >= NOW() - make_interval(secs => $2)
+                 AND NOT EXISTS(SELECT 1 FROM conversation_history h WHERE h.chat_id=c.chat_id AND h.content IN ($3, $4))
+                 AND NOT EXISTS(SELECT 1 FROM followups f WHERE f.chat_id=c.chat_id AND f.rule=$7 AND f.sent_at > u.last_at)
+                 AND (SELECT COUNT(*) FROM followups f WHERE f.chat_id=c.chat_id AND NOT f.failed) < $5
+                 `+triggerCond+`
+               ORDER BY u.last_at
+               LIMIT $6`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FollowUpCandidate
+	for rows.Next() {
+		var c FollowUpCandidate
+		if err := rows.Scan(&c.ChatID, &c.Name, &c.Summary, &c.Interest, &c.Language); err != nil {
+			return out, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// SaveFollowUp records a follow-up. A failed one only keeps the chat from
+// being retried by the rule until the user writes again.
+func (r *Repository) SaveFollowUp(ctx context.Context, chatID int64, rule, text string, failed bool) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO followups(chat_id, rule, text, failed) VALUES ($1, $2, $3, $4)", chatID, rule, text, failed)
+	return err
+}
+
+// FollowUpStat is the result of a follow-up rule: how many users replied
+// and how many left a request or booked a lesson within the attribution window.
+type FollowUpStat struct {
+	Rule      string
+	Sent      int
+	Replied   int
+	Converted int
+}
+
+// ConversionRate returns the share of converted follow-ups in percent.
+func (s FollowUpStat) ConversionRate() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Converted) / float64(s.Sent) * 100
+}
+
+// FollowUpStats returns follow-up results per rule for follow-ups sent in the last days.
+func (r *Repository) FollowUpStats(ctx context.Context, days, attributionDays int) ([]FollowUpStat, error) {
+	rows, err := r.db.QueryContext(ctx, `
+               SELECT f.rule, COUNT(*),
+                      COUNT(*) FILTER (WHERE EXISTS(
+                          SELECT 1 FROM conversation_history h
+                          WHERE h.chat_id=f.chat_id AND h.role='user' AND h.created_at > f.sent_at
+                            AND h.created_at <= f.sent_at + make_interval(days => $2))),
+                      COUNT(*) FILTER (WHERE EXISTS(
+                          SELECT 1 FROM conversation_history h
+                          WHERE h.chat_id=f.chat_id AND h.content IN ($3, $4) AND h.created_at > f.sent_at
+                            AND h.created_at <= f.sent_at + make_interval(days => $2)))
+               FROM followups f
+               WHERE f.sent_at >= NOW() - make_interval(days => $1) AND NOT f.failed
+               GROUP BY f.rule
+               ORDER BY f.rule`, days, attributionDays, HistoryCallRequested, HistoryBookingCreated)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FollowUpStat
+	for rows.Next() {
+		var s FollowUpStat
+		if err := rows.Scan(&s.Rule, &s.Sent, &s.Replied, &s.Converted); err != nil {
+			return out, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,152 @@
+package tansultant
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"ragbot/internal/util"
+)
+
+// cache keeps the last successfully fetched value of an endpoint.
+// When the upstream fails, the previous value is served as stale.
+type cache[T any] struct {
+	name string
+
+	mu        sync.Mutex
+	value     T
+	loaded    bool
+	fetchedAt time.Time
+	lastErr   error
+	failingAt time.Time
+	alerted   bool
+	// inflight is the fetch in progress shared by concurrent callers
+	inflight *flight[T]
+}
+
+// flight is one fetch of the endpoint and its result.
+type flight[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// CacheStatus describes freshness of a cached endpoint.
+type CacheStatus struct {
+	Name      string
+	FetchedAt time.Time
+	LastError error
+}
+
+// get returns the cached value. A value older than ttl is returned as is and
+// refreshed in the background, so a slow upstream never delays the caller.
+// Only the first request waits for the fetch.
+func (c *cache[T]) get(ctx context.Context, ttl time.Duration, fetch func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	if !c.loaded {
+		c.mu.Unlock()
+		return c.refresh(ctx, fetch)
+	}
+	v := c.value
+	if time.Since(c.fetchedAt) >= ttl && c.inflight == nil {
+		f := c.startFlight()
+		c.mu.Unlock()
+		go func() {
+			defer util.Recover("tansultant " + c.name + " refresh")
+			// Запрос вызывающего может завершиться раньше обновления
+			c.fly(context.WithoutCancel(ctx), f, fetch)
+		}()
+		return v, nil
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+// expired reports whether the cached value is older than ttl.
+func (c *cache[T]) expired(ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loaded && time.Since(c.fetchedAt) >= ttl
+}
+
+// refresh fetches a new value. On error it falls back to the cached value if any.
+// Concurrent calls share one fetch.
+func (c *cache[T]) refresh(ctx context.Context, fetch func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	f := c.inflight
+	if f == nil {
+		f = c.startFlight()
+		c.mu.Unlock()
+		c.fly(ctx, f, fetch)
+	} else {
+		c.mu.Unlock()
+	}
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// startFlight registers a new fetch. It must be called with c.mu held.
+func (c *cache[T]) startFlight() *flight[T] {
+	f := &flight[T]{done: make(chan struct{})}
+	c.inflight = f
+	return f
+}
+
+// fly runs the fetch of f and stores its result.
+func (c *cache[T]) fly(ctx context.Context, f *flight[T], fetch func(context.Context) (T, error)) {
+	v, err := fetch(ctx)
+
+	c.mu.Lock()
+	defer close(f.done)
+	defer c.mu.Unlock()
+	c.inflight = nil
+	if err == nil {
+		c.value, c.loaded, c.fetchedAt = v, true, time.Now()
+		c.lastErr, c.alerted = nil, false
+		f.value = v
+		return
+	}
+	if c.lastErr == nil {
+		c.failingAt = time.Now()
+	}
+	c.lastErr = err
+	if c.loaded {
+		log.Printf("Tansultant %s request failed, serving data fetched at %s: %v", c.name, c.fetchedAt.Format(time.RFC3339), err)
+		f.value = c.value
+		return
+	}
+	f.err = err
+}
+
+// staleFor reports how long the cache has been failing to refresh.
+// The second result is true only the first time the threshold is exceeded,
+// so that an alert is sent once per outage.
+func (c *cache[T]) staleFor(threshold time.Duration) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastErr == nil {
+		return 0, false
+	}
+	// Если данные ещё ни разу не были получены, считаем с момента первой ошибки
+	age := time.Since(c.failingAt)
+	if c.loaded {
+		age = time.Since(c.fetchedAt)
+	}
+	if age < threshold || c.alerted {
+		return age, false
+	}
+	c.alerted = true
+	return age, true
+}
+
+func (c *cache[T]) status() CacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStatus{Name: c.name, FetchedAt: c.fetchedAt, LastError: c.lastErr}
+}
@@ -1,38 +1,115 @@
 package tansultant
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
+
+	"ragbot/internal/util"
 )
 
 // Client provides methods to get information from the Tansultant API.
+// Responses are cached in memory and served stale when the API is down.
 type Client struct {
-	HTTPClient      *http.Client
-	token           string
-	addressEndpoint string
-	pricesEndpoint  string
+	HTTPClient       *http.Client
+	token            string
+	addressEndpoint  string
+	pricesEndpoint   string
+	scheduleEndpoint string
+	bookingEndpoint  string
+	coordinates      map[string][2]float64
+	cacheTTL         time.Duration
+	staleAlertAfter  time.Duration
+	retryAttempts    int
+	retryDelay       time.Duration
+
+	// OnStale is called once when cached data has not been refreshed for too long.
+	OnStale func(name string, age time.Duration, err error)
+
+	branches cache[[]Branch]
+	prices   cache[[]Price]
+
+	scheduleMu sync.Mutex
+	schedules  map[string]*cache[[]Lesson]
 }
 
 // NewClient creates a client using environment variables.
 func NewClient() *Client {
 	loadConfig()
 	return &Client{
-		HTTPClient:      &http.Client{Timeout: 10 * time.Second},
-		token:           tansConfig.token,
-		addressEndpoint: tansConfig.addressEndpoint,
-		pricesEndpoint:  tansConfig.pricesEndpoint,
+		HTTPClient:       &http.Client{Timeout: 10 * time.Second},
+		token:            tansConfig.token,
+		addressEndpoint:  tansConfig.addressEndpoint,
+		pricesEndpoint:   tansConfig.pricesEndpoint,
+		scheduleEndpoint: tansConfig.scheduleEndpoint,
+		bookingEndpoint:  tansConfig.bookingEndpoint,
+		coordinates:      tansConfig.coordinates,
+		cacheTTL:         tansConfig.cacheTTL,
+		staleAlertAfter:  tansConfig.staleAlertAfter,
+		retryAttempts:    tansConfig.retryAttempts,
+		retryDelay:       tansConfig.retryDelay,
+		branches:         cache[[]Branch]{name: "branches"},
+		prices:           cache[[]Price]{name: "prices"},
+		schedules:        make(map[string]*cache[[]Lesson]),
 	}
 }
 
-func (c *Client) request(url string, v interface{}) error {
+// ErrNotConfigured is returned when the endpoint for a request is not set.
+var ErrNotConfigured = errors.New("endpoint not set")
+
+// errPermanent marks errors that should not be retried.
+type errPermanent struct{ err error }
+
+func (e errPermanent) Error() string { return e.err.Error() }
+
+// request performs a GET request retrying transient failures with exponential backoff.
+func (c *Client) request(ctx context.Context, url string, v interface{}) error {
 	if url == "" {
-		return fmt.Errorf("endpoint not set")
+		return ErrNotConfigured
+	}
+	delay := c.retryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.doRequest(ctx, http.MethodGet, url, nil, v)
+		var permanent errPermanent
+		if err == nil || errors.As(err, &permanent) || attempt >= c.retryAttempts {
+			return err
+		}
+		log.Printf("Tansultant request attempt %d failed: %v", attempt, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+}
+
+func (c *Client) doRequest(ctx context.Context, method, url string, payload, v interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return errPermanent{err}
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return err
+		return errPermanent{err}
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
@@ -42,26 +119,189 @@ func (c *Client) request(url string, v interface{}) error {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status: %s", resp.Status)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("unexpected status: %s %s", resp.Status, bytes.TrimSpace(data))
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			return errPermanent{err}
+		}
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errPermanent{err}
 	}
-	return json.NewDecoder(resp.Body).Decode(v)
+	return nil
 }
 
-// Branches returns available branches from the API.
-func (c *Client) Branches() ([]Branch, error) {
+func (c *Client) fetchBranches(ctx context.Context) ([]Branch, error) {
 	var branches []Branch
-	if err := c.request(c.addressEndpoint, &branches); err != nil {
+	if err := c.request(ctx, c.addressEndpoint, &branches); err != nil {
 		return nil, err
 	}
+	for i := range branches {
+		c.applyCoordinates(&branches[i])
+	}
 	return branches, nil
 }
 
-// Prices returns available passes and prices from the API.
-func (c *Client) Prices() ([]Price, error) {
+// applyCoordinates overrides branch coordinates from the local configuration,
+// looked up by branch ID first and then by title.
+func (c *Client) applyCoordinates(b *Branch) {
+	coords, ok := c.coordinates[strconv.Itoa(b.ID)]
+	if !ok {
+		coords, ok = c.coordinates[b.Title]
+	}
+	if ok {
+		b.Latitude, b.Longitude = Coordinate(coords[0]), Coordinate(coords[1])
+	}
+}
+
+func (c *Client) fetchPrices(ctx context.Context) ([]Price, error) {
 	var prices []Price
-	if err := c.request(c.pricesEndpoint, &prices); err != nil {
+	if err := c.request(ctx, c.pricesEndpoint, &prices); err != nil {
 		return nil, err
 	}
 	return prices, nil
 }
+
+// Branches returns available branches from the API.
+func (c *Client) Branches(ctx context.Context) ([]Branch, error) {
+	return c.branches.get(ctx, c.cacheTTL, c.fetchBranches)
+}
+
+// Prices returns available passes and prices from the API.
+func (c *Client) Prices(ctx context.Context) ([]Price, error) {
+	return c.prices.get(ctx, c.cacheTTL, c.fetchPrices)
+}
+
+// Schedule returns lessons of the branch between the from and to dates inclusive.
+func (c *Client) Schedule(ctx context.Context, branchID int, from, to time.Time) ([]Lesson, error) {
+	if c.scheduleEndpoint == "" {
+		return nil, ErrNotConfigured
+	}
+	dateFrom, dateTo := from.Format(time.DateOnly), to.Format(time.DateOnly)
+	key := fmt.Sprintf("%d:%s:%s", branchID, dateFrom, dateTo)
+
+	c.scheduleMu.Lock()
+	ch, ok := c.schedules[key]
+	if !ok {
+		// Расписания запрашиваются на разные даты, поэтому старые записи вычищаются,
+		// чтобы кэш не рос бесконечно.
+		for k, old := range c.schedules {
+			if old.expired(c.cacheTTL) {
+				delete(c.schedules, k)
+			}
+		}
+		ch = &cache[[]Lesson]{name: "schedule " + key}
+		c.schedules[key] = ch
+	}
+	c.scheduleMu.Unlock()
+
+	return ch.get(ctx, c.cacheTTL, func(ctx context.Context) ([]Lesson, error) {
+		u, err := url.Parse(c.scheduleEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("branch_id", strconv.Itoa(branchID))
+		q.Set("date_from", dateFrom)
+		q.Set("date_to", dateTo)
+		u.RawQuery = q.Encode()
+
+		var lessons []Lesson
+		if err := c.request(ctx, u.String(), &lessons); err != nil {
+			return nil, err
+		}
+		sort.SliceStable(lessons, func(i, j int) bool {
+			return lessons[i].StartsAt.Before(lessons[j].StartsAt.Time)
+		})
+		return lessons, nil
+	})
+}
+
+// Book creates a trial lesson booking. Bookings are not idempotent,
+// so the request is sent once without retries.
+func (c *Client) Book(ctx context.Context, b BookingRequest) (Booking, error) {
+	var booking Booking
+	if c.bookingEndpoint == "" {
+		return booking, ErrNotConfigured
+	}
+	if err := c.doRequest(ctx, http.MethodPost, c.bookingEndpoint, b, &booking); err != nil {
+		var permanent errPermanent
+		if errors.As(err, &permanent) {
+			return booking, permanent.err
+		}
+		return booking, err
+	}
+	return booking, nil
+}
+
+// BookingConfigured reports whether the booking endpoint is set.
+func (c *Client) BookingConfigured() bool {
+	return c.bookingEndpoint != ""
+}
+
+// ScheduleConfigured reports whether the schedule endpoint is set.
+func (c *Client) ScheduleConfigured() bool {
+	return c.scheduleEndpoint != ""
+}
+
+// Configured reports whether any API endpoint is set.
+func (c *Client) Configured() bool {
+	return c.addressEndpoint != "" || c.pricesEndpoint != ""
+}
+
+// Status returns freshness of every cached endpoint.
+func (c *Client) Status() []CacheStatus {
+	return []CacheStatus{c.branches.status(), c.prices.status()}
+}
+
+// StartRefresh keeps the cache warm by refreshing it in the background.
+// It runs twice per TTL, so cached values are replaced before they expire.
+func (c *Client) StartRefresh(ctx context.Context) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+	go func() {
+		defer util.Recover("tansultant refresh")
+		ticker := time.NewTicker(c.cacheTTL / 2)
+		defer ticker.Stop()
+		for {
+			c.Refresh(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh re-fetches all configured endpoints and reports stale data.
+func (c *Client) Refresh(ctx context.Context) {
+	if c.addressEndpoint != "" {
+		c.branches.refresh(ctx, c.fetchBranches)
+		c.checkStale(&c.branches)
+	}
+	if c.pricesEndpoint != "" {
+		c.prices.refresh(ctx, c.fetchPrices)
+		c.checkStale(&c.prices)
+	}
+}
+
+type staleChecker interface {
+	staleFor(threshold time.Duration) (time.Duration, bool)
+	status() CacheStatus
+}
+
+func (c *Client) checkStale(ch staleChecker) {
+	age, alert := ch.staleFor(c.staleAlertAfter)
+	if !alert {
+		return
+	}
+	st := ch.status()
+	log.Printf("Tansultant %s data is stale for %s: %v", st.Name, age.Round(time.Minute), st.LastError)
+	if c.OnStale != nil {
+		c.OnStale(st.Name, age, st.LastError)
+	}
+}
@@ -0,0 +1,244 @@
+package tansultant
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type stubAPI struct {
+	calls    atomic.Int32
+	failures atomic.Int32
+	status   atomic.Int32
+}
+
+func (s *stubAPI) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if s.failures.Load() > 0 {
+			s.failures.Add(-1)
+			w.WriteHeader(int(s.status.Load()))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[{"id":1,"title":"Центр","address":"ул. Пушкина, 1","latitude":"55.75","longitude":37.61}]`))
+	})
+}
+
+func newTestClient(url string) *Client {
+	return &Client{
+		HTTPClient:      http.DefaultClient,
+		token:           "token",
+		addressEndpoint: url,
+		cacheTTL:        time.Hour,
+		staleAlertAfter: time.Hour,
+		retryAttempts:   3,
+		retryDelay:      time.Millisecond,
+		branches:        cache[[]Branch]{name: "branches"},
+		prices:          cache[[]Price]{name: "prices"},
+		schedules:       make(map[string]*cache[[]Lesson]),
+	}
+}
+
+func TestBranchesAreCached(t *testing.T) {
+	api := &stubAPI{}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	for i := 0; i < 3; i++ {
+		branches, err := c.Branches(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(branches) != 1 || branches[0].Latitude != 55.75 || branches[0].Longitude != 37.61 {
+			t.Fatalf("unexpected branches: %+v", branches)
+		}
+	}
+	if got := api.calls.Load(); got != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", got)
+	}
+}
+
+func TestExpiredBranchesRefreshInBackground(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			<-release
+		}
+		w.Write([]byte(`[{"id":1,"title":"Центр"}]`))
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := newTestClient(srv.URL)
+	c.cacheTTL = time.Millisecond
+
+	if _, err := c.Branches(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		branches, err := c.Branches(context.Background())
+		if err != nil || len(branches) != 1 {
+			t.Fatalf("expected cached branches, got %+v, %v", branches, err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("expected one background refresh, got %d upstream calls", got)
+	}
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	api := &stubAPI{}
+	api.failures.Store(2)
+	api.status.Store(http.StatusBadGateway)
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+
+	if _, err := c.Branches(context.Background()); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if got := api.calls.Load(); got != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", got)
+	}
+}
+
+func TestNoRetryOnClientErrors(t *testing.T) {
+	api := &stubAPI{}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+	c.token = "wrong"
+
+	if _, err := c.Branches(context.Background()); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got := api.calls.Load(); got != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", got)
+	}
+}
+
+func TestStaleDataServedAndReported(t *testing.T) {
+	api := &stubAPI{}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+	c.staleAlertAfter = 0
+
+	var alerts []string
+	c.OnStale = func(name string, age time.Duration, err error) {
+		alerts = append(alerts, name)
+	}
+
+	c.Refresh(context.Background())
+	if len(alerts) != 0 {
+		t.Fatalf("unexpected alert for fresh data: %v", alerts)
+	}
+
+	api.failures.Store(100)
+	api.status.Store(http.StatusInternalServerError)
+	c.Refresh(context.Background())
+	c.Refresh(context.Background())
+
+	branches, err := c.Branches(context.Background())
+	if err != nil || len(branches) != 1 {
+		t.Fatalf("expected stale branches, got %+v, %v", branches, err)
+	}
+	if len(alerts) != 1 || alerts[0] != "branches" {
+		t.Fatalf("expected exactly one alert, got %v", alerts)
+	}
+}
+
+func TestScheduleRequestsDateRange(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`[
+			{"id":"2","name":"Хип-хоп","instructor":"Анна","starts_at":"2026-10-19 19:00:00","ends_at":"2026-10-19 20:00:00"},
+			{"id":"1","name":"Вог","starts_at":"2026-10-19 18:00","ends_at":"2026-10-19 19:00","status":"canceled"}
+		]`))
+	}))
+	defer srv.Close()
+	c := newTestClient("")
+	c.scheduleEndpoint = srv.URL + "?studio=main"
+
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	lessons, err := c.Schedule(context.Background(), 7, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query != "branch_id=7&date_from=2026-10-19&date_to=2026-10-20&studio=main" {
+		t.Fatalf("unexpected query: %s", query)
+	}
+	if len(lessons) != 2 || lessons[0].ID != "1" || !lessons[0].Canceled() || lessons[1].Instructor != "Анна" {
+		t.Fatalf("unexpected lessons: %+v", lessons)
+	}
+}
+
+func TestBookPostsRequestOnce(t *testing.T) {
+	var calls int
+	var got BookingRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"b-42","status":"new"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	c := newTestClient("")
+	c.bookingEndpoint = srv.URL
+
+	req := BookingRequest{BranchID: 7, LessonID: "l-1", Name: "Анна", Phone: "+79990000000"}
+	booking, err := c.Book(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if booking.ID != "b-42" || got != req {
+		t.Fatalf("unexpected booking %+v for request %+v", booking, got)
+	}
+
+	if _, err := c.Book(context.Background(), req); err == nil {
+		t.Fatalf("expected error")
+	}
+	if calls != 2 {
+		t.Fatalf("bookings must not be retried, got %d calls", calls)
+	}
+}
+
+func TestBranchCoordinatesOverride(t *testing.T) {
+	api := &stubAPI{}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	c := newTestClient(srv.URL)
+	c.coordinates = map[string][2]float64{"Центр": {55.7, 37.5}}
+
+	branches, err := c.Branches(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if branches[0].Latitude != 55.7 || branches[0].Longitude != 37.5 {
+		t.Fatalf("coordinates not overridden: %+v", branches[0])
+	}
+}
@@ -1,19 +1,54 @@
 package tansultant
 
-import "ragbot/internal/util"
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"ragbot/internal/util"
+)
 
 type tc struct {
-	token           string
-	addressEndpoint string
-	pricesEndpoint  string
+	token            string
+	addressEndpoint  string
+	pricesEndpoint   string
+	scheduleEndpoint string
+	bookingEndpoint  string
+	coordinates      map[string][2]float64
+	cacheTTL         time.Duration
+	staleAlertAfter  time.Duration
+	retryAttempts    int
+	retryDelay       time.Duration
 }
 
 var tansConfig *tc
 
 func loadConfig() {
 	tansConfig = &tc{
-		token:           util.GetEnvString("TANSULTANT_API_ACCESS_TOKEN", ""),
-		addressEndpoint: util.GetEnvString("TANSULTANT_API_ADDRESS_ENDPOINT", ""),
-		pricesEndpoint:  util.GetEnvString("TANSULTANT_API_PRICES_ENDPOINT", ""),
+		token:            util.GetEnvString("TANSULTANT_API_ACCESS_TOKEN", ""),
+		addressEndpoint:  util.GetEnvString("TANSULTANT_API_ADDRESS_ENDPOINT", ""),
+		pricesEndpoint:   util.GetEnvString("TANSULTANT_API_PRICES_ENDPOINT", ""),
+		scheduleEndpoint: util.GetEnvString("TANSULTANT_API_SCHEDULE_ENDPOINT", ""),
+		bookingEndpoint:  util.GetEnvString("TANSULTANT_API_BOOKING_ENDPOINT", ""),
+		coordinates:      loadCoordinates(),
+		cacheTTL:         time.Duration(util.GetEnvInt("TANSULTANT_CACHE_TTL", 300)) * time.Second,
+		staleAlertAfter:  time.Duration(util.GetEnvInt("TANSULTANT_STALE_ALERT_AFTER", 3600)) * time.Second,
+		retryAttempts:    util.GetEnvInt("TANSULTANT_RETRY_ATTEMPTS", 3),
+		retryDelay:       time.Duration(util.GetEnvInt("TANSULTANT_RETRY_DELAY_MS", 500)) * time.Millisecond,
+	}
+}
+
+// loadCoordinates читает координаты филиалов из TANSULTANT_BRANCH_COORDINATES:
+// JSON-объект, где ключ — ID или название филиала, а значение — [широта, долгота].
+func loadCoordinates() map[string][2]float64 {
+	raw := util.GetEnvString("TANSULTANT_BRANCH_COORDINATES", "")
+	if raw == "" {
+		return nil
+	}
+	var coordinates map[string][2]float64
+	if err := json.Unmarshal([]byte(raw), &coordinates); err != nil {
+		log.Printf("Invalid TANSULTANT_BRANCH_COORDINATES: %v", err)
+		return nil
 	}
+	return coordinates
 }
@@ -1,14 +1,28 @@
 package tansultant
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Branch represents a dance studio branch.
 // Only fields listed here are parsed from the API response.
 type Branch struct {
-	ID           int    `json:"id"`
-	Title        string `json:"title"`
-	Name         string `json:"name"`
-	Phone        string `json:"phone"`
-	Address      string `json:"address"`
-	ScheduleLink string `json:"schedule_public_link"`
+	ID           int        `json:"id"`
+	Title        string     `json:"title"`
+	Name         string     `json:"name"`
+	Phone        string     `json:"phone"`
+	Address      string     `json:"address"`
+	ScheduleLink string     `json:"schedule_public_link"`
+	Latitude     Coordinate `json:"latitude"`
+	Longitude    Coordinate `json:"longitude"`
+}
+
+// HasCoordinates reports whether the branch location is known.
+func (b Branch) HasCoordinates() bool {
+	return b.Latitude != 0 || b.Longitude != 0
 }
 
 // Price represents a subscription pass with its price and description.
@@ -23,3 +37,78 @@ type Price struct {
 	FreezeAllowed string `json:"freeze_allowed"`
 	GuestVisits   string `json:"guest_visits"`
 }
+
+// Coordinate is a geographic coordinate which the API may return
+// either as a number or as a string.
+type Coordinate float64
+
+func (c *Coordinate) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(data), `"`)
+	if raw == "" || raw == "null" {
+		*c = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid coordinate %q", raw)
+	}
+	*c = Coordinate(v)
+	return nil
+}
+
+// Lesson is a scheduled class in a branch.
+// Only fields listed here are parsed from the API response.
+type Lesson struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Instructor string    `json:"instructor"`
+	Classroom  string    `json:"classroom"`
+	BranchID   int       `json:"branch_id"`
+	StartsAt   Timestamp `json:"starts_at"`
+	EndsAt     Timestamp `json:"ends_at"`
+	Status     string    `json:"status"`
+}
+
+// Canceled reports whether the lesson was called off.
+func (l Lesson) Canceled() bool {
+	return l.Status == "canceled" || l.Status == "cancelled"
+}
+
+// Timestamp is a point in time which the API returns either in RFC 3339
+// or as a local "2006-01-02 15:04:05" string.
+type Timestamp struct {
+	time.Time
+}
+
+var timestampLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(data), `"`)
+	if raw == "" || raw == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range timestampLayouts {
+		if v, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid timestamp %q", raw)
+}
+
+// BookingRequest is a request to book a trial lesson.
+type BookingRequest struct {
+	BranchID int    `json:"branch_id"`
+	LessonID string `json:"lesson_id"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Booking is a created trial lesson booking.
+// Only fields listed here are parsed from the API response.
+type Booking struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
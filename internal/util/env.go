@@ -28,6 +28,18 @@ func GetEnvInt(key string, defaultValue int) int {
 	return i
 }
 
+func GetEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return f
+}
+
 func GetEnvBool(key string, defaultValue bool) bool {
 	value := os.Getenv(key)
 	if value == "" {
@@ -90,3 +102,17 @@ func GetEnvStringIntMap(key string, defaultValue map[string]int) map[string]int
 	}
 	return result
 }
+
+func GetEnvStringMap(key string, defaultValue map[string]string) map[string]string {
+	mapData := GetEnvJSON(key)
+	if mapData == nil {
+		return defaultValue
+	}
+	result := make(map[string]string)
+	for k, v := range mapData {
+		if s, ok := v.(string); ok {
+			result[k] = s
+		}
+	}
+	return result
+}
@@ -2,9 +2,10 @@ package util
 
 import "strings"
 
+// ContainsStringFromSlice reports whether str contains any non-empty string of slice.
 func ContainsStringFromSlice(str string, slice []string) bool {
 	for _, s := range slice {
-		if strings.Contains(str, s) {
+		if s != "" && strings.Contains(str, s) {
 			return true
 		}
 	}
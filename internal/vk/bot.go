@@ -0,0 +1,185 @@
+// Package vk connects the VK community messages to the conversation engine
+// through the Callback API.
+package vk
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ragbot/internal/messenger"
+	"ragbot/internal/repository"
+	"ragbot/internal/util"
+)
+
+// CallbackPath is the address of the Callback API server set in the community settings.
+const CallbackPath = "/vk/callback"
+
+// Беседы сообщества имеют peer_id от 2000000000, бот отвечает только в личных сообщениях
+const chatPeerIDOffset = 2000000000
+
+// VK повторяет событие, если не получил "ok" вовремя. Повторы узнаются
+// по event_id в течение eventTTL.
+const eventTTL = time.Hour
+
+// languages maps VK lang_id of the user's client to language codes.
+var languages = map[int]string{0: "ru", 1: "uk", 2: "be", 3: "en"}
+
+// Bot answers messages of the VK community through the conversation engine.
+type Bot struct {
+	client       *Client
+	engine       *messenger.Engine
+	confirmation string
+	secret       string
+	groupID      int64
+
+	eventsMu sync.Mutex
+	events   map[string]time.Time
+}
+
+// New creates the community bot or returns nil when VK_GROUP_TOKEN is not set.
+// Without VK_SECRET and VK_GROUP_ID anyone could post events, so the bot is not started.
+func New(engine *messenger.Engine) *Bot {
+	client := NewClient()
+	if !client.Configured() {
+		return nil
+	}
+	if vkConfig.secret == "" || vkConfig.groupID == 0 {
+		log.Println("VK bot is disabled: VK_SECRET and VK_GROUP_ID must be set")
+		return nil
+	}
+	return &Bot{
+		client:       client,
+		engine:       engine,
+		confirmation: vkConfig.confirmation,
+		secret:       vkConfig.secret,
+		groupID:      vkConfig.groupID,
+		events:       make(map[string]time.Time),
+	}
+}
+
+// Channel implements messenger.Adapter.
+func (b *Bot) Channel() string {
+	return repository.ChannelVK
+}
+
+// Send implements messenger.Adapter. VK messages have no markup, so the plain text is sent.
+func (b *Bot) Send(externalID string, msg messenger.Outbound) error {
+	peerID, err := strconv.ParseInt(externalID, 10, 64)
+	if err != nil {
+		return err
+	}
+	return b.client.SendMessage(context.Background(), peerID, msg.Text, inlineKeyboard(msg.Buttons))
+}
+
+type event struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	GroupID int64           `json:"group_id"`
+	Secret  string          `json:"secret"`
+	Object  json.RawMessage `json:"object"`
+}
+
+type messageNew struct {
+	Message struct {
+		FromID  int64  `json:"from_id"`
+		PeerID  int64  `json:"peer_id"`
+		Text    string `json:"text"`
+		Payload string `json:"payload"`
+	} `json:"message"`
+	ClientInfo struct {
+		LangID int `json:"lang_id"`
+	} `json:"client_info"`
+}
+
+// ServeHTTP handles the Callback API events of the community.
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer util.Recover("vk callback")
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var ev event
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(ev.Secret), []byte(b.secret)) != 1 {
+		http.Error(w, "invalid secret", http.StatusForbidden)
+		return
+	}
+	if ev.GroupID != b.groupID {
+		http.Error(w, "unknown community", http.StatusForbidden)
+		return
+	}
+
+	switch ev.Type {
+	case "confirmation":
+		w.Write([]byte(b.confirmation))
+		return
+	case "message_new":
+		if !b.firstDelivery(ev.EventID, time.Now()) {
+			break
+		}
+		var m messageNew
+		if err := json.Unmarshal(ev.Object, &m); err != nil {
+			log.Printf("Invalid VK message: %v", err)
+			break
+		}
+		if in, ok := inbound(m); ok {
+			// VK повторяет событие, пока не получит "ok", поэтому ответ
+			// отправляется сразу, а сообщение обрабатывается в фоне
+			go b.engine.Handle(b, in)
+		}
+	}
+	w.Write([]byte("ok"))
+}
+
+// firstDelivery remembers the event and reports whether it was not seen
+// during eventTTL. Events without an ID are always handled.
+func (b *Bot) firstDelivery(eventID string, now time.Time) bool {
+	if eventID == "" {
+		return true
+	}
+	b.eventsMu.Lock()
+	defer b.eventsMu.Unlock()
+	for id, at := range b.events {
+		if now.Sub(at) > eventTTL {
+			delete(b.events, id)
+		}
+	}
+	if _, ok := b.events[eventID]; ok {
+		return false
+	}
+	b.events[eventID] = now
+	return true
+}
+
+// inbound converts a community message to an engine message. Messages
+// from group chats and from other communities are skipped.
+func inbound(m messageNew) (messenger.Inbound, bool) {
+	if m.Message.PeerID >= chatPeerIDOffset || m.Message.FromID <= 0 {
+		return messenger.Inbound{}, false
+	}
+	in := messenger.Inbound{
+		ExternalID: strconv.FormatInt(m.Message.PeerID, 10),
+		Language:   languages[m.ClientInfo.LangID],
+		Text:       m.Message.Text,
+	}
+	if m.Message.Payload != "" {
+		var p payload
+		if err := json.Unmarshal([]byte(m.Message.Payload), &p); err != nil {
+			log.Printf("Invalid VK payload: %s", m.Message.Payload)
+		} else if p.Command == "start" {
+			in.Text = "/start"
+		} else {
+			in.Action = p.Action
+		}
+	}
+	return in, true
+}
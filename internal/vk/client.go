@@ -0,0 +1,99 @@
+package vk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the VK API on behalf of the community.
+type Client struct {
+	HTTPClient *http.Client
+	token      string
+	apiURL     string
+	version    string
+}
+
+// NewClient creates a client using environment variables.
+func NewClient() *Client {
+	loadConfig()
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		token:      vkConfig.token,
+		apiURL:     vkConfig.apiURL,
+		version:    vkConfig.apiVersion,
+	}
+}
+
+// Configured reports whether the community token is set.
+func (c *Client) Configured() bool {
+	return c.token != ""
+}
+
+// APIError is an error returned by the VK API.
+type APIError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"error_msg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("vk api error %d: %s", e.Code, e.Message)
+}
+
+type apiResponse struct {
+	Response json.RawMessage `json:"response"`
+	Error    *APIError       `json:"error"`
+}
+
+// call performs an API method and decodes the response into v when it is not nil.
+func (c *Client) call(ctx context.Context, method string, params url.Values, v interface{}) error {
+	params.Set("access_token", c.token)
+	params.Set("v", c.version)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"/"+method, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vk api %s: unexpected status %d", method, resp.StatusCode)
+	}
+	var r apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Response, v)
+}
+
+// SendMessage sends a text message with an optional keyboard to the peer.
+func (c *Client) SendMessage(ctx context.Context, peerID int64, text string, keyboard *Keyboard) error {
+	params := url.Values{}
+	params.Set("peer_id", strconv.FormatInt(peerID, 10))
+	// random_id защищает от повторной отправки того же сообщения
+	params.Set("random_id", strconv.FormatInt(int64(rand.Int32()), 10))
+	params.Set("message", text)
+	if keyboard != nil {
+		data, err := json.Marshal(keyboard)
+		if err != nil {
+			return err
+		}
+		params.Set("keyboard", string(data))
+	}
+	return c.call(ctx, "messages.send", params, nil)
+}
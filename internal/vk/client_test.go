@@ -0,0 +1,148 @@
+package vk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"ragbot/internal/messenger"
+)
+
+// stubAPI records messages.send calls like the VK API.
+type stubAPI struct {
+	sent []url.Values
+}
+
+func (s *stubAPI) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("access_token") != "token" {
+			w.Write([]byte(`{"error":{"error_code":5,"error_msg":"User authorization failed"}}`))
+			return
+		}
+		if r.URL.Path != "/messages.send" {
+			w.Write([]byte(`{"error":{"error_code":3,"error_msg":"Unknown method passed"}}`))
+			return
+		}
+		s.sent = append(s.sent, r.Form)
+		w.Write([]byte(`{"response":1}`))
+	})
+}
+
+func newTestBot(apiURL, token string) *Bot {
+	return &Bot{
+		client:       &Client{HTTPClient: http.DefaultClient, token: token, apiURL: apiURL, version: "5.199"},
+		confirmation: "confirm",
+		secret:       "secret",
+		groupID:      1,
+		events:       make(map[string]time.Time),
+	}
+}
+
+func TestSendWithKeyboard(t *testing.T) {
+	api := &stubAPI{}
+	srv := httptest.NewServer(api.handler())
+	defer srv.Close()
+	b := newTestBot(srv.URL, "token")
+
+	err := b.Send("42", messenger.Outbound{
+		Text:       "Позвать менеджера?",
+		MarkdownV2: "*Позвать менеджера?*",
+		Buttons:    [][]messenger.Button{{{Text: "Позвать", Action: messenger.ActionCallManager}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(api.sent) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(api.sent))
+	}
+	form := api.sent[0]
+	if form.Get("peer_id") != "42" || form.Get("message") != "Позвать менеджера?" || form.Get("v") != "5.199" {
+		t.Errorf("unexpected params: %v", form)
+	}
+	var kb Keyboard
+	if err := json.Unmarshal([]byte(form.Get("keyboard")), &kb); err != nil {
+		t.Fatalf("invalid keyboard: %v", err)
+	}
+	if !kb.Inline || len(kb.Buttons) != 1 || kb.Buttons[0][0].Action.Payload != `{"action":"CALL_MANAGER"}` {
+		t.Errorf("unexpected keyboard: %+v", kb)
+	}
+}
+
+func TestSendAPIError(t *testing.T) {
+	srv := httptest.NewServer((&stubAPI{}).handler())
+	defer srv.Close()
+	b := newTestBot(srv.URL, "wrong")
+
+	err := b.Send("42", messenger.Outbound{Text: "Привет"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 5 {
+		t.Fatalf("expected API error 5, got %v", err)
+	}
+}
+
+func TestCallbackConfirmationAndSecret(t *testing.T) {
+	b := newTestBot("", "token")
+
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader(`{"type":"confirmation","group_id":1,"secret":"secret"}`)))
+	if rec.Body.String() != "confirm" {
+		t.Errorf("expected confirmation code, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader(`{"type":"confirmation","group_id":1,"secret":"wrong"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a wrong secret, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader(`{"type":"confirmation","group_id":2,"secret":"secret"}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another community, got %d", rec.Code)
+	}
+}
+
+func TestFirstDelivery(t *testing.T) {
+	b := newTestBot("", "token")
+	now := time.Now()
+	if !b.firstDelivery("e1", now) {
+		t.Error("a new event must be handled")
+	}
+	if b.firstDelivery("e1", now.Add(time.Minute)) {
+		t.Error("a retried event must be skipped")
+	}
+	if !b.firstDelivery("e1", now.Add(2*eventTTL)) {
+		t.Error("events are forgotten after eventTTL")
+	}
+	if !b.firstDelivery("", now) || !b.firstDelivery("", now) {
+		t.Error("events without an ID must always be handled")
+	}
+}
+
+func TestInbound(t *testing.T) {
+	var m messageNew
+	json.Unmarshal([]byte(`{"message":{"from_id":7,"peer_id":7,"text":"Позвать","payload":"{\"action\":\"CALL_MANAGER\"}"},"client_info":{"lang_id":3}}`), &m)
+	in, ok := inbound(m)
+	if !ok || in.ExternalID != "7" || in.Action != messenger.ActionCallManager || in.Language != "en" {
+		t.Errorf("unexpected inbound: %+v", in)
+	}
+
+	m.Message.Payload = `{"command":"start"}`
+	if in, _ := inbound(m); in.Text != "/start" || in.Action != "" {
+		t.Errorf("start button must become /start: %+v", in)
+	}
+
+	m.Message.PeerID = chatPeerIDOffset + 1
+	if _, ok := inbound(m); ok {
+		t.Error("group chat messages must be skipped")
+	}
+}
@@ -0,0 +1,25 @@
+package vk
+
+import "ragbot/internal/util"
+
+type vc struct {
+	token        string
+	confirmation string
+	secret       string
+	groupID      int64
+	apiURL       string
+	apiVersion   string
+}
+
+var vkConfig *vc
+
+func loadConfig() {
+	vkConfig = &vc{
+		token:        util.GetEnvString("VK_GROUP_TOKEN", ""),
+		confirmation: util.GetEnvString("VK_CONFIRMATION_CODE", ""),
+		secret:       util.GetEnvString("VK_SECRET", ""),
+		groupID:      int64(util.GetEnvInt("VK_GROUP_ID", 0)),
+		apiURL:       util.GetEnvString("VK_API_URL", "https://api.vk.com/method"),
+		apiVersion:   util.GetEnvString("VK_API_VERSION", "5.199"),
+	}
+}
@@ -0,0 +1,75 @@
+package vk
+
+import (
+	"encoding/json"
+	"log"
+
+	"ragbot/internal/messenger"
+)
+
+// Ограничения встроенной клавиатуры VK
+const (
+	maxInlineRows  = 6
+	maxLabelLength = 40
+)
+
+// Keyboard is a bot keyboard attached to a message.
+type Keyboard struct {
+	Inline  bool               `json:"inline"`
+	Buttons [][]KeyboardButton `json:"buttons"`
+}
+
+type KeyboardButton struct {
+	Action ButtonAction `json:"action"`
+}
+
+type ButtonAction struct {
+	Type    string `json:"type"`
+	Label   string `json:"label"`
+	Payload string `json:"payload,omitempty"`
+	Link    string `json:"link,omitempty"`
+}
+
+// payload is the JSON payload of a text button. VK sends {"command":"start"}
+// when the user presses "Начать" in a new dialog.
+type payload struct {
+	Action  string `json:"action,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// inlineKeyboard converts engine buttons to an inline keyboard. Pressing
+// a text button sends its label back as a message with the action in the payload.
+func inlineKeyboard(rows [][]messenger.Button) *Keyboard {
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(rows) > maxInlineRows {
+		log.Printf("VK keyboard truncated from %d to %d rows", len(rows), maxInlineRows)
+		rows = rows[:maxInlineRows]
+	}
+	kb := &Keyboard{Inline: true}
+	for _, row := range rows {
+		var buttons []KeyboardButton
+		for _, b := range row {
+			action := ButtonAction{Type: "text", Label: truncateLabel(b.Text)}
+			if b.URL != "" {
+				action.Type = "open_link"
+				action.Link = b.URL
+			} else {
+				data, _ := json.Marshal(payload{Action: b.Action})
+				action.Payload = string(data)
+			}
+			buttons = append(buttons, KeyboardButton{Action: action})
+		}
+		kb.Buttons = append(kb.Buttons, buttons)
+	}
+	return kb
+}
+
+func truncateLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) <= maxLabelLength {
+		return label
+	}
+	return string(runes[:maxLabelLength-1]) + "…"
+}